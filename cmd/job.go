@@ -6,8 +6,9 @@ import (
 )
 
 const (
-	JOB_COMMAND       = "This command will show how to run lis job related commands (e.g. Migration, Scheduler)"
+	JOB_COMMAND       = "This command will show how to run lis job related commands (e.g. Migration, Outbox)"
 	MIGRATION_COMMAND = "This command will run the database migration to ensure the database schema is up to date."
+	OUTBOX_COMMAND    = "This command will deliver every due outbox entry to the LIS platform once, failed entries are retried by the next run."
 )
 
 var jobCommand = &cobra.Command{
@@ -22,3 +23,10 @@ var migrationCommand = &cobra.Command{
 	Long:  MIGRATION_COMMAND,
 	Run:   jobs.Migrate,
 }
+
+var outboxCommand = &cobra.Command{
+	Use:   "outbox",
+	Short: "Deliver pending results to the LIS platform",
+	Long:  OUTBOX_COMMAND,
+	Run:   jobs.DispatchOutbox,
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/Calmantara/lis-backend/internal/adaptors/listeners"
+	"github.com/spf13/cobra"
+)
+
+const (
+	MLLP_COMMAND   = "This command will start the lis MLLP TCP listener to receive HL7 messages from analyzers."
+	ASTM_COMMAND   = "This command will start the lis ASTM E1381 link as TCP server, TCP client or on a serial port to receive ASTM messages from analyzers."
+	SERIAL_COMMAND = "This command will start the lis serial readers to receive RS232 messages from the configured serial devices."
+)
+
+var mllpCommand = &cobra.Command{
+	Use:   "mllp",
+	Short: "Run lis MLLP TCP listener",
+	Long:  MLLP_COMMAND,
+	Run:   listeners.RunMLLP,
+}
+
+var astmCommand = &cobra.Command{
+	Use:   "astm",
+	Short: "Run lis ASTM E1381 link",
+	Long:  ASTM_COMMAND,
+	Run:   listeners.RunASTM,
+}
+
+var serialCommand = &cobra.Command{
+	Use:   "serial",
+	Short: "Run lis serial device readers",
+	Long:  SERIAL_COMMAND,
+	Run:   listeners.RunSerial,
+}
@@ -29,8 +29,8 @@ func main() {
 
 func init() {
 	// init all commands
-	jobCommand.AddCommand(migrationCommand)
+	jobCommand.AddCommand(migrationCommand, outboxCommand)
 
 	// manage all commands
-	rootCmd.AddCommand(httpCommand, jobCommand)
+	rootCmd.AddCommand(httpCommand, mllpCommand, astmCommand, serialCommand, workerCommand, jobCommand)
 }
@@ -16,6 +16,10 @@ func TestHttpCommands(t *testing.T) {
 	}
 
 	assert.Contains(t, longs, HTTP_COMMAND)
+	assert.Contains(t, longs, MLLP_COMMAND)
+	assert.Contains(t, longs, ASTM_COMMAND)
+	assert.Contains(t, longs, SERIAL_COMMAND)
+	assert.Contains(t, longs, WORKER_COMMAND)
 }
 
 func TestMainCommand(t *testing.T) {
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/Calmantara/lis-backend/internal/adaptors/workers"
+	"github.com/spf13/cobra"
+)
+
+const (
+	WORKER_COMMAND = "This command will start the lis background worker to retry pending deliveries and purge expired records on a bounded worker pool."
+)
+
+var workerCommand = &cobra.Command{
+	Use:   "worker",
+	Short: "Run lis background worker",
+	Long:  WORKER_COMMAND,
+	Run:   workers.RunWorker,
+}
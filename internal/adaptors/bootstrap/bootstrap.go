@@ -0,0 +1,125 @@
+package bootstrap
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/adaptors/publishers"
+	"github.com/Calmantara/lis-backend/internal/adaptors/storage/mysql"
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/services"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+// SignalContext and DiggerContext let tests hand a command its stop channel
+// and container through the command context.
+type (
+	SignalContext struct{}
+	DiggerContext struct{}
+)
+
+// Load reads the configuration and starts the logger of a command.
+func Load() {
+	configurations.Load()
+	utils.NewZap(
+		utils.WithAppName(configurations.Config.Application.Name),
+		utils.WithEnvironment(configurations.Config.Application.Environment),
+	)
+}
+
+// NewInjector provides the configuration sections, storage, result
+// publishers and services every command builds on.
+func NewInjector(digger *dig.Container) {
+	// digger config
+	digger.Provide(func() configurations.LisPlatform {
+		return configurations.Config.LisPlatform
+	})
+	digger.Provide(func() configurations.HL7 {
+		return configurations.Config.HL7
+	})
+	digger.Provide(func() configurations.TextParser {
+		return configurations.Config.TextParser
+	})
+	digger.Provide(func() configurations.Urine {
+		return configurations.Config.Urine
+	})
+	digger.Provide(func() configurations.Parsing {
+		return configurations.Config.Parsing
+	})
+	digger.Provide(func() configurations.Units {
+		return configurations.Config.Units
+	})
+	digger.Provide(func() configurations.Clock {
+		return configurations.Config.Clock
+	})
+	digger.Provide(func() configurations.Charset {
+		return configurations.Config.Charset
+	})
+	digger.Provide(func() configurations.Outbox {
+		return configurations.Config.Outbox
+	})
+	digger.Provide(func() configurations.Routing {
+		return configurations.Config.Routing
+	})
+	digger.Provide(func() configurations.Worker {
+		return configurations.Config.Worker
+	})
+
+	// dependency injection
+	mysql.NewInjector(digger)
+	publishers.NewInjector(digger)
+	services.NewInjector(digger)
+}
+
+// Close releases what the container opened once the command stopped
+// delivering results.
+func Close(digger *dig.Container) {
+	if err := publishers.Close(digger); err != nil {
+		utils.Log.Errorw("failed to close the result publishers", map[string]any{"error": err.Error()})
+	}
+}
+
+// GracefulShutdown runs shutdown within the configured graceful period.
+func GracefulShutdown(shutdown func(ctx context.Context) error) error {
+	ctx, cancel := context.WithDeadline(
+		context.Background(),
+		time.Now().Add(configurations.Config.Application.Graceful*time.Second),
+	)
+	defer cancel()
+
+	return shutdown(ctx)
+}
+
+func Digger(cmd *cobra.Command) *dig.Container {
+	ctx := cmd.Context()
+	if ctx != nil {
+		// check digger from context
+		ctxDigger := ctx.Value(DiggerContext{})
+		if dg, ok := ctxDigger.(*dig.Container); ok {
+			return dg
+		}
+	}
+
+	return dig.New()
+}
+
+func SignalChan(cmd *cobra.Command) chan os.Signal {
+	ctx := cmd.Context()
+	if ctx != nil {
+		// check signal channel from context
+		ctxValue := ctx.Value(SignalContext{})
+		if ch, ok := ctxValue.(chan os.Signal); ok {
+			return ch
+		}
+	}
+
+	stopChan := make(chan os.Signal, 1)
+	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
+
+	return stopChan
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/helpers/wrappers"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type DeadLetterHdlImpl struct {
+	deadLetterService ports.DeadLetterService
+	middleware        ports.Middleware
+}
+
+func NewDeadLetterHandler(
+	deadLetterService ports.DeadLetterService,
+	middleware ports.Middleware,
+) ports.DeadLetterHdl {
+	return &DeadLetterHdlImpl{
+		deadLetterService: deadLetterService,
+		middleware:        middleware,
+	}
+}
+
+func (a *DeadLetterHdlImpl) MountV1(external, internal *echo.Group) {
+	deadLetterGroup := internal.Group("/dead-letters", a.middleware.BasicApplicationKey)
+	{
+		deadLetterGroup.GET("", a.List)
+		deadLetterGroup.GET("/:id", a.Get)
+		deadLetterGroup.POST("/:id/replay", a.Replay)
+		deadLetterGroup.DELETE("/:id", a.Discard)
+	}
+}
+
+func (a *DeadLetterHdlImpl) List(ctx echo.Context) error {
+	c := utils.GetEchoContext(ctx)
+	// bind query
+	params := models.DeadLetterFilter{}
+	if err := ctx.Bind(&params); err != nil {
+		err = errors.Wrap(err, errors.ERROR_BAD_REQUEST.Error())
+
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+	if err := params.Validate(); err != nil {
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+
+	deadLetters, page, err := a.deadLetterService.List(c, params)
+	if err != nil {
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+
+	return wrappers.ConstructResponseSuccess(
+		ctx,
+		wrappers.SuccessFetched(deadLetters, page),
+	)
+}
+
+func (a *DeadLetterHdlImpl) Get(ctx echo.Context) error {
+	c := utils.GetEchoContext(ctx)
+	id, err := deadLetterID(ctx)
+	if err != nil {
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+
+	deadLetter, err := a.deadLetterService.Get(c, id)
+	if err != nil {
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+
+	return wrappers.ConstructResponseSuccess(
+		ctx,
+		wrappers.SuccessFetched(deadLetter, nil),
+	)
+}
+
+func (a *DeadLetterHdlImpl) Replay(ctx echo.Context) error {
+	c := utils.GetEchoContext(ctx)
+	id, err := deadLetterID(ctx)
+	if err != nil {
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+	// bind payload, an empty body replays the snapshot
+	params := models.DeadLetterReplayParam{}
+	if err := ctx.Bind(&params); err != nil {
+		err = errors.Wrap(err, errors.ERROR_BAD_REQUEST.Error())
+
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+
+	output, err := a.deadLetterService.Replay(c, id, params)
+	if err != nil {
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+
+	return wrappers.ConstructResponseSuccess(
+		ctx,
+		wrappers.SuccessOK(output, nil),
+	)
+}
+
+func (a *DeadLetterHdlImpl) Discard(ctx echo.Context) error {
+	c := utils.GetEchoContext(ctx)
+	id, err := deadLetterID(ctx)
+	if err != nil {
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+
+	deadLetter, err := a.deadLetterService.Discard(c, id)
+	if err != nil {
+		return wrappers.ConstructResponseFailure(ctx, err)
+	}
+
+	return wrappers.ConstructResponseSuccess(
+		ctx,
+		wrappers.SuccessDeleted(deadLetter, nil),
+	)
+}
+
+func deadLetterID(ctx echo.Context) (uuid.UUID, error) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		return uuid.Nil, errors.Wrapf(errors.ERROR_BAD_REQUEST, "dead letter id %q", ctx.Param("id"))
+	}
+
+	return id, nil
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/Calmantara/lis-backend/internal/core/models"
 	"github.com/Calmantara/lis-backend/internal/core/ports"
 	"github.com/Calmantara/lis-backend/internal/helpers/errors"
@@ -9,6 +12,10 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	MIME_HL7_V2 = "application/hl7-v2"
+)
+
 type DeviceMessageHdlImpl struct {
 	deviceMessageService ports.DeviceMessageService
 	middleware           ports.Middleware
@@ -47,13 +54,26 @@ func (a *DeviceMessageHdlImpl) Create(ctx echo.Context) error {
 		return wrappers.ConstructResponseFailure(ctx, err)
 	}
 
-	err := a.deviceMessageService.Process(c, params.ToInput())
+	output, err := a.deviceMessageService.Process(c, params.ToInput())
+	// hl7 peers expect the acknowledgement itself, including AE / AR, it
+	// only reports the error condition so the error is logged here
+	if output != nil && output.Acknowledgement != "" &&
+		strings.Contains(ctx.Request().Header.Get(echo.HeaderAccept), MIME_HL7_V2) {
+		if err != nil {
+			utils.Log.Errorw("failed to process hl7 message", map[string]any{
+				"device_id": params.DeviceID,
+				"error":     err.Error(),
+			})
+		}
+
+		return ctx.Blob(http.StatusOK, MIME_HL7_V2, []byte(output.Acknowledgement))
+	}
 	if err != nil {
 		return wrappers.ConstructResponseFailure(ctx, err)
 	}
 	// 	construct response
 	return wrappers.ConstructResponseSuccess(
 		ctx,
-		wrappers.SuccessOK("ok", nil),
+		wrappers.SuccessOK(output, nil),
 	)
 }
@@ -10,13 +10,16 @@ type InjectorInput struct {
 	dig.In
 	RouterV1         models.Router `name:"routerV1"`
 	DeviceMessageHdl ports.DeviceMessageHdl
+	DeadLetterHdl    ports.DeadLetterHdl
 }
 
 func NewInjector(digger *dig.Container) {
 	digger.Provide(NewMiddlewareHandler)
 	digger.Provide(NewDeviceMessageHandler)
+	digger.Provide(NewDeadLetterHandler)
 }
 
 func Invoke(input InjectorInput) {
 	input.DeviceMessageHdl.MountV1(input.RouterV1.External, input.RouterV1.Internal)
+	input.DeadLetterHdl.MountV1(input.RouterV1.External, input.RouterV1.Internal)
 }
@@ -1,41 +1,26 @@
 package http
 
 import (
-	"context"
 	"fmt"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
 
+	"github.com/Calmantara/lis-backend/internal/adaptors/bootstrap"
 	"github.com/Calmantara/lis-backend/internal/adaptors/handlers"
-	"github.com/Calmantara/lis-backend/internal/adaptors/storage/mysql"
 	"github.com/Calmantara/lis-backend/internal/core/configurations"
 	"github.com/Calmantara/lis-backend/internal/core/models"
-	"github.com/Calmantara/lis-backend/internal/core/services"
 	"github.com/Calmantara/lis-backend/internal/utils"
 	"github.com/labstack/echo/v4"
 	"github.com/spf13/cobra"
 	"go.uber.org/dig"
 )
 
-type (
-	SignalContext struct{}
-	DiggerContext struct{}
-)
-
 func RunEcho(cmd *cobra.Command, args []string) {
-	configurations.Load()
-	utils.NewZap(
-		utils.WithAppName(configurations.Config.Application.Name),
-		utils.WithEnvironment(configurations.Config.Application.Environment),
-	)
+	bootstrap.Load()
 
 	// os channel
-	stopChan := getSignalChan(cmd)
+	stopChan := bootstrap.SignalChan(cmd)
 	// dig dependency injection
-	digger := getDigger(cmd)
+	digger := bootstrap.Digger(cmd)
 	// echo server
 	e := echo.New()
 	routerV1 := RouterV1(e)
@@ -43,14 +28,8 @@ func RunEcho(cmd *cobra.Command, args []string) {
 		return routerV1
 	}, dig.Name("routerV1"))
 
-	// digger config
-	digger.Provide(func() configurations.LisPlatform {
-		return configurations.Config.LisPlatform
-	})
-
 	// dependency injection
-	mysql.NewInjector(digger)
-	services.NewInjector(digger)
+	bootstrap.NewInjector(digger)
 	handlers.NewInjector(digger)
 	// invoke
 	err := digger.Invoke(handlers.Invoke)
@@ -59,11 +38,15 @@ func RunEcho(cmd *cobra.Command, args []string) {
 	}
 
 	// start server
+	stopped := make(chan struct{})
 	go func() {
+		defer close(stopped)
 		// Wait for interrupt signal to gracefully shut down the server
 		<-stopChan
 		err = GracefulShutdown(e)
 		utils.Log.Infow("gracefully shutting down the server", map[string]any{"error": err})
+		// requests in flight are done, nothing is published anymore
+		bootstrap.Close(digger)
 	}()
 
 	port := fmt.Sprintf(":%d", configurations.Config.Application.Port)
@@ -76,16 +59,11 @@ func RunEcho(cmd *cobra.Command, args []string) {
 	if err := e.Start(port); err != nil && err != http.ErrServerClosed {
 		panic(err)
 	}
+	<-stopped
 }
 
 func GracefulShutdown(e *echo.Echo) error {
-	ctx, cancel := context.WithDeadline(
-		context.Background(),
-		time.Now().Add(configurations.Config.Application.Graceful*time.Second),
-	)
-	defer cancel()
-
-	return e.Shutdown(ctx)
+	return bootstrap.GracefulShutdown(e.Shutdown)
 }
 
 func RouterV1(e *echo.Echo) models.Router {
@@ -94,32 +72,3 @@ func RouterV1(e *echo.Echo) models.Router {
 		Internal: e.Group("/api/internal/v1"),
 	}
 }
-
-func getDigger(cmd *cobra.Command) *dig.Container {
-	ctx := cmd.Context()
-	if ctx != nil {
-		// check digger from context
-		ctxDigger := ctx.Value(DiggerContext{})
-		if dg, ok := ctxDigger.(*dig.Container); ok {
-			return dg
-		}
-	}
-
-	return dig.New()
-}
-
-func getSignalChan(cmd *cobra.Command) chan os.Signal {
-	ctx := cmd.Context()
-	if ctx != nil {
-		// check signal channel from context
-		ctxValue := ctx.Value(SignalContext{})
-		if ch, ok := ctxValue.(chan os.Signal); ok {
-			return ch
-		}
-	}
-
-	stopChan := make(chan os.Signal, 1)
-	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
-
-	return stopChan
-}
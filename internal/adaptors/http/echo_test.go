@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Calmantara/lis-backend/internal/adaptors/bootstrap"
 	"github.com/Calmantara/lis-backend/internal/core/configurations"
 	"github.com/Calmantara/lis-backend/internal/core/ports"
 	"github.com/labstack/echo/v4"
@@ -60,7 +61,7 @@ func TestRunEcho(t *testing.T) {
 	stopChan := make(chan os.Signal, 1)
 	signal.Notify(stopChan, syscall.SIGINT, syscall.SIGTERM)
 
-	ctx := context.WithValue(t.Context(), SignalContext{}, stopChan)
+	ctx := context.WithValue(t.Context(), bootstrap.SignalContext{}, stopChan)
 	cmd.SetContext(ctx)
 
 	go func() {
@@ -87,7 +88,7 @@ func TestRunEcho_WithDiggerContext(t *testing.T) {
 	}
 	digger.Provide(handler)
 
-	ctx := context.WithValue(t.Context(), DiggerContext{}, digger)
+	ctx := context.WithValue(t.Context(), bootstrap.DiggerContext{}, digger)
 	cmd.SetContext(ctx)
 
 	assert.Panics(t, func() {
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/Calmantara/lis-backend/internal/adaptors/bootstrap"
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// DispatchOutbox delivers every due outbox entry once and exits, failed
+// entries are left pending for the next run.
+func DispatchOutbox(cmd *cobra.Command, args []string) {
+	configuration := configurations.Load()
+	log := utils.NewZap(
+		utils.WithAppName(configuration.Application.ApplicationName()),
+		utils.WithEnvironment(configuration.Application.Environment),
+	)
+	log.Infow("Running lis outbox dispatch job")
+
+	digger := bootstrap.Digger(cmd)
+	bootstrap.NewInjector(digger)
+
+	var dispatcher ports.OutboxDispatcher
+	err := digger.Invoke(func(outboxDispatcher ports.OutboxDispatcher) {
+		dispatcher = outboxDispatcher
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// every attempt moves its entry past now, so the outbox drains
+	total := 0
+	for {
+		attempted, err := dispatcher.Dispatch(ctx)
+		total += attempted
+		if err != nil {
+			log.Errorw("failed to dispatch outbox entries", map[string]any{
+				"error": err.Error(),
+			})
+			break
+		}
+		if attempted == 0 {
+			break
+		}
+	}
+
+	bootstrap.Close(digger)
+
+	log.Infow("Successfully dispatched outbox entries", map[string]any{
+		"attempted": total,
+	})
+}
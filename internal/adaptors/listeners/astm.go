@@ -0,0 +1,161 @@
+package listeners
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	ASTM_MODE_SERVER = "server"
+	ASTM_MODE_CLIENT = "client"
+	ASTM_MODE_SERIAL = "serial"
+
+	DEFAULT_ASTM_RECONNECT_INTERVAL = 10 * time.Second
+	DEFAULT_ASTM_BAUD_RATE          = 9600
+)
+
+// ASTMServer accepts analyzers connecting over TCP, one link per connection.
+type ASTMServer struct {
+	*connServer
+	config               configurations.ASTM
+	deviceMessageService ports.DeviceMessageService
+	options              []ASTMLinkOption
+}
+
+func NewASTMServer(config configurations.ASTM, deviceMessageService ports.DeviceMessageService, options ...ASTMLinkOption) *ASTMServer {
+	server := &ASTMServer{
+		config:               config,
+		deviceMessageService: deviceMessageService,
+		options:              append([]ASTMLinkOption{WithASTMMaxMessageSize(config.MaxMessageSize)}, options...),
+	}
+	server.connServer = newConnServer(config.MaxConnection, server.handle)
+
+	return server
+}
+
+func (s *ASTMServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	deviceID := s.config.PeerDevice(host)
+
+	link := NewASTMLink(conn, processASTM(s.deviceMessageService, deviceID, s.config.DeviceTypeCode), s.options...)
+	if err := link.Run(context.Background()); err != nil && !errors.Is(err, io.EOF) && !s.isClosed() {
+		utils.Log.Errorw("astm link stopped", map[string]any{
+			"peer":  conn.RemoteAddr().String(),
+			"error": err.Error(),
+		})
+	}
+}
+
+// ASTMConnector keeps a single link open to an analyzer, either by dialing
+// its TCP port or by opening a serial port, and reopens it when it drops.
+type ASTMConnector struct {
+	config               configurations.ASTM
+	deviceMessageService ports.DeviceMessageService
+	options              []ASTMLinkOption
+	open                 func(ctx context.Context) (io.ReadWriteCloser, error)
+}
+
+// NewASTMClient connects to an analyzer acting as TCP server.
+func NewASTMClient(config configurations.ASTM, deviceMessageService ports.DeviceMessageService, options ...ASTMLinkOption) *ASTMConnector {
+	return newASTMConnector(config, deviceMessageService, options, func(ctx context.Context) (io.ReadWriteCloser, error) {
+		dialer := net.Dialer{}
+
+		return dialer.DialContext(ctx, "tcp", config.Address)
+	})
+}
+
+// NewASTMSerial talks to an analyzer on a serial port, 8N1 at the
+// configured baud rate.
+func NewASTMSerial(config configurations.ASTM, deviceMessageService ports.DeviceMessageService, options ...ASTMLinkOption) *ASTMConnector {
+	return newASTMConnector(config, deviceMessageService, options, func(ctx context.Context) (io.ReadWriteCloser, error) {
+		baudRate := config.BaudRate
+		if baudRate <= 0 {
+			baudRate = DEFAULT_ASTM_BAUD_RATE
+		}
+
+		return openSerialPort(configurations.SerialDevice{
+			Port:        config.SerialPort,
+			BaudRate:    baudRate,
+			DataBits:    8,
+			Parity:      configurations.SERIAL_PARITY_NONE,
+			StopBits:    1,
+			FlowControl: configurations.SERIAL_FLOW_NONE,
+		})
+	})
+}
+
+func newASTMConnector(
+	config configurations.ASTM,
+	deviceMessageService ports.DeviceMessageService,
+	options []ASTMLinkOption,
+	open func(ctx context.Context) (io.ReadWriteCloser, error),
+) *ASTMConnector {
+	return &ASTMConnector{
+		config:               config,
+		deviceMessageService: deviceMessageService,
+		options:              append([]ASTMLinkOption{WithASTMMaxMessageSize(config.MaxMessageSize)}, options...),
+		open:                 open,
+	}
+}
+
+// Run keeps the link up until the context is cancelled.
+func (c *ASTMConnector) Run(ctx context.Context) error {
+	interval := c.config.ReconnectInterval
+	if interval <= 0 {
+		interval = DEFAULT_ASTM_RECONNECT_INTERVAL
+	}
+
+	return keepConnected(ctx, interval, c.config.DeviceID, c.runOnce)
+}
+
+func (c *ASTMConnector) runOnce(ctx context.Context) error {
+	stream, err := c.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	// closing the stream unblocks the link reader on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		stream.Close()
+	}()
+
+	link := NewASTMLink(stream, processASTM(c.deviceMessageService, c.config.DeviceID, c.config.DeviceTypeCode), c.options...)
+
+	return link.Run(ctx)
+}
+
+// processASTM hands completed messages to the device message service.
+func processASTM(deviceMessageService ports.DeviceMessageService, deviceID, deviceTypeCode string) ASTMHandler {
+	return func(message []byte) {
+		ctx := utils.SetRequestID(context.Background(), uuid.NewString())
+		_, err := deviceMessageService.Process(ctx, &models.DeviceMessageInput{
+			DeviceID:       deviceID,
+			DeviceTypeCode: deviceTypeCode,
+			Message:        string(message),
+			Protocol:       models.PROTOCOL_ASTM,
+		})
+		if err != nil {
+			utils.Log.Errorw("failed to process astm message", map[string]any{
+				"device_id": deviceID,
+				"error":     err.Error(),
+			})
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package listeners
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// ASTM E1381 (CLSI LIS1-A) control characters
+const (
+	ASTM_ENQ byte = 0x05
+	ASTM_ACK byte = 0x06
+	ASTM_NAK byte = 0x15
+	ASTM_EOT byte = 0x04
+	ASTM_STX byte = 0x02
+	ASTM_ETX byte = 0x03
+	ASTM_ETB byte = 0x17
+	ASTM_CR  byte = 0x0D
+	ASTM_LF  byte = 0x0A
+
+	// ASTM_MAX_FRAME_TEXT is the maximum text per frame, longer records are
+	// split into intermediate ETB frames followed by a final ETX frame
+	ASTM_MAX_FRAME_TEXT = 240
+	// ASTM_MAX_FRAME_SIZE is STX FN text ETX C1 C2 CR LF
+	ASTM_MAX_FRAME_SIZE = ASTM_MAX_FRAME_TEXT + 7
+)
+
+// ASTMChecksum is the modulo 256 sum of the frame number, text and ETB/ETX
+// as two uppercase hex characters.
+func ASTMChecksum(data []byte) string {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+
+	return fmt.Sprintf("%02X", sum)
+}
+
+// BuildASTMFrames splits a message into frames. Every record gets its own
+// frames so receivers can process records as they arrive, frame numbers run
+// 1..7, 0, 1.. across the whole message.
+func BuildASTMFrames(message []byte) [][]byte {
+	frames := [][]byte{}
+	number := byte(1)
+
+	for _, record := range bytes.SplitAfter(message, []byte{ASTM_CR}) {
+		if len(record) == 0 {
+			continue
+		}
+
+		for len(record) > 0 {
+			size := min(len(record), ASTM_MAX_FRAME_TEXT)
+			terminator := ASTM_ETB
+			if size == len(record) {
+				terminator = ASTM_ETX
+			}
+
+			frames = append(frames, buildASTMFrame(number, record[:size], terminator))
+			record = record[size:]
+			number = (number + 1) % 8
+		}
+	}
+
+	return frames
+}
+
+func buildASTMFrame(number byte, text []byte, terminator byte) []byte {
+	body := make([]byte, 0, len(text)+2)
+	body = append(body, '0'+number)
+	body = append(body, text...)
+	body = append(body, terminator)
+
+	frame := make([]byte, 0, len(body)+5)
+	frame = append(frame, ASTM_STX)
+	frame = append(frame, body...)
+	frame = append(frame, ASTMChecksum(body)...)
+	frame = append(frame, ASTM_CR, ASTM_LF)
+
+	return frame
+}
+
+// DecodeASTMFrame validates a frame received after STX, i.e.
+// FN text ETB|ETX C1 C2 CR LF, and returns its parts.
+func DecodeASTMFrame(frame []byte) (number byte, text []byte, final bool, err error) {
+	size := len(frame)
+	if size < 6 || frame[size-2] != ASTM_CR || frame[size-1] != ASTM_LF {
+		return 0, nil, false, errors.ERROR_INVALID_ASTM_FRAME
+	}
+
+	terminator := frame[size-5]
+	if terminator != ASTM_ETB && terminator != ASTM_ETX {
+		return 0, nil, false, errors.ERROR_INVALID_ASTM_FRAME
+	}
+	if frame[0] < '0' || frame[0] > '7' {
+		return 0, nil, false, errors.ERROR_INVALID_ASTM_FRAME
+	}
+
+	body := frame[:size-4]
+	if !bytes.EqualFold([]byte(ASTMChecksum(body)), frame[size-4:size-2]) {
+		return 0, nil, false, errors.Wrapf(errors.ERROR_INVALID_ASTM_FRAME, "checksum %s", frame[size-4:size-2])
+	}
+
+	return frame[0] - '0', frame[1 : size-5], terminator == ASTM_ETX, nil
+}
@@ -0,0 +1,452 @@
+package listeners
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+)
+
+const (
+	DEFAULT_ASTM_MAX_MESSAGE_SIZE = 1 << 20
+	// frames are retransmitted at most six times before the sender aborts
+	ASTM_MAX_RETRANSMISSION = 6
+)
+
+type (
+	// ASTMHandler receives every completed message, called sequentially in
+	// arrival order.
+	ASTMHandler func(message []byte)
+
+	// ASTMTimeouts are the E1381 timers. Defaults follow the standard.
+	ASTMTimeouts struct {
+		// Reply is how long a sender waits for ACK/NAK after ENQ or a frame
+		Reply time.Duration
+		// Receive is how long a receiver waits for the next frame or EOT
+		Receive time.Duration
+		// Busy is how long a sender waits after NAK on ENQ before retrying
+		Busy time.Duration
+		// Contention is how long the LIS yields after both sides sent ENQ
+		Contention time.Duration
+	}
+
+	ASTMLinkOption func(l *ASTMLink)
+
+	// ASTMLink runs the E1381 low-level protocol on a byte stream, either a
+	// TCP connection or a serial port. The LIS acts as the computer system, so
+	// it yields to the instrument on line contention.
+	ASTMLink struct {
+		rw             io.ReadWriter
+		handler        ASTMHandler
+		timeouts       ASTMTimeouts
+		maxMessageSize int
+
+		incoming chan byte
+		readErr  chan error
+		outgoing chan astmOutgoing
+		received chan []byte
+		err      error
+	}
+
+	astmOutgoing struct {
+		message []byte
+		done    chan error
+	}
+)
+
+func DefaultASTMTimeouts() ASTMTimeouts {
+	return ASTMTimeouts{
+		Reply:      15 * time.Second,
+		Receive:    30 * time.Second,
+		Busy:       10 * time.Second,
+		Contention: 20 * time.Second,
+	}
+}
+
+func WithASTMTimeouts(timeouts ASTMTimeouts) ASTMLinkOption {
+	return func(l *ASTMLink) {
+		l.timeouts = timeouts
+	}
+}
+
+func WithASTMMaxMessageSize(size int) ASTMLinkOption {
+	return func(l *ASTMLink) {
+		if size > 0 {
+			l.maxMessageSize = size
+		}
+	}
+}
+
+func NewASTMLink(rw io.ReadWriter, handler ASTMHandler, options ...ASTMLinkOption) *ASTMLink {
+	link := &ASTMLink{
+		rw:             rw,
+		handler:        handler,
+		timeouts:       DefaultASTMTimeouts(),
+		maxMessageSize: DEFAULT_ASTM_MAX_MESSAGE_SIZE,
+		incoming:       make(chan byte, ASTM_MAX_FRAME_SIZE),
+		readErr:        make(chan error, 1),
+		outgoing:       make(chan astmOutgoing),
+		received:       make(chan []byte, 16),
+	}
+	for _, fn := range options {
+		fn(link)
+	}
+
+	return link
+}
+
+// Run drives the link until the context is done or the stream fails. It
+// returns once every received message has been handed to the handler.
+func (l *ASTMLink) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go l.read(ctx)
+
+	// messages are handled off the link so a slow handler never delays ACKs
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for message := range l.received {
+			l.handler(message)
+		}
+	}()
+	defer func() {
+		close(l.received)
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-l.readErr:
+			return err
+		case b := <-l.incoming:
+			// anything but ENQ in the neutral state is line noise
+			if b != ASTM_ENQ {
+				continue
+			}
+			if err := l.receive(ctx); err != nil {
+				return err
+			}
+		case out := <-l.outgoing:
+			err := l.transmit(ctx, out.message)
+			out.done <- err
+			if l.err != nil {
+				return l.err
+			}
+		}
+	}
+}
+
+// Send transmits a message to the peer and waits until it was accepted or
+// the transmission was aborted.
+func (l *ASTMLink) Send(ctx context.Context, message []byte) error {
+	out := astmOutgoing{message: message, done: make(chan error, 1)}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case l.outgoing <- out:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-out.done:
+		return err
+	}
+}
+
+func (l *ASTMLink) read(ctx context.Context) {
+	reader := bufio.NewReader(l.rw)
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			l.readErr <- err
+
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case l.incoming <- b:
+		}
+	}
+}
+
+// next waits for the next byte from the peer. A stream failure is kept in
+// l.err so Run stops once the current phase is unwound.
+func (l *ASTMLink) next(ctx context.Context, timeout time.Duration) (byte, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case err := <-l.readErr:
+		l.err = err
+
+		return 0, err
+	case b := <-l.incoming:
+		return b, nil
+	case <-timer.C:
+		return 0, errors.ERROR_ASTM_TIMEOUT
+	}
+}
+
+func (l *ASTMLink) write(b ...byte) error {
+	_, err := l.rw.Write(b)
+	if err != nil {
+		l.err = err
+	}
+
+	return err
+}
+
+// receive handles one transfer phase after the peer sent ENQ, collecting
+// frames until EOT. Only stream failures are returned, protocol errors end
+// the phase and the link goes back to neutral.
+func (l *ASTMLink) receive(ctx context.Context) error {
+	if err := l.write(ASTM_ACK); err != nil {
+		return err
+	}
+
+	// a transfer starts at frame 1, until then no frame can be a resend
+	expected := byte(1)
+	accepted := false
+	message := bytes.Buffer{}
+	overflow := false
+	for {
+		b, err := l.next(ctx, l.timeouts.Receive)
+		if err != nil {
+			if errors.Is(err, errors.ERROR_ASTM_TIMEOUT) {
+				utils.Log.Errorw("astm receiver timed out, message discarded", map[string]any{"size": message.Len()})
+
+				return nil
+			}
+
+			return err
+		}
+
+		switch b {
+		case ASTM_EOT:
+			if overflow {
+				utils.Log.Errorw("astm message discarded", map[string]any{"error": errors.ERROR_MESSAGE_TOO_LARGE.Error()})
+
+				return nil
+			}
+			if message.Len() > 0 {
+				l.received <- message.Bytes()
+			}
+
+			return nil
+
+		case ASTM_ENQ:
+			// sender restarted the establishment phase
+			expected = 1
+			accepted = false
+			message.Reset()
+			if err := l.write(ASTM_ACK); err != nil {
+				return err
+			}
+
+		case ASTM_STX:
+			frame, err := l.readFrame(ctx)
+			if err != nil {
+				if errors.Is(err, errors.ERROR_ASTM_TIMEOUT) {
+					utils.Log.Errorw("astm receiver timed out, message discarded", map[string]any{"size": message.Len()})
+
+					return nil
+				}
+				if l.err != nil {
+					return err
+				}
+				// oversized frame, let the sender retransmit
+				if err := l.write(ASTM_NAK); err != nil {
+					return err
+				}
+				continue
+			}
+
+			number, text, _, err := DecodeASTMFrame(frame)
+			switch {
+			case err != nil:
+				err = l.write(ASTM_NAK)
+			case accepted && number == (expected+7)%8:
+				// our ACK got lost and the previous frame was resent
+				err = l.write(ASTM_ACK)
+			case number != expected:
+				err = l.write(ASTM_NAK)
+			default:
+				if message.Len()+len(text) > l.maxMessageSize {
+					overflow = true
+					message.Reset()
+				}
+				if !overflow {
+					message.Write(text)
+				}
+				expected = (expected + 1) % 8
+				accepted = true
+				err = l.write(ASTM_ACK)
+			}
+			if err != nil {
+				return err
+			}
+
+		default:
+			// noise between frames
+		}
+	}
+}
+
+// readFrame reads the bytes following STX up to and including LF.
+func (l *ASTMLink) readFrame(ctx context.Context) ([]byte, error) {
+	frame := make([]byte, 0, ASTM_MAX_FRAME_SIZE)
+	for {
+		b, err := l.next(ctx, l.timeouts.Receive)
+		if err != nil {
+			return nil, err
+		}
+		frame = append(frame, b)
+		if b == ASTM_LF {
+			return frame, nil
+		}
+		if len(frame) > ASTM_MAX_FRAME_SIZE {
+			// drain the rest of the frame before answering
+			for b != ASTM_LF {
+				if b, err = l.next(ctx, l.timeouts.Receive); err != nil {
+					return nil, err
+				}
+			}
+
+			return nil, errors.ERROR_INVALID_ASTM_FRAME
+		}
+	}
+}
+
+// transmit sends a message through the establishment, transfer and
+// termination phases.
+func (l *ASTMLink) transmit(ctx context.Context, message []byte) error {
+	if err := l.establish(ctx); err != nil {
+		return err
+	}
+
+	for _, frame := range BuildASTMFrames(message) {
+		if err := l.transmitFrame(ctx, frame); err != nil {
+			if l.err == nil {
+				l.write(ASTM_EOT)
+			}
+
+			return err
+		}
+	}
+
+	return l.write(ASTM_EOT)
+}
+
+func (l *ASTMLink) establish(ctx context.Context) error {
+	for attempt := 0; ; attempt++ {
+		if attempt > ASTM_MAX_RETRANSMISSION {
+			return errors.ERROR_ASTM_LINK_BUSY
+		}
+		if err := l.write(ASTM_ENQ); err != nil {
+			return err
+		}
+
+		b, err := l.awaitReply(ctx)
+		if err != nil {
+			if errors.Is(err, errors.ERROR_ASTM_TIMEOUT) {
+				l.write(ASTM_EOT)
+			}
+
+			return err
+		}
+
+		switch b {
+		case ASTM_ACK:
+			return nil
+		case ASTM_NAK:
+			// receiver is busy
+			if err := l.wait(ctx, l.timeouts.Busy); err != nil {
+				return err
+			}
+		case ASTM_ENQ:
+			// contention, the instrument has priority
+			if err := l.receive(ctx); err != nil {
+				return err
+			}
+			if err := l.wait(ctx, l.timeouts.Contention); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (l *ASTMLink) transmitFrame(ctx context.Context, frame []byte) error {
+	for attempt := 0; ; attempt++ {
+		if attempt > ASTM_MAX_RETRANSMISSION {
+			return errors.ERROR_ASTM_RETRANSMISSION
+		}
+		if err := l.write(frame...); err != nil {
+			return err
+		}
+
+		b, err := l.awaitReply(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch b {
+		case ASTM_ACK:
+			return nil
+		case ASTM_EOT:
+			// receiver interrupt request, the frame itself was accepted
+			return nil
+		default:
+			// NAK or garbage, retransmit
+		}
+	}
+}
+
+// awaitReply waits for ACK, NAK, EOT or ENQ, skipping anything else.
+func (l *ASTMLink) awaitReply(ctx context.Context) (byte, error) {
+	deadline := time.Now().Add(l.timeouts.Reply)
+	for {
+		b, err := l.next(ctx, time.Until(deadline))
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ASTM_ACK, ASTM_NAK, ASTM_EOT, ASTM_ENQ:
+			return b, nil
+		}
+	}
+}
+
+func (l *ASTMLink) wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-l.readErr:
+		l.err = err
+
+		return err
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,445 @@
+package listeners
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+var testASTMTimeouts = ASTMTimeouts{
+	Reply:      time.Second,
+	Receive:    time.Second,
+	Busy:       50 * time.Millisecond,
+	Contention: 50 * time.Millisecond,
+}
+
+// socketPair returns both ends of a loopback TCP connection, the first one
+// for the link under test and the second one for the simulated analyzer.
+func socketPair(t *testing.T) (net.Conn, net.Conn) {
+	// the link logs protocol errors
+	utils.NewZap()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		accepted <- conn
+	}()
+
+	peer, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	local := <-accepted
+	t.Cleanup(func() {
+		local.Close()
+		peer.Close()
+	})
+
+	return local, peer
+}
+
+// astmPeer simulates the analyzer side of the link.
+type astmPeer struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newASTMPeer(t *testing.T, conn net.Conn) *astmPeer {
+	return &astmPeer{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+func (p *astmPeer) write(b ...byte) {
+	_, err := p.conn.Write(b)
+	assert.NoError(p.t, err)
+}
+
+func (p *astmPeer) expect(expected byte) {
+	p.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	b, err := p.reader.ReadByte()
+	assert.NoError(p.t, err)
+	assert.Equal(p.t, expected, b)
+}
+
+// readFrame reads a complete frame including STX.
+func (p *astmPeer) readFrame() []byte {
+	p.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frame, err := p.reader.ReadBytes(ASTM_LF)
+	assert.NoError(p.t, err)
+
+	return frame
+}
+
+type astmReceived struct {
+	mx       sync.Mutex
+	messages []string
+	done     chan struct{}
+}
+
+func newASTMReceived() *astmReceived {
+	return &astmReceived{done: make(chan struct{}, 16)}
+}
+
+func (r *astmReceived) handle(message []byte) {
+	r.mx.Lock()
+	r.messages = append(r.messages, string(message))
+	r.mx.Unlock()
+	r.done <- struct{}{}
+}
+
+func (r *astmReceived) wait(t *testing.T) []string {
+	select {
+	case <-r.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("message was not delivered")
+	}
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	return append([]string{}, r.messages...)
+}
+
+const astmLongResult = "R|1|^^^COMMENT|"
+
+func astmTestMessage() string {
+	// the result record is longer than a frame and must be split
+	return "H|\\^&|||CA-660|||||LIS||P|1\r" +
+		"P|1||PAT-1\r" +
+		"O|1|SPC-1||^^^PT\r" +
+		astmLongResult + strings.Repeat("x", 300) + "\r" +
+		"L|1|N\r"
+}
+
+func TestASTMChecksum(t *testing.T) {
+	// 0x31+0x48+0x7C+0x5C+0x5E+0x26+0x0D+0x03 = 0x1E5
+	assert.Equal(t, "E5", ASTMChecksum(append([]byte("1H|\\^&\r"), ASTM_ETX)))
+	assert.Equal(t, "00", ASTMChecksum(nil))
+}
+
+func TestBuildASTMFrames(t *testing.T) {
+	frames := BuildASTMFrames([]byte(astmTestMessage()))
+	// H, P, O, R split in two, L
+	assert.Len(t, frames, 6)
+
+	message := bytes.Buffer{}
+	for i, frame := range frames {
+		assert.Equal(t, ASTM_STX, frame[0])
+		assert.LessOrEqual(t, len(frame), ASTM_MAX_FRAME_SIZE)
+
+		number, text, final, err := DecodeASTMFrame(frame[1:])
+		assert.NoError(t, err)
+		assert.Equal(t, byte((i+1)%8), number)
+		// only the first part of the long result record is intermediate
+		assert.Equal(t, i != 3, final)
+		message.Write(text)
+	}
+	assert.Equal(t, astmTestMessage(), message.String())
+
+	// frame numbers wrap after 7
+	many := BuildASTMFrames([]byte(strings.Repeat("R|1\r", 9)))
+	assert.Equal(t, byte('0'), many[7][1])
+	assert.Equal(t, byte('1'), many[8][1])
+}
+
+func TestDecodeASTMFrame(t *testing.T) {
+	frame := BuildASTMFrames([]byte("H|\\^&\r"))[0][1:]
+
+	corrupted := append([]byte{}, frame...)
+	corrupted[3] = 'X'
+	for _, invalid := range [][]byte{nil, []byte("1\x03\r\n"), corrupted, append([]byte{'9'}, frame[1:]...)} {
+		_, _, _, err := DecodeASTMFrame(invalid)
+		assert.True(t, errors.Is(err, errors.ERROR_INVALID_ASTM_FRAME))
+	}
+
+	// lower case checksum characters are accepted
+	lower := bytes.Replace(frame, []byte(ASTMChecksum(frame[:len(frame)-4])), bytes.ToLower([]byte(ASTMChecksum(frame[:len(frame)-4]))), 1)
+	_, text, final, err := DecodeASTMFrame(lower)
+	assert.NoError(t, err)
+	assert.True(t, final)
+	assert.Equal(t, "H|\\^&\r", string(text))
+}
+
+func TestASTMLinkReceive(t *testing.T) {
+	local, remote := socketPair(t)
+	received := newASTMReceived()
+	link := NewASTMLink(local, received.handle, WithASTMTimeouts(testASTMTimeouts))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go link.Run(ctx)
+
+	peer := newASTMPeer(t, remote)
+	frames := BuildASTMFrames([]byte(astmTestMessage()))
+
+	peer.write(ASTM_ENQ)
+	peer.expect(ASTM_ACK)
+
+	// a transfer starts at frame 1, frame 0 is not the resend of anything
+	peer.write(buildASTMFrame(0, []byte("H|\\^&\r"), ASTM_ETX)...)
+	peer.expect(ASTM_NAK)
+
+	// corrupted checksum is rejected and retransmitted
+	corrupted := append([]byte{}, frames[0]...)
+	corrupted[len(corrupted)-3] ^= 0x01
+	peer.write(corrupted...)
+	peer.expect(ASTM_NAK)
+
+	for i, frame := range frames {
+		peer.write(frame...)
+		peer.expect(ASTM_ACK)
+
+		// lost ACK, the analyzer resends the same frame
+		if i == 1 {
+			peer.write(frame...)
+			peer.expect(ASTM_ACK)
+		}
+	}
+
+	// out of sequence frame number
+	peer.write(BuildASTMFrames([]byte("C|1\r"))[0]...)
+	peer.expect(ASTM_NAK)
+
+	peer.write(ASTM_EOT)
+	assert.Equal(t, []string{astmTestMessage()}, received.wait(t))
+}
+
+func TestASTMLinkReceiveTimeout(t *testing.T) {
+	local, remote := socketPair(t)
+	received := newASTMReceived()
+	link := NewASTMLink(local, received.handle, WithASTMTimeouts(ASTMTimeouts{
+		Reply:   time.Second,
+		Receive: 100 * time.Millisecond,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go link.Run(ctx)
+
+	peer := newASTMPeer(t, remote)
+	frames := BuildASTMFrames([]byte(astmTestMessage()))
+
+	// analyzer goes silent mid transfer, the partial message is dropped
+	peer.write(ASTM_ENQ)
+	peer.expect(ASTM_ACK)
+	peer.write(frames[0]...)
+	peer.expect(ASTM_ACK)
+	time.Sleep(200 * time.Millisecond)
+
+	// the link is back in neutral and accepts the next transfer
+	peer.write(ASTM_ENQ)
+	peer.expect(ASTM_ACK)
+	for _, frame := range BuildASTMFrames([]byte("H|\\^&\rL|1|N\r")) {
+		peer.write(frame...)
+		peer.expect(ASTM_ACK)
+	}
+	peer.write(ASTM_EOT)
+
+	assert.Equal(t, []string{"H|\\^&\rL|1|N\r"}, received.wait(t))
+}
+
+func TestASTMLinkSend(t *testing.T) {
+	local, remote := socketPair(t)
+	link := NewASTMLink(local, func([]byte) {}, WithASTMTimeouts(testASTMTimeouts))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go link.Run(ctx)
+
+	peer := newASTMPeer(t, remote)
+	sent := make(chan error, 1)
+	go func() {
+		sent <- link.Send(ctx, []byte(astmTestMessage()))
+	}()
+
+	// busy analyzer first, then accepts
+	peer.expect(ASTM_ENQ)
+	peer.write(ASTM_NAK)
+	peer.expect(ASTM_ENQ)
+	peer.write(ASTM_ACK)
+
+	message := bytes.Buffer{}
+	for i := 0; i < 6; i++ {
+		frame := peer.readFrame()
+		// reject the first frame once, it must be retransmitted as is
+		if i == 0 {
+			peer.write(ASTM_NAK)
+			assert.Equal(t, frame, peer.readFrame())
+		}
+
+		_, text, _, err := DecodeASTMFrame(frame[1:])
+		assert.NoError(t, err)
+		message.Write(text)
+		peer.write(ASTM_ACK)
+	}
+	peer.expect(ASTM_EOT)
+
+	assert.NoError(t, <-sent)
+	assert.Equal(t, astmTestMessage(), message.String())
+}
+
+func TestASTMLinkSendRetransmissionLimit(t *testing.T) {
+	local, remote := socketPair(t)
+	link := NewASTMLink(local, func([]byte) {}, WithASTMTimeouts(testASTMTimeouts))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go link.Run(ctx)
+
+	peer := newASTMPeer(t, remote)
+	sent := make(chan error, 1)
+	go func() {
+		sent <- link.Send(ctx, []byte("H|\\^&\r"))
+	}()
+
+	peer.expect(ASTM_ENQ)
+	peer.write(ASTM_ACK)
+	for i := 0; i <= ASTM_MAX_RETRANSMISSION; i++ {
+		peer.readFrame()
+		peer.write(ASTM_NAK)
+	}
+	peer.expect(ASTM_EOT)
+
+	assert.True(t, errors.Is(<-sent, errors.ERROR_ASTM_RETRANSMISSION))
+}
+
+func TestASTMLinkContention(t *testing.T) {
+	local, remote := socketPair(t)
+	received := newASTMReceived()
+	link := NewASTMLink(local, received.handle, WithASTMTimeouts(testASTMTimeouts))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go link.Run(ctx)
+
+	peer := newASTMPeer(t, remote)
+	sent := make(chan error, 1)
+	go func() {
+		sent <- link.Send(ctx, []byte("H|\\^&\rQ|1|^SPC-1\rL|1|N\r"))
+	}()
+
+	// both sides bid for the line, the LIS must yield to the analyzer
+	peer.expect(ASTM_ENQ)
+	peer.write(ASTM_ENQ)
+	peer.expect(ASTM_ACK)
+	for _, frame := range BuildASTMFrames([]byte(astmTestMessage())) {
+		peer.write(frame...)
+		peer.expect(ASTM_ACK)
+	}
+	peer.write(ASTM_EOT)
+	assert.Equal(t, []string{astmTestMessage()}, received.wait(t))
+
+	// after the contention delay the LIS bids again
+	peer.expect(ASTM_ENQ)
+	peer.write(ASTM_ACK)
+	for i := 0; i < 3; i++ {
+		peer.readFrame()
+		peer.write(ASTM_ACK)
+	}
+	peer.expect(ASTM_EOT)
+	assert.NoError(t, <-sent)
+}
+
+func TestASTMServer(t *testing.T) {
+	utils.NewZap()
+	svc := &mockDeviceMessageService{}
+	server := NewASTMServer(configurations.ASTM{
+		DeviceID:       "default-device",
+		DeviceTypeCode: "coagulation",
+		Peers:          map[string]string{"127.0.0.1": "coagulation-1"},
+	}, svc, WithASTMTimeouts(testASTMTimeouts))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	peer := newASTMPeer(t, conn)
+	peer.write(ASTM_ENQ)
+	peer.expect(ASTM_ACK)
+	for _, frame := range BuildASTMFrames([]byte(astmTestMessage())) {
+		peer.write(frame...)
+		peer.expect(ASTM_ACK)
+	}
+	peer.write(ASTM_EOT)
+
+	assert.Eventually(t, func() bool {
+		return len(svc.received()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	input := svc.received()[0]
+	assert.Equal(t, "coagulation-1", input.DeviceID)
+	assert.Equal(t, "coagulation", input.DeviceTypeCode)
+	assert.Equal(t, models.PROTOCOL_ASTM, input.Protocol)
+	assert.Equal(t, astmTestMessage(), input.Message)
+
+	conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx))
+	assert.True(t, errors.Is(<-served, errors.ERROR_LISTENER_CLOSED))
+}
+
+func TestASTMClient(t *testing.T) {
+	utils.NewZap()
+	svc := &mockDeviceMessageService{}
+
+	// the analyzer is the TCP server in client mode
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	connector := NewASTMClient(configurations.ASTM{
+		Address:           listener.Addr().String(),
+		DeviceID:          "chemistry-1",
+		ReconnectInterval: 50 * time.Millisecond,
+	}, svc, WithASTMTimeouts(testASTMTimeouts))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- connector.Run(ctx)
+	}()
+
+	// first connection drops, the connector dials again
+	conn, err := listener.Accept()
+	assert.NoError(t, err)
+	conn.Close()
+	conn, err = listener.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	peer := newASTMPeer(t, conn)
+	peer.write(ASTM_ENQ)
+	peer.expect(ASTM_ACK)
+	for _, frame := range BuildASTMFrames([]byte("H|\\^&\rL|1|N\r")) {
+		peer.write(frame...)
+		peer.expect(ASTM_ACK)
+	}
+	peer.write(ASTM_EOT)
+
+	assert.Eventually(t, func() bool {
+		return len(svc.received()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "chemistry-1", svc.received()[0].DeviceID)
+
+	cancel()
+	assert.True(t, errors.Is(<-stopped, context.Canceled))
+}
@@ -0,0 +1,156 @@
+package listeners
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	MLLP_START_BLOCK     byte = 0x0B
+	MLLP_END_BLOCK       byte = 0x1C
+	MLLP_CARRIAGE_RETURN byte = 0x0D
+
+	DEFAULT_MLLP_MAX_MESSAGE_SIZE = 1 << 20
+)
+
+// ReadMLLPFrame reads a single 0x0B ... 0x1C 0x0D framed message and returns
+// its payload. Bytes received before the start block are discarded.
+func ReadMLLPFrame(r *bufio.Reader, maxSize int) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = DEFAULT_MLLP_MAX_MESSAGE_SIZE
+	}
+
+	// skip noise until start block
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == MLLP_START_BLOCK {
+			break
+		}
+	}
+
+	payload := []byte{}
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.ERROR_INCOMPLETE_FRAME
+			}
+
+			return nil, err
+		}
+
+		switch b {
+		case MLLP_END_BLOCK:
+			// trailing carriage return is expected, but tolerate peers omitting it
+			next, err := r.ReadByte()
+			if err == nil && next != MLLP_CARRIAGE_RETURN {
+				r.UnreadByte()
+			}
+
+			return payload, nil
+		case MLLP_START_BLOCK:
+			// peer restarted the frame, drop partial payload
+			payload = payload[:0]
+		default:
+			if len(payload) >= maxSize {
+				return nil, errors.ERROR_MESSAGE_TOO_LARGE
+			}
+			payload = append(payload, b)
+		}
+	}
+}
+
+// WriteMLLPFrame wraps the payload in MLLP start and end blocks.
+func WriteMLLPFrame(w io.Writer, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+3)
+	frame = append(frame, MLLP_START_BLOCK)
+	frame = append(frame, payload...)
+	frame = append(frame, MLLP_END_BLOCK, MLLP_CARRIAGE_RETURN)
+
+	_, err := w.Write(frame)
+
+	return err
+}
+
+type MLLPServer struct {
+	*connServer
+	config               configurations.MLLP
+	deviceMessageService ports.DeviceMessageService
+}
+
+func NewMLLPServer(config configurations.MLLP, deviceMessageService ports.DeviceMessageService) *MLLPServer {
+	server := &MLLPServer{
+		config:               config,
+		deviceMessageService: deviceMessageService,
+	}
+	server.connServer = newConnServer(config.MaxConnection, server.handle)
+
+	return server
+}
+
+func (s *MLLPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	deviceID := s.config.PeerDevice(host)
+	reader := bufio.NewReader(conn)
+
+	for {
+		if s.config.ReadTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.config.ReadTimeout))
+		}
+
+		payload, err := ReadMLLPFrame(reader, s.config.MaxMessageSize)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !s.isClosed() {
+				utils.Log.Errorw("failed to read mllp frame", map[string]any{
+					"peer":  conn.RemoteAddr().String(),
+					"error": err.Error(),
+				})
+			}
+
+			return
+		}
+
+		ctx := utils.SetRequestID(context.Background(), uuid.NewString())
+		output, err := s.deviceMessageService.Process(ctx, &models.DeviceMessageInput{
+			DeviceID:       deviceID,
+			DeviceTypeCode: s.config.DeviceTypeCode,
+			Message:        string(payload),
+			Protocol:       models.PROTOCOL_HL7,
+		})
+		if err != nil {
+			utils.Log.Errorw("failed to process mllp message", map[string]any{
+				"peer":      conn.RemoteAddr().String(),
+				"device_id": deviceID,
+				"error":     err.Error(),
+			})
+		}
+
+		// reply with the acknowledgement so the analyzer does not retransmit
+		if output == nil || output.Acknowledgement == "" {
+			continue
+		}
+		if err := WriteMLLPFrame(conn, []byte(output.Acknowledgement)); err != nil {
+			utils.Log.Errorw("failed to write mllp acknowledgement", map[string]any{
+				"peer":  conn.RemoteAddr().String(),
+				"error": err.Error(),
+			})
+
+			return
+		}
+	}
+}
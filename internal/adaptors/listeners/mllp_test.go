@@ -0,0 +1,134 @@
+package listeners
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockDeviceMessageService struct {
+	mx     sync.Mutex
+	inputs []models.DeviceMessageInput
+}
+
+func (m *mockDeviceMessageService) Process(ctx context.Context, inputs *models.DeviceMessageInput) (*models.DeviceMessageOutput, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.inputs = append(m.inputs, *inputs)
+
+	return &models.DeviceMessageOutput{Acknowledgement: "MSH|^~\\&|ACK\rMSA|AA|1\r"}, nil
+}
+
+func (m *mockDeviceMessageService) received() []models.DeviceMessageInput {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return append([]models.DeviceMessageInput{}, m.inputs...)
+}
+
+func TestReadMLLPFrame(t *testing.T) {
+	t.Run("single frame", func(t *testing.T) {
+		reader := bufio.NewReader(strings.NewReader("\x0bMSH|^~\\&|LAB\rPID|1\r\x1c\x0d"))
+		payload, err := ReadMLLPFrame(reader, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "MSH|^~\\&|LAB\rPID|1\r", string(payload))
+	})
+
+	t.Run("noise before start block and missing carriage return", func(t *testing.T) {
+		reader := bufio.NewReader(strings.NewReader("garbage\x0bfirst\x1c\x0bsecond\x1c\x0d"))
+		payload, err := ReadMLLPFrame(reader, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", string(payload))
+
+		payload, err = ReadMLLPFrame(reader, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", string(payload))
+	})
+
+	t.Run("incomplete frame", func(t *testing.T) {
+		reader := bufio.NewReader(strings.NewReader("\x0bMSH|^~\\&|LAB"))
+		_, err := ReadMLLPFrame(reader, 0)
+		assert.True(t, errors.Is(err, errors.ERROR_INCOMPLETE_FRAME))
+	})
+
+	t.Run("message too large", func(t *testing.T) {
+		reader := bufio.NewReader(strings.NewReader("\x0b0123456789\x1c\x0d"))
+		_, err := ReadMLLPFrame(reader, 5)
+		assert.True(t, errors.Is(err, errors.ERROR_MESSAGE_TOO_LARGE))
+	})
+}
+
+func TestWriteMLLPFrame(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := WriteMLLPFrame(buf, []byte("MSH|^~\\&|LAB"))
+	assert.NoError(t, err)
+	assert.Equal(t, "\x0bMSH|^~\\&|LAB\x1c\x0d", buf.String())
+}
+
+func TestMLLPServer(t *testing.T) {
+	utils.NewZap()
+
+	svc := &mockDeviceMessageService{}
+	server := NewMLLPServer(configurations.MLLP{
+		DeviceID:       "default-device",
+		DeviceTypeCode: "analyzer",
+		Peers:          map[string]string{"127.0.0.1": "loopback-device"},
+		MaxConnection:  4,
+	}, svc)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+
+	// concurrent analyzers sending multiple frames each
+	wg := sync.WaitGroup{}
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			reader := bufio.NewReader(conn)
+			for range 2 {
+				assert.NoError(t, WriteMLLPFrame(conn, []byte("MSH|^~\\&|LAB\r")))
+				ack, err := ReadMLLPFrame(reader, 0)
+				assert.NoError(t, err)
+				assert.Equal(t, "MSH|^~\\&|ACK\rMSA|AA|1\r", string(ack))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Eventually(t, func() bool {
+		return len(svc.received()) == 6
+	}, 2*time.Second, 10*time.Millisecond)
+
+	for _, input := range svc.received() {
+		assert.Equal(t, "loopback-device", input.DeviceID)
+		assert.Equal(t, "analyzer", input.DeviceTypeCode)
+		assert.Equal(t, models.PROTOCOL_HL7, input.Protocol)
+		assert.Equal(t, "MSH|^~\\&|LAB\r", input.Message)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	assert.NoError(t, server.Shutdown(ctx))
+	assert.True(t, errors.Is(<-served, errors.ERROR_LISTENER_CLOSED))
+}
@@ -0,0 +1,188 @@
+package listeners
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/Calmantara/lis-backend/internal/adaptors/bootstrap"
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func RunMLLP(cmd *cobra.Command, args []string) {
+	bootstrap.Load()
+
+	// os channel
+	stopChan := bootstrap.SignalChan(cmd)
+	// dig dependency injection
+	digger := bootstrap.Digger(cmd)
+	bootstrap.NewInjector(digger)
+
+	var server *MLLPServer
+	err := digger.Invoke(func(deviceMessageService ports.DeviceMessageService) {
+		server = NewMLLPServer(configurations.Config.MLLP, deviceMessageService)
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	port := fmt.Sprintf(":%d", configurations.Config.MLLP.Port)
+	listener, err := net.Listen("tcp", port)
+	if err != nil {
+		panic(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		// Wait for interrupt signal to gracefully shut down the listener
+		<-stopChan
+		err := bootstrap.GracefulShutdown(server.Shutdown)
+		utils.Log.Infow("gracefully shutting down the mllp listener", map[string]any{"error": err})
+		// messages in flight are done, nothing is published anymore
+		bootstrap.Close(digger)
+	}()
+
+	utils.Log.Infow("starting MLLP listener", map[string]any{
+		"port":             port,
+		"device_id":        configurations.Config.MLLP.DeviceID,
+		"device_type_code": configurations.Config.MLLP.DeviceTypeCode,
+	})
+	if err := server.Serve(listener); err != nil && !errors.Is(err, errors.ERROR_LISTENER_CLOSED) {
+		panic(err)
+	}
+	<-stopped
+}
+
+func RunASTM(cmd *cobra.Command, args []string) {
+	bootstrap.Load()
+
+	// os channel
+	stopChan := bootstrap.SignalChan(cmd)
+	// dig dependency injection
+	digger := bootstrap.Digger(cmd)
+	bootstrap.NewInjector(digger)
+
+	config := configurations.Config.ASTM
+	var deviceMessageService ports.DeviceMessageService
+	err := digger.Invoke(func(svc ports.DeviceMessageService) {
+		deviceMessageService = svc
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	utils.Log.Infow("starting ASTM link", map[string]any{
+		"mode":             config.Mode,
+		"device_id":        config.DeviceID,
+		"device_type_code": config.DeviceTypeCode,
+	})
+
+	var connector *ASTMConnector
+	switch config.Mode {
+	case ASTM_MODE_CLIENT:
+		connector = NewASTMClient(config, deviceMessageService)
+	case ASTM_MODE_SERIAL:
+		connector = NewASTMSerial(config, deviceMessageService)
+	}
+
+	if connector != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			// Wait for interrupt signal to stop the link
+			<-stopChan
+			cancel()
+			utils.Log.Infow("gracefully shutting down the astm link")
+		}()
+		if err := connector.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			panic(err)
+		}
+		bootstrap.Close(digger)
+
+		return
+	}
+
+	server := NewASTMServer(config, deviceMessageService)
+	port := fmt.Sprintf(":%d", config.Port)
+	listener, err := net.Listen("tcp", port)
+	if err != nil {
+		panic(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		// Wait for interrupt signal to gracefully shut down the listener
+		<-stopChan
+		err := bootstrap.GracefulShutdown(server.Shutdown)
+		utils.Log.Infow("gracefully shutting down the astm listener", map[string]any{"error": err})
+		// messages in flight are done, nothing is published anymore
+		bootstrap.Close(digger)
+	}()
+	if err := server.Serve(listener); err != nil && !errors.Is(err, errors.ERROR_LISTENER_CLOSED) {
+		panic(err)
+	}
+	<-stopped
+}
+
+func RunSerial(cmd *cobra.Command, args []string) {
+	bootstrap.Load()
+
+	// os channel
+	stopChan := bootstrap.SignalChan(cmd)
+	// dig dependency injection
+	digger := bootstrap.Digger(cmd)
+	bootstrap.NewInjector(digger)
+
+	config := configurations.Config.Serial
+	devices, err := config.DeviceList()
+	if err != nil {
+		panic(err)
+	}
+
+	readers := make([]*SerialReader, 0, len(devices))
+	err = digger.Invoke(func(deviceMessageService ports.DeviceMessageService) error {
+		for _, device := range devices {
+			reader, err := NewSerialReader(config, device, deviceMessageService)
+			if err != nil {
+				return err
+			}
+			readers = append(readers, reader)
+		}
+
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// Wait for interrupt signal to close the serial ports
+		<-stopChan
+		cancel()
+		utils.Log.Infow("gracefully shutting down the serial readers")
+	}()
+
+	var wg sync.WaitGroup
+	for _, reader := range readers {
+		utils.Log.Infow("starting serial reader", map[string]any{
+			"port":             reader.device.Port,
+			"device_id":        reader.device.DeviceID,
+			"device_type_code": reader.device.DeviceTypeCode,
+			"protocol":         reader.device.Protocol,
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reader.Run(ctx)
+		}()
+	}
+	wg.Wait()
+	bootstrap.Close(digger)
+}
@@ -0,0 +1,197 @@
+package listeners
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	DEFAULT_SERIAL_IDLE_TIMEOUT       = 2 * time.Second
+	DEFAULT_SERIAL_RECONNECT_INTERVAL = 10 * time.Second
+
+	serialReadBufferSize = 4096
+)
+
+// SerialReader reads one serial instrument. Plain rs232 devices are framed
+// with the boundary rules of their device type, astm devices run the E1381
+// link on the port instead.
+type SerialReader struct {
+	config               configurations.Serial
+	device               configurations.SerialDevice
+	boundary             configurations.SerialBoundary
+	deviceMessageService ports.DeviceMessageService
+	open                 func() (io.ReadWriteCloser, error)
+}
+
+func NewSerialReader(config configurations.Serial, device configurations.SerialDevice, deviceMessageService ports.DeviceMessageService) (*SerialReader, error) {
+	boundary, err := config.Boundary(device.DeviceTypeCode)
+	if err != nil {
+		return nil, err
+	}
+	if boundary.IdleTimeout <= 0 {
+		boundary.IdleTimeout = DEFAULT_SERIAL_IDLE_TIMEOUT
+	}
+
+	return &SerialReader{
+		config:               config,
+		device:               device,
+		boundary:             boundary,
+		deviceMessageService: deviceMessageService,
+		open: func() (io.ReadWriteCloser, error) {
+			return openSerialPort(device)
+		},
+	}, nil
+}
+
+// Run keeps the port open until the context is cancelled.
+func (r *SerialReader) Run(ctx context.Context) error {
+	interval := r.config.ReconnectInterval
+	if interval <= 0 {
+		interval = DEFAULT_SERIAL_RECONNECT_INTERVAL
+	}
+
+	return keepConnected(ctx, interval, r.device.DeviceID, r.runOnce)
+}
+
+func (r *SerialReader) runOnce(ctx context.Context) error {
+	stream, err := r.open()
+	if err != nil {
+		return err
+	}
+
+	// closing the port unblocks the reader on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		stream.Close()
+	}()
+
+	if r.device.Protocol == configurations.SERIAL_PROTOCOL_ASTM {
+		link := NewASTMLink(stream, processASTM(r.deviceMessageService, r.device.DeviceID, r.device.DeviceTypeCode),
+			WithASTMMaxMessageSize(r.config.MaxMessageSize))
+
+		return link.Run(ctx)
+	}
+
+	return r.frame(ctx, stream)
+}
+
+// frame reads the port and hands every complete message to the service. A
+// message still buffered when the port drops is processed as it is.
+func (r *SerialReader) frame(ctx context.Context, stream io.Reader) error {
+	chunks := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		buffer := make([]byte, serialReadBufferSize)
+		for {
+			n, err := stream.Read(buffer)
+			if n > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case chunks <- append([]byte(nil), buffer[:n]...):
+				}
+			}
+			if err != nil {
+				readErr <- err
+
+				return
+			}
+		}
+	}()
+
+	// messages are processed off the reader so a slow service never stalls it
+	received := make(chan []byte, 16)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for message := range received {
+			r.process(message)
+		}
+	}()
+
+	framer := NewSerialFramer(r.boundary, r.config.MaxMessageSize)
+	defer func() {
+		if message := framer.Flush(); message != nil {
+			received <- message
+		}
+		close(received)
+		wg.Wait()
+	}()
+
+	idle := time.NewTimer(r.boundary.IdleTimeout)
+	idle.Stop()
+	defer idle.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErr:
+			return err
+		case chunk := <-chunks:
+			for _, message := range framer.Feed(chunk) {
+				received <- message
+			}
+			idle.Stop()
+			if framer.Pending() {
+				idle.Reset(r.boundary.IdleTimeout)
+			}
+		case <-idle.C:
+			if message := framer.Flush(); message != nil {
+				received <- message
+			}
+		}
+	}
+}
+
+func (r *SerialReader) process(message []byte) {
+	ctx := utils.SetRequestID(context.Background(), uuid.NewString())
+	_, err := r.deviceMessageService.Process(ctx, &models.DeviceMessageInput{
+		DeviceID:       r.device.DeviceID,
+		DeviceTypeCode: r.device.DeviceTypeCode,
+		Message:        string(message),
+		Protocol:       models.PROTOCOL_RS232,
+	})
+	if err != nil {
+		utils.Log.Errorw("failed to process serial message", map[string]any{
+			"device_id": r.device.DeviceID,
+			"error":     err.Error(),
+		})
+	}
+}
+
+// keepConnected runs a connection until the context is cancelled, starting
+// it again after interval whenever it drops.
+func keepConnected(ctx context.Context, interval time.Duration, deviceID string, run func(ctx context.Context) error) error {
+	for {
+		err := run(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		utils.Log.Errorw("device link dropped, reconnecting", map[string]any{
+			"device_id": deviceID,
+			"error":     err.Error(),
+			"interval":  interval.String(),
+		})
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package listeners
+
+import (
+	"bytes"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+)
+
+const DEFAULT_SERIAL_MAX_MESSAGE_SIZE = 1 << 20
+
+// SerialFramer cuts the byte stream of a serial instrument into messages. A
+// terminator byte or a header line ends the current message right away, the
+// idle timeout is left to the caller through Flush.
+type SerialFramer struct {
+	boundary       configurations.SerialBoundary
+	maxMessageSize int
+
+	buffer    []byte
+	lineStart int
+	overflow  bool
+}
+
+func NewSerialFramer(boundary configurations.SerialBoundary, maxMessageSize int) *SerialFramer {
+	if maxMessageSize <= 0 {
+		maxMessageSize = DEFAULT_SERIAL_MAX_MESSAGE_SIZE
+	}
+
+	return &SerialFramer{
+		boundary:       boundary,
+		maxMessageSize: maxMessageSize,
+	}
+}
+
+// Feed appends received bytes and returns the messages they completed.
+func (f *SerialFramer) Feed(data []byte) [][]byte {
+	var messages [][]byte
+	for _, b := range data {
+		if f.boundary.Terminator != 0 && b == f.boundary.Terminator {
+			messages = appendMessage(messages, f.cut(len(f.buffer)))
+			continue
+		}
+
+		f.buffer = append(f.buffer, b)
+		if b == '\n' || b == '\r' {
+			f.lineStart = len(f.buffer)
+			continue
+		}
+
+		// a header line opens the next message, everything before it is done
+		header := f.boundary.Header
+		if header != "" && (f.lineStart > 0 || f.overflow) &&
+			len(f.buffer)-f.lineStart == len(header) && string(f.buffer[f.lineStart:]) == header {
+			messages = appendMessage(messages, f.cut(f.lineStart))
+		}
+
+		if len(f.buffer) > f.maxMessageSize {
+			// keep dropping until the next boundary
+			f.overflow = true
+			f.buffer = f.buffer[:0]
+			f.lineStart = 0
+		}
+	}
+
+	return messages
+}
+
+// Flush ends the buffered message, used when the line went idle.
+func (f *SerialFramer) Flush() []byte {
+	return trimMessage(f.cut(len(f.buffer)))
+}
+
+// Pending tells whether bytes are waiting for a boundary.
+func (f *SerialFramer) Pending() bool {
+	return len(f.buffer) > 0 || f.overflow
+}
+
+func (f *SerialFramer) cut(n int) []byte {
+	message := bytes.Clone(f.buffer[:n])
+	f.buffer = append(f.buffer[:0], f.buffer[n:]...)
+	f.lineStart = max(f.lineStart-n, 0)
+
+	if f.overflow {
+		f.overflow = false
+		utils.Log.Errorw("serial message discarded", map[string]any{"error": errors.ERROR_MESSAGE_TOO_LARGE.Error()})
+
+		return nil
+	}
+
+	return message
+}
+
+func appendMessage(messages [][]byte, message []byte) [][]byte {
+	if message = trimMessage(message); message != nil {
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+// trimMessage drops the blank lines and padding around a message.
+func trimMessage(message []byte) []byte {
+	message = bytes.Trim(message, " \t\r\n\x00")
+	if len(message) == 0 {
+		return nil
+	}
+
+	return message
+}
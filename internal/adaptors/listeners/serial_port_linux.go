@@ -0,0 +1,96 @@
+//go:build linux
+
+package listeners
+
+import (
+	"io"
+	"os"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"golang.org/x/sys/unix"
+)
+
+var serialBaudRates = map[int]uint32{
+	1200:   unix.B1200,
+	2400:   unix.B2400,
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+}
+
+var serialDataBits = map[int]uint32{
+	5: unix.CS5,
+	6: unix.CS6,
+	7: unix.CS7,
+	8: unix.CS8,
+}
+
+// openSerialPort opens a tty in raw mode with the device line settings. The
+// termios are set here instead of through go.bug.st/serial, which always
+// turns RTS/CTS and XON/XOFF off, because instruments on Linux hosts need
+// flow control. The descriptor is non-blocking so closing the file unblocks
+// a pending read.
+func openSerialPort(device configurations.SerialDevice) (io.ReadWriteCloser, error) {
+	baudRate, ok := serialBaudRates[device.BaudRate]
+	if !ok {
+		return nil, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "unsupported baud rate %d", device.BaudRate)
+	}
+	dataBits, ok := serialDataBits[device.DataBits]
+	if !ok {
+		return nil, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "unsupported data bits %d", device.DataBits)
+	}
+
+	fd, err := unix.Open(device.Port, unix.O_RDWR|unix.O_NOCTTY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "open serial port %s", device.Port)
+	}
+
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		unix.Close(fd)
+
+		return nil, errors.Wrapf(err, "read settings of %s", device.Port)
+	}
+
+	// raw mode, the same as cfmakeraw
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON | unix.IXOFF | unix.IXANY
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	termios.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CRTSCTS | unix.CBAUD
+	termios.Cflag |= unix.CREAD | unix.CLOCAL | dataBits | baudRate
+	termios.Ispeed = baudRate
+	termios.Ospeed = baudRate
+	termios.Cc[unix.VMIN] = 1
+	termios.Cc[unix.VTIME] = 0
+
+	switch device.Parity {
+	case configurations.SERIAL_PARITY_ODD:
+		termios.Cflag |= unix.PARENB | unix.PARODD
+		termios.Iflag |= unix.INPCK
+	case configurations.SERIAL_PARITY_EVEN:
+		termios.Cflag |= unix.PARENB
+		termios.Iflag |= unix.INPCK
+	}
+	if device.StopBits == 2 {
+		termios.Cflag |= unix.CSTOPB
+	}
+	switch device.FlowControl {
+	case configurations.SERIAL_FLOW_RTSCTS:
+		termios.Cflag |= unix.CRTSCTS
+	case configurations.SERIAL_FLOW_XONXOFF:
+		termios.Iflag |= unix.IXON | unix.IXOFF
+	}
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		unix.Close(fd)
+
+		return nil, errors.Wrapf(err, "apply settings to %s", device.Port)
+	}
+
+	return os.NewFile(uintptr(fd), device.Port), nil
+}
@@ -0,0 +1,133 @@
+//go:build linux
+
+package listeners
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+// ptyPair opens a pseudo-terminal, the controller plays the instrument and
+// the reader opens the returned device path as its serial port.
+func ptyPair(t *testing.T) (*os.File, string) {
+	utils.NewZap()
+
+	controller, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	t.Cleanup(func() { controller.Close() })
+
+	// unlock the device and look its number up, as posix_openpt callers do
+	// with unlockpt and ptsname
+	fd := int(controller.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		t.Skipf("pseudo-terminal cannot be unlocked: %v", err)
+	}
+	number, err := unix.IoctlGetUint32(fd, unix.TIOCGPTN)
+	if err != nil {
+		t.Skipf("pseudo-terminal has no number: %v", err)
+	}
+	path := "/dev/pts/" + strconv.Itoa(int(number))
+
+	// the device stays open so the controller never reads a hang up
+	// between the ports the reader opens
+	device, err := os.OpenFile(path, os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminal %s cannot be opened: %v", path, err)
+	}
+	t.Cleanup(func() { device.Close() })
+
+	return controller, path
+}
+
+func waitReceived(t *testing.T, service *mockDeviceMessageService, count int) []models.DeviceMessageInput {
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if received := service.received(); len(received) >= count {
+			return received
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d messages, got %d", count, len(service.received()))
+
+	return nil
+}
+
+func TestSerialReader(t *testing.T) {
+	controller, port := ptyPair(t)
+
+	config := configurations.Serial{
+		Boundaries:        map[string]string{"urine": "header:NO.;idle:200ms"},
+		ReconnectInterval: 50 * time.Millisecond,
+	}
+	device := configurations.SerialDevice{
+		DeviceID:       "urine-1",
+		DeviceTypeCode: "urine",
+		Port:           port,
+		BaudRate:       9600,
+		DataBits:       8,
+		Parity:         configurations.SERIAL_PARITY_NONE,
+		StopBits:       1,
+		FlowControl:    configurations.SERIAL_FLOW_NONE,
+		Protocol:       configurations.SERIAL_PROTOCOL_RS232,
+	}
+
+	// apply raw mode before writing, the pty would otherwise translate line
+	// endings of bytes arriving before the reader opened it
+	settings, err := openSerialPort(device)
+	assert.NoError(t, err)
+	settings.Close()
+
+	service := &mockDeviceMessageService{}
+	reader, err := NewSerialReader(config, device, service)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- reader.Run(ctx)
+	}()
+
+	// the second header ends the first result, the idle timeout the second
+	_, err = controller.Write([]byte("NO.001\r\nLEU NEG\r\n"))
+	assert.NoError(t, err)
+	_, err = controller.Write([]byte("NO.002\r\nLEU 1+\r\n"))
+	assert.NoError(t, err)
+
+	received := waitReceived(t, service, 2)
+	assert.Equal(t, "NO.001\r\nLEU NEG", received[0].Message)
+	assert.Equal(t, "NO.002\r\nLEU 1+", received[1].Message)
+	for _, input := range received {
+		assert.Equal(t, "urine-1", input.DeviceID)
+		assert.Equal(t, "urine", input.DeviceTypeCode)
+		assert.Equal(t, models.PROTOCOL_RS232, input.Protocol)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(2 * time.Second):
+		t.Fatal("serial reader did not stop")
+	}
+}
+
+func TestOpenSerialPortInvalidSettings(t *testing.T) {
+	_, port := ptyPair(t)
+
+	_, err := openSerialPort(configurations.SerialDevice{Port: port, BaudRate: 12345, DataBits: 8})
+	assert.Error(t, err)
+
+	_, err = openSerialPort(configurations.SerialDevice{Port: "/dev/does-not-exist", BaudRate: 9600, DataBits: 8})
+	assert.Error(t, err)
+}
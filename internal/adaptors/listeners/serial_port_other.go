@@ -0,0 +1,41 @@
+//go:build !linux
+
+package listeners
+
+import (
+	"io"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"go.bug.st/serial"
+)
+
+var serialParities = map[string]serial.Parity{
+	configurations.SERIAL_PARITY_NONE: serial.NoParity,
+	configurations.SERIAL_PARITY_ODD:  serial.OddParity,
+	configurations.SERIAL_PARITY_EVEN: serial.EvenParity,
+}
+
+// openSerialPort opens the port with go.bug.st/serial, which always turns
+// flow control off, so ports that need it are only served on Linux.
+func openSerialPort(device configurations.SerialDevice) (io.ReadWriteCloser, error) {
+	if device.FlowControl != "" && device.FlowControl != configurations.SERIAL_FLOW_NONE {
+		return nil, errors.Wrapf(errors.ERROR_SERIAL_UNSUPPORTED, "flow control %s of %s needs linux", device.FlowControl, device.Port)
+	}
+
+	stopBits := serial.OneStopBit
+	if device.StopBits == 2 {
+		stopBits = serial.TwoStopBits
+	}
+	port, err := serial.Open(device.Port, &serial.Mode{
+		BaudRate: device.BaudRate,
+		DataBits: device.DataBits,
+		Parity:   serialParities[device.Parity],
+		StopBits: stopBits,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "open serial port %s", device.Port)
+	}
+
+	return port, nil
+}
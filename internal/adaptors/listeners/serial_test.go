@@ -0,0 +1,52 @@
+package listeners
+
+import (
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func feedAll(framer *SerialFramer, chunks ...string) []string {
+	var messages []string
+	for _, chunk := range chunks {
+		for _, message := range framer.Feed([]byte(chunk)) {
+			messages = append(messages, string(message))
+		}
+	}
+
+	return messages
+}
+
+func TestSerialFramerTerminator(t *testing.T) {
+	framer := NewSerialFramer(configurations.SerialBoundary{Terminator: 0x03}, 0)
+
+	messages := feedAll(framer, "first\r\nmess", "age\x03\r\nsecond\x03third")
+	assert.Equal(t, []string{"first\r\nmessage", "second"}, messages)
+	assert.True(t, framer.Pending())
+	assert.Equal(t, "third", string(framer.Flush()))
+	assert.False(t, framer.Pending())
+	assert.Nil(t, framer.Flush())
+}
+
+func TestSerialFramerHeader(t *testing.T) {
+	framer := NewSerialFramer(configurations.SerialBoundary{Header: "NO."}, 0)
+
+	// the header only counts at the start of a line
+	messages := feedAll(framer,
+		"\r\nNO.001\r\nLEU NEG\r\nREF NO.7\r\n",
+		"N", "O.002\r\nLEU 1+\r\n",
+	)
+	assert.Equal(t, []string{"NO.001\r\nLEU NEG\r\nREF NO.7"}, messages)
+	assert.Equal(t, "NO.002\r\nLEU 1+", string(framer.Flush()))
+}
+
+func TestSerialFramerOverflow(t *testing.T) {
+	// the framer logs the discarded message
+	utils.NewZap()
+	framer := NewSerialFramer(configurations.SerialBoundary{Terminator: '\n'}, 8)
+
+	messages := feedAll(framer, "0123456789abcdef", "rest\nok\n")
+	assert.Equal(t, []string{"ok"}, messages)
+}
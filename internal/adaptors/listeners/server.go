@@ -0,0 +1,132 @@
+package listeners
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// connServer accepts connections and tracks them for a graceful shutdown,
+// protocol specific handling is left to the handle function.
+type connServer struct {
+	handle func(conn net.Conn)
+
+	mx       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	slots    chan struct{}
+	wg       sync.WaitGroup
+	closed   bool
+}
+
+func newConnServer(maxConnection int, handle func(conn net.Conn)) *connServer {
+	server := &connServer{
+		handle: handle,
+		conns:  map[net.Conn]struct{}{},
+	}
+	if maxConnection > 0 {
+		server.slots = make(chan struct{}, maxConnection)
+	}
+
+	return server
+}
+
+// Serve accepts connections on the listener until Shutdown is called.
+// Every connection is handled on its own goroutine.
+func (s *connServer) Serve(listener net.Listener) error {
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+
+		return errors.ERROR_LISTENER_CLOSED
+	}
+	s.listener = listener
+	s.mx.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return errors.ERROR_LISTENER_CLOSED
+			}
+
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+
+			return err
+		}
+
+		// limit concurrent connections when configured
+		if s.slots != nil {
+			s.slots <- struct{}{}
+		}
+
+		s.track(conn, true)
+		s.wg.Add(1)
+		go func() {
+			defer func() {
+				s.track(conn, false)
+				if s.slots != nil {
+					<-s.slots
+				}
+				s.wg.Done()
+			}()
+			s.handle(conn)
+		}()
+	}
+}
+
+// Shutdown stops accepting connections and waits for in-flight messages.
+// Remaining connections are closed once the context expires.
+func (s *connServer) Shutdown(ctx context.Context) error {
+	s.mx.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.mx.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mx.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mx.Unlock()
+		<-done
+
+		return ctx.Err()
+	}
+}
+
+func (s *connServer) track(conn net.Conn, add bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if add {
+		s.conns[conn] = struct{}{}
+
+		return
+	}
+	delete(s.conns, conn)
+}
+
+func (s *connServer) isClosed() bool {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	return s.closed
+}
@@ -0,0 +1,156 @@
+package publishers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQP_CONFIRM_TIMEOUT bounds the wait for the broker confirm when the
+// context has no deadline of its own
+const AMQP_CONFIRM_TIMEOUT = 5 * time.Second
+
+// AMQPPublisher publishes to a RabbitMQ exchange, the destination url is
+// amqp://<user>:<password>@<host>:<port>/<vhost>?exchange=<exchange>&routing_key=<key>.
+// The routing key defaults to the destination name. Every message waits for
+// the broker confirm.
+type AMQPPublisher struct {
+	mx       sync.Mutex
+	channels map[string]*amqpChannel
+	now      func() time.Time
+}
+
+type amqpChannel struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+func NewAMQPPublisher() ports.ResultPublisher {
+	return &AMQPPublisher{
+		channels: map[string]*amqpChannel{},
+		now:      time.Now,
+	}
+}
+
+func (a *AMQPPublisher) Schemes() []string {
+	return []string{"amqp", "amqps"}
+}
+
+func (a *AMQPPublisher) Publish(ctx context.Context, destination configurations.Destination, entry models.OutboxEntry) error {
+	address, vhost, err := brokerTarget(destination)
+	if err != nil {
+		return err
+	}
+	query := address.Query()
+	exchange, routingKey := query.Get("exchange"), query.Get("routing_key")
+	if routingKey == "" {
+		routingKey = destination.Name
+	}
+	address.RawQuery = ""
+	address.Path = "/" + vhost
+
+	channel, err := a.channel(address.String())
+	if err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "amqp connect: %v", err)
+	}
+
+	headers := amqp.Table{}
+	for key, value := range messageHeaders(destination, entry) {
+		headers[key] = value
+	}
+	publishing := amqp.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    a.now(),
+		Headers:      headers,
+		Body:         []byte(entry.Payload),
+	}
+	if entry.ID != nil {
+		publishing.MessageId = entry.ID.String()
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, AMQP_CONFIRM_TIMEOUT)
+		defer cancel()
+	}
+	confirm, err := channel.channel.PublishWithDeferredConfirmWithContext(ctx, exchange, routingKey, false, false, publishing)
+	if err != nil {
+		a.drop(address.String(), channel)
+
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "amqp publish: %v", err)
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "amqp confirm: %v", err)
+	}
+	if !acked {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "amqp nack on exchange %q", exchange)
+	}
+
+	return nil
+}
+
+// channel returns the open confirm channel of the url, dialing it again once
+// the connection dropped.
+func (a *AMQPPublisher) channel(address string) (*amqpChannel, error) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	if channel, ok := a.channels[address]; ok {
+		if !channel.channel.IsClosed() {
+			return channel, nil
+		}
+		// a channel error such as an unknown exchange closes the channel only
+		channel.conn.Close()
+		delete(a.channels, address)
+	}
+
+	conn, err := amqp.Dial(address)
+	if err != nil {
+		return nil, err
+	}
+	channel, err := conn.Channel()
+	if err == nil {
+		err = channel.Confirm(false)
+	}
+	if err != nil {
+		conn.Close()
+
+		return nil, err
+	}
+	a.channels[address] = &amqpChannel{conn: conn, channel: channel}
+
+	return a.channels[address], nil
+}
+
+func (a *AMQPPublisher) drop(address string, channel *amqpChannel) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	if a.channels[address] == channel {
+		delete(a.channels, address)
+	}
+	channel.conn.Close()
+}
+
+// Close closes every connection along with its channel.
+func (a *AMQPPublisher) Close() (err error) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	for address, channel := range a.channels {
+		if closeErr := channel.conn.Close(); err == nil && closeErr != nil && !errors.Is(closeErr, amqp.ErrClosed) {
+			err = errors.Wrapf(closeErr, "amqp connection %s", channel.conn.RemoteAddr())
+		}
+		delete(a.channels, address)
+	}
+
+	return err
+}
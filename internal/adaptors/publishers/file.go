@@ -0,0 +1,93 @@
+package publishers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// spoolRecord is one line of a spool file.
+type spoolRecord struct {
+	MessageID       string          `json:"message_id,omitempty"`
+	Destination     string          `json:"destination"`
+	DeviceMessageID string          `json:"device_message_id"`
+	PublishedAt     time.Time       `json:"published_at"`
+	Payload         json.RawMessage `json:"payload"`
+}
+
+// FilePublisher appends results as JSON lines to a local spool file, the
+// destination url is file:///<path>. The file is reopened for every line so
+// it can be rotated or picked up by a shipper at any time.
+type FilePublisher struct {
+	mx  sync.Mutex
+	now func() time.Time
+}
+
+func NewFilePublisher() ports.ResultPublisher {
+	return &FilePublisher{now: time.Now}
+}
+
+func (f *FilePublisher) Schemes() []string {
+	return []string{"file"}
+}
+
+// Close has nothing to release, the spool file is closed after every line.
+func (f *FilePublisher) Close() error {
+	return nil
+}
+
+func (f *FilePublisher) Publish(ctx context.Context, destination configurations.Destination, entry models.OutboxEntry) error {
+	u, err := url.Parse(destination.Url)
+	if err != nil || u.Path == "" {
+		return errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s has no path", destination.Name)
+	}
+
+	// the payload is compacted so a result edited on replay stays one line
+	payload := bytes.Buffer{}
+	if err := json.Compact(&payload, []byte(entry.Payload)); err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "payload is not JSON: %v", err)
+	}
+	record := spoolRecord{
+		Destination:     destination.Name,
+		DeviceMessageID: entry.DeviceMessageID,
+		PublishedAt:     f.now(),
+		Payload:         payload.Bytes(),
+	}
+	if entry.ID != nil {
+		record.MessageID = entry.ID.String()
+	}
+	line, _ := json.Marshal(record)
+
+	f.mx.Lock()
+	defer f.mx.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(u.Path), 0o750); err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "spool directory: %v", err)
+	}
+	file, err := os.OpenFile(u.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "spool open: %v", err)
+	}
+	defer file.Close()
+
+	// one write per line keeps lines whole when several processes append
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "spool write: %v", err)
+	}
+
+	return nil
+}
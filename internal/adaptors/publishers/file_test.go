@@ -0,0 +1,56 @@
+package publishers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilePublisher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spool", "results.jsonl")
+	destination := configurations.Destination{Name: "archive", Url: "file://" + path}
+	publisher := NewFilePublisher()
+
+	id := uuid.New()
+	assert.NoError(t, publisher.Publish(context.Background(), destination, models.OutboxEntry{
+		ID:              &id,
+		DeviceMessageID: "message-1",
+		Payload:         "{\n  \"device_id\": \"hema-1\"\n}",
+	}))
+	assert.NoError(t, publisher.Publish(context.Background(), destination, models.OutboxEntry{
+		DeviceMessageID: "message-2",
+		Payload:         `[{"device_id":"urine-1"}]`,
+	}))
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	records := []spoolRecord{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record spoolRecord
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	assert.Len(t, records, 2)
+	assert.Equal(t, id.String(), records[0].MessageID)
+	assert.Equal(t, "archive", records[0].Destination)
+	assert.Equal(t, "message-1", records[0].DeviceMessageID)
+	assert.JSONEq(t, `{"device_id":"hema-1"}`, string(records[0].Payload))
+	assert.Empty(t, records[1].MessageID)
+	assert.JSONEq(t, `[{"device_id":"urine-1"}]`, string(records[1].Payload))
+
+	// a payload that is not JSON would break the spool
+	err = publisher.Publish(context.Background(), destination, models.OutboxEntry{Payload: "NO.0012"})
+	assert.ErrorIs(t, err, errors.ERROR_DELIVERY_FAILED)
+}
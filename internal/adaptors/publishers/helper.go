@@ -0,0 +1,67 @@
+package publishers
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	// RESULT_PUBLISHER_GROUP collects the publishers the outbox dispatcher
+	// picks from by destination url scheme
+	RESULT_PUBLISHER_GROUP = "resultPublishers"
+
+	HEADER_MESSAGE_ID        = "message_id"
+	HEADER_DESTINATION       = "destination"
+	HEADER_DEVICE_MESSAGE_ID = "device_message_id"
+)
+
+func setHTTPClient() (client *resty.Client) {
+	// Create a new Resty client with retry configuration
+	client = resty.New()
+
+	// Configure retry settings
+	client.
+		SetRetryCount(3).                      // Number of retries
+		SetRetryWaitTime(5 * time.Second).     // Wait time between retries
+		SetRetryMaxWaitTime(10 * time.Second). // Maximum wait time
+		AddRetryCondition(                     // Custom retry condition
+			func(r *resty.Response, err error) bool {
+				return r.StatusCode() == 429 || r.StatusCode() >= 500
+			},
+		)
+
+	return
+}
+
+// brokerTarget splits a broker destination url into the address to dial,
+// without the path and query, and the path naming the topic or subject.
+func brokerTarget(destination configurations.Destination) (address *url.URL, target string, err error) {
+	u, err := url.Parse(destination.Url)
+	if err != nil {
+		return nil, "", errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s url", destination.Name)
+	}
+	target = strings.Trim(u.Path, "/")
+	address = &url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host, RawQuery: u.RawQuery}
+
+	return address, target, nil
+}
+
+// messageHeaders are sent with every broker message so consumers can drop
+// duplicates and find the stored device message.
+func messageHeaders(destination configurations.Destination, entry models.OutboxEntry) map[string]string {
+	headers := map[string]string{
+		HEADER_DESTINATION:       destination.Name,
+		HEADER_DEVICE_MESSAGE_ID: entry.DeviceMessageID,
+	}
+	if entry.ID != nil {
+		headers[HEADER_MESSAGE_ID] = entry.ID.String()
+	}
+
+	return headers
+}
@@ -0,0 +1,97 @@
+package publishers
+
+import (
+	"context"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/helpers/signature"
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+)
+
+// destinationContext carries the destination of a request to its signing.
+type destinationContext struct{}
+
+// HTTPPublisher posts the payload to the destination url with its auth.
+type HTTPPublisher struct {
+	client            *resty.Client
+	lisPlatformConfig configurations.LisPlatform
+	now               func() time.Time
+}
+
+func NewHTTPPublisher(lisPlatform configurations.LisPlatform) ports.ResultPublisher {
+	// the outbox owns the retries, one attempt is one request
+	publisher := &HTTPPublisher{
+		client:            setHTTPClient().SetRetryCount(0).SetTimeout(10 * time.Second),
+		lisPlatformConfig: lisPlatform,
+		now:               time.Now,
+	}
+	// every request is signed again
+	publisher.client.OnBeforeRequest(publisher.sign)
+
+	return publisher
+}
+
+func (h *HTTPPublisher) Schemes() []string {
+	return []string{"http", "https"}
+}
+
+// Publish posts the payload, the idempotency key stays the same across
+// retries of an entry so the destination can drop duplicates.
+func (h *HTTPPublisher) Publish(ctx context.Context, destination configurations.Destination, entry models.OutboxEntry) error {
+	req := h.client.R().
+		SetContext(context.WithValue(ctx, destinationContext{}, destination)).
+		SetHeader("Accept", "application/json").
+		SetHeader("Content-Type", "application/json").
+		SetBody(entry.Payload)
+	if entry.ID != nil {
+		req.SetHeader(signature.HEADER_IDEMPOTENCY_KEY, entry.ID.String())
+	}
+	switch destination.Auth.Scheme {
+	case configurations.DESTINATION_AUTH_BEARER:
+		req.SetAuthToken(destination.Auth.Token)
+	case configurations.DESTINATION_AUTH_BASIC:
+		req.SetBasicAuth(destination.Auth.Username, destination.Auth.Password)
+	}
+
+	resp, err := req.Post(destination.Url)
+	if err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "post: %v", err)
+	}
+	if resp.IsError() {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "status %d", resp.StatusCode())
+	}
+
+	return nil
+}
+
+func (h *HTTPPublisher) Close() error {
+	h.client.GetClient().CloseIdleConnections()
+
+	return nil
+}
+
+// sign signs the body for hmac destinations, the nonce is new on every
+// attempt so the destination can reject replayed requests.
+func (h *HTTPPublisher) sign(_ *resty.Client, req *resty.Request) error {
+	destination, _ := req.Context().Value(destinationContext{}).(configurations.Destination)
+	if destination.Auth.Scheme != configurations.DESTINATION_AUTH_HMAC {
+		return nil
+	}
+
+	body, _ := req.Body.(string)
+	signature.SetHeaders(
+		req.Header,
+		destination.Auth.KeyID,
+		h.lisPlatformConfig.SigningSecrets[destination.Auth.KeyID],
+		h.now(),
+		uuid.New().String(),
+		[]byte(body),
+	)
+
+	return nil
+}
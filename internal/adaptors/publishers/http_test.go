@@ -0,0 +1,102 @@
+package publishers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/helpers/signature"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPPublisherSigning(t *testing.T) {
+	secrets := map[string]string{"lis-1": "secret"}
+	var mx sync.Mutex
+	headers := []http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mx.Lock()
+		headers = append(headers, r.Header.Clone())
+		attempt := len(headers)
+		mx.Unlock()
+
+		if err := signature.Verify(r.Header, body, secrets, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// the platform fails twice before it takes the result
+		if attempt < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	publisher := NewHTTPPublisher(configurations.LisPlatform{SigningSecrets: secrets})
+	destination := configurations.Destination{
+		Name: configurations.DESTINATION_LIS_PLATFORM,
+		Url:  server.URL,
+		Auth: configurations.DestinationAuth{Scheme: configurations.DESTINATION_AUTH_HMAC, KeyID: "lis-1"},
+	}
+	id := uuid.New()
+	entry := models.OutboxEntry{ID: &id, Payload: `{"device_id":"hema-1"}`}
+
+	// the outbox owns the retries, every attempt is a single request
+	for attempt := 1; attempt <= 3; attempt++ {
+		err := publisher.Publish(context.Background(), destination, entry)
+		if attempt < 3 {
+			assert.ErrorIs(t, err, errors.ERROR_DELIVERY_FAILED)
+		} else {
+			assert.NoError(t, err)
+		}
+		assert.Len(t, headers, attempt)
+	}
+
+	// every attempt carries the same idempotency key and a fresh nonce
+	nonces := map[string]bool{}
+	for _, header := range headers {
+		assert.Equal(t, id.String(), header.Get(signature.HEADER_IDEMPOTENCY_KEY))
+		assert.Equal(t, "lis-1", header.Get(signature.HEADER_KEY_ID))
+		nonces[header.Get(signature.HEADER_NONCE)] = true
+	}
+	assert.Len(t, nonces, 3)
+}
+
+func TestHTTPPublisherAuth(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		if r.URL.Path == "/rejects" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	publisher := NewHTTPPublisher(configurations.LisPlatform{})
+	entry := models.OutboxEntry{Payload: "{}"}
+
+	assert.NoError(t, publisher.Publish(context.Background(), configurations.Destination{
+		Name: "lake",
+		Url:  server.URL,
+		Auth: configurations.DestinationAuth{Scheme: configurations.DESTINATION_AUTH_BEARER, Token: "lake-token"},
+	}, entry))
+	assert.Equal(t, "Bearer lake-token", header.Get("Authorization"))
+	assert.Empty(t, header.Get(signature.HEADER_SIGNATURE))
+
+	assert.NoError(t, publisher.Publish(context.Background(), configurations.Destination{
+		Name: "qc",
+		Url:  server.URL,
+		Auth: configurations.DestinationAuth{Scheme: configurations.DESTINATION_AUTH_BASIC, Username: "qc", Password: "secret"},
+	}, entry))
+	assert.Equal(t, "Basic cWM6c2VjcmV0", header.Get("Authorization"))
+
+	err := publisher.Publish(context.Background(), configurations.Destination{Name: "lake", Url: server.URL + "/rejects"}, entry)
+	assert.ErrorIs(t, err, errors.ERROR_DELIVERY_FAILED)
+}
@@ -0,0 +1,38 @@
+package publishers
+
+import (
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"go.uber.org/dig"
+)
+
+type ResultPublishersInput struct {
+	dig.In
+	Publishers []ports.ResultPublisher `group:"resultPublishers"`
+}
+
+func NewInjector(digger *dig.Container) {
+	// result publishers, picked by the scheme of the destination url
+	digger.Provide(NewHTTPPublisher, dig.Group(RESULT_PUBLISHER_GROUP))
+	digger.Provide(NewAMQPPublisher, dig.Group(RESULT_PUBLISHER_GROUP))
+	digger.Provide(NewKafkaPublisher, dig.Group(RESULT_PUBLISHER_GROUP))
+	digger.Provide(NewNATSPublisher, dig.Group(RESULT_PUBLISHER_GROUP))
+	digger.Provide(NewFilePublisher, dig.Group(RESULT_PUBLISHER_GROUP))
+}
+
+// Close releases the connections of the provided publishers, runners call it
+// once nothing is delivered anymore. Every publisher is closed, the first
+// failure is returned.
+func Close(digger *dig.Container) (err error) {
+	invokeErr := digger.Invoke(func(input ResultPublishersInput) {
+		for _, publisher := range input.Publishers {
+			if closeErr := publisher.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	})
+	if err == nil {
+		err = invokeErr
+	}
+
+	return err
+}
@@ -0,0 +1,93 @@
+package publishers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+// KAFKA_BATCH_TIMEOUT bounds how long a write waits for other messages to
+// batch with, every delivery is written on its own.
+const KAFKA_BATCH_TIMEOUT = 10 * time.Millisecond
+
+// KafkaPublisher writes to a Kafka topic, the destination url is
+// kafka://<bootstrap broker>:<port>/<topic>. Messages are keyed by device
+// message so the records of one message stay in order, a write waits for
+// all in-sync replicas.
+type KafkaPublisher struct {
+	mx      sync.Mutex
+	writers map[string]*kafka.Writer
+}
+
+func NewKafkaPublisher() ports.ResultPublisher {
+	return &KafkaPublisher{writers: map[string]*kafka.Writer{}}
+}
+
+func (k *KafkaPublisher) Schemes() []string {
+	return []string{"kafka"}
+}
+
+func (k *KafkaPublisher) Publish(ctx context.Context, destination configurations.Destination, entry models.OutboxEntry) error {
+	address, topic, err := brokerTarget(destination)
+	if err != nil {
+		return err
+	}
+	if topic == "" {
+		return errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s has no topic", destination.Name)
+	}
+
+	message := kafka.Message{
+		Key:   []byte(entry.DeviceMessageID),
+		Value: []byte(entry.Payload),
+	}
+	for key, value := range messageHeaders(destination, entry) {
+		message.Headers = append(message.Headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+
+	if err := k.writer(address.Host, topic).WriteMessages(ctx, message); err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "kafka write: %v", err)
+	}
+
+	return nil
+}
+
+// writer returns the writer of the topic, it reconnects on its own.
+func (k *KafkaPublisher) writer(broker, topic string) *kafka.Writer {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	key := broker + "/" + topic
+	if writer, ok := k.writers[key]; ok {
+		return writer
+	}
+	k.writers[key] = &kafka.Writer{
+		Addr:         kafka.TCP(broker),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: KAFKA_BATCH_TIMEOUT,
+	}
+
+	return k.writers[key]
+}
+
+// Close flushes and closes the writers of every topic.
+func (k *KafkaPublisher) Close() (err error) {
+	k.mx.Lock()
+	defer k.mx.Unlock()
+
+	for key, writer := range k.writers {
+		if closeErr := writer.Close(); err == nil && closeErr != nil {
+			err = errors.Wrapf(closeErr, "kafka writer %s", key)
+		}
+		delete(k.writers, key)
+	}
+
+	return err
+}
@@ -0,0 +1,62 @@
+package publishers
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaPublisher(t *testing.T) {
+	publisher := NewKafkaPublisher()
+	entry := models.OutboxEntry{DeviceMessageID: "message-1", Payload: `{"device_id":"hema-1"}`}
+
+	// a destination without topic is a configuration mistake
+	err := publisher.Publish(context.Background(), configurations.Destination{Name: "events", Url: "kafka://localhost:9092"}, entry)
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG)
+
+	broker := os.Getenv("KAFKA_TEST_BROKER")
+	if broker == "" {
+		broker = "localhost:9092"
+	}
+	conn, err := kafka.Dial("tcp", broker)
+	if err != nil {
+		t.Skipf("kafka is not available: %v", err)
+	}
+	defer conn.Close()
+	controller, err := conn.Controller()
+	assert.NoError(t, err)
+	admin, err := kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	assert.NoError(t, err)
+	defer admin.Close()
+	topic := "lis-results-" + uuid.NewString()
+	assert.NoError(t, admin.CreateTopics(kafka.TopicConfig{Topic: topic, NumPartitions: 1, ReplicationFactor: 1}))
+
+	id := uuid.New()
+	entry.ID = &id
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.NoError(t, publisher.Publish(ctx, configurations.Destination{Name: "events", Url: "kafka://" + broker + "/" + topic}, entry))
+
+	reader := kafka.NewReader(kafka.ReaderConfig{Brokers: []string{broker}, Topic: topic})
+	defer reader.Close()
+	message, err := reader.ReadMessage(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "message-1", string(message.Key))
+	assert.Equal(t, entry.Payload, string(message.Value))
+	headers := map[string]string{}
+	for _, header := range message.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	assert.Equal(t, id.String(), headers[HEADER_MESSAGE_ID])
+	assert.Equal(t, "events", headers[HEADER_DESTINATION])
+}
@@ -0,0 +1,123 @@
+package publishers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATS_FLUSH_TIMEOUT bounds the wait for the server when the context has no
+// deadline of its own
+const NATS_FLUSH_TIMEOUT = 5 * time.Second
+
+// NATSPublisher publishes to a NATS subject, the destination url is
+// nats://<user>:<password>@<host>:<port>/<subject>. With ?jetstream=true the
+// subject must belong to a stream and every message waits for its ack, the
+// message id header lets the stream drop duplicates. Otherwise the publish
+// only waits for the server to read it.
+type NATSPublisher struct {
+	mx    sync.Mutex
+	conns map[string]*nats.Conn
+}
+
+func NewNATSPublisher() ports.ResultPublisher {
+	return &NATSPublisher{conns: map[string]*nats.Conn{}}
+}
+
+func (n *NATSPublisher) Schemes() []string {
+	return []string{"nats"}
+}
+
+func (n *NATSPublisher) Publish(ctx context.Context, destination configurations.Destination, entry models.OutboxEntry) error {
+	address, subject, err := brokerTarget(destination)
+	if err != nil {
+		return err
+	}
+	if subject == "" {
+		return errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s has no subject", destination.Name)
+	}
+	useJetStream := address.Query().Get("jetstream") == "true"
+	address.RawQuery = ""
+
+	conn, err := n.conn(address.String())
+	if err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "nats connect: %v", err)
+	}
+
+	message := nats.NewMsg(subject)
+	message.Data = []byte(entry.Payload)
+	for key, value := range messageHeaders(destination, entry) {
+		message.Header.Set(key, value)
+	}
+	if entry.ID != nil {
+		message.Header.Set(jetstream.MsgIDHeader, entry.ID.String())
+	}
+
+	if useJetStream {
+		js, err := jetstream.New(conn)
+		if err == nil {
+			_, err = js.PublishMsg(ctx, message)
+		}
+		if err != nil {
+			return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "nats jetstream publish: %v", err)
+		}
+
+		return nil
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, NATS_FLUSH_TIMEOUT)
+		defer cancel()
+	}
+	err = conn.PublishMsg(message)
+	if err == nil {
+		err = conn.FlushWithContext(ctx)
+	}
+	if err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "nats publish: %v", err)
+	}
+
+	return nil
+}
+
+// conn returns the connection of the url, the client reconnects on its own
+// until the connection is closed for good.
+func (n *NATSPublisher) conn(address string) (*nats.Conn, error) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	if conn, ok := n.conns[address]; ok && !conn.IsClosed() {
+		return conn, nil
+	}
+
+	conn, err := nats.Connect(address, nats.Name("lis-backend"))
+	if err != nil {
+		return nil, err
+	}
+	n.conns[address] = conn
+
+	return conn, nil
+}
+
+// Close flushes the pending messages and closes every connection.
+func (n *NATSPublisher) Close() (err error) {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+
+	for address, conn := range n.conns {
+		if drainErr := conn.Drain(); err == nil && drainErr != nil {
+			err = errors.Wrapf(drainErr, "nats connection %s", conn.ConnectedUrlRedacted())
+		}
+		delete(n.conns, address)
+	}
+
+	return err
+}
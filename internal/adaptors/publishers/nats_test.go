@@ -0,0 +1,104 @@
+package publishers
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+)
+
+// natsConn connects to the broker at NATS_TEST_URL, the test is skipped
+// when none is reachable.
+func natsConn(t *testing.T) (*nats.Conn, string) {
+	address := os.Getenv("NATS_TEST_URL")
+	if address == "" {
+		address = nats.DefaultURL
+	}
+	conn, err := nats.Connect(address)
+	if err != nil {
+		t.Skipf("nats is not available: %v", err)
+	}
+	t.Cleanup(conn.Close)
+
+	return conn, strings.TrimSuffix(address, "/")
+}
+
+func TestNATSPublisher(t *testing.T) {
+	conn, address := natsConn(t)
+	subject := "lis.results." + uuid.NewString()
+
+	subscription, err := conn.SubscribeSync(subject)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Flush())
+
+	publisher := NewNATSPublisher()
+	id := uuid.New()
+	entry := models.OutboxEntry{ID: &id, DeviceMessageID: "message-1", Payload: `{"device_id":"hema-1"}`}
+	assert.NoError(t, publisher.Publish(context.Background(), configurations.Destination{
+		Name: "events",
+		Url:  address + "/" + subject,
+	}, entry))
+
+	message, err := subscription.NextMsg(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, entry.Payload, string(message.Data))
+	assert.Equal(t, id.String(), message.Header.Get(HEADER_MESSAGE_ID))
+	assert.Equal(t, "events", message.Header.Get(HEADER_DESTINATION))
+	assert.Equal(t, "message-1", message.Header.Get(HEADER_DEVICE_MESSAGE_ID))
+
+	// a destination without subject is a configuration mistake
+	err = publisher.Publish(context.Background(), configurations.Destination{Name: "events", Url: address}, entry)
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG)
+
+	// closing drains the cached connections, the next publish dials again
+	cached := publisher.(*NATSPublisher).conns[address]
+	assert.NoError(t, publisher.Close())
+	assert.Eventually(t, cached.IsClosed, time.Second, 10*time.Millisecond)
+	assert.NoError(t, publisher.Publish(context.Background(), configurations.Destination{
+		Name: "events",
+		Url:  address + "/" + subject,
+	}, entry))
+	assert.NoError(t, publisher.Close())
+}
+
+func TestNATSPublisherJetStream(t *testing.T) {
+	conn, address := natsConn(t)
+	name := "RESULTS_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	subject := "lis.results." + name
+
+	ctx := context.Background()
+	js, err := jetstream.New(conn)
+	assert.NoError(t, err)
+	stream, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: name, Subjects: []string{subject}})
+	if err != nil {
+		t.Skipf("jetstream is not available: %v", err)
+	}
+	t.Cleanup(func() { js.DeleteStream(context.Background(), name) })
+
+	publisher := NewNATSPublisher()
+	destination := configurations.Destination{Name: "events", Url: address + "/" + subject + "?jetstream=true"}
+	id := uuid.New()
+	entry := models.OutboxEntry{ID: &id, Payload: "{}"}
+
+	// a retried entry is stored once
+	assert.NoError(t, publisher.Publish(ctx, destination, entry))
+	assert.NoError(t, publisher.Publish(ctx, destination, entry))
+	info, err := stream.Info(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), info.State.Msgs)
+
+	// subjects outside any stream are not acknowledged
+	destination.Url = address + "/lis.unknown." + name + "?jetstream=true"
+	timeout, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	assert.ErrorIs(t, publisher.Publish(timeout, destination, entry), errors.ERROR_DELIVERY_FAILED)
+}
@@ -0,0 +1,144 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type deadLetterCommandImpl struct {
+	mysql MySql
+}
+
+func NewDeadLetterCommand(mysql MySql) ports.DeadLetterCommand {
+	return &deadLetterCommandImpl{mysql: mysql}
+}
+
+func (d *deadLetterCommandImpl) Create(ctx context.Context, deadLetter *models.DeadLetter) error {
+	ctx, txn := begin(ctx, d.mysql.Master())
+
+	err := txn.
+		WithContext(ctx).
+		Create(deadLetter).Error
+	if err != nil {
+		return errors.Wrap(err, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+
+	return nil
+}
+
+func (d *deadLetterCommandImpl) Close(ctx context.Context, deadLetter *models.DeadLetter) error {
+	ctx, txn := begin(ctx, d.mysql.Master())
+
+	res := txn.
+		WithContext(ctx).
+		Model(deadLetter).
+		Where("status = ?", models.DEAD_LETTER_STATUS_OPEN).
+		Select("status", "replayed_payload", "replayed_at", "discarded_at").
+		Updates(deadLetter)
+	if res.Error != nil {
+		return errors.Wrap(res.Error, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+	if res.RowsAffected == 0 {
+		return errors.Wrapf(errors.ERROR_DEAD_LETTER_CLOSED, "dead letter %s", deadLetter.ID)
+	}
+
+	return nil
+}
+
+func (d *deadLetterCommandImpl) Retry(ctx context.Context, deadLetter *models.DeadLetter) error {
+	ctx, txn := begin(ctx, d.mysql.Master())
+
+	res := txn.
+		WithContext(ctx).
+		Model(deadLetter).
+		Where("status = ?", models.DEAD_LETTER_STATUS_OPEN).
+		Select("attempts", "reprocessed", "error_chain").
+		Updates(deadLetter)
+	if res.Error != nil {
+		return errors.Wrap(res.Error, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+	if res.RowsAffected == 0 {
+		return errors.Wrapf(errors.ERROR_DEAD_LETTER_CLOSED, "dead letter %s", deadLetter.ID)
+	}
+
+	return nil
+}
+
+func (d *deadLetterCommandImpl) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	res := d.mysql.Master().
+		WithContext(ctx).
+		Unscoped().
+		Where("status <> ? AND updated_at < ?", models.DEAD_LETTER_STATUS_OPEN, before).
+		Limit(limit).
+		Delete(&models.DeadLetter{})
+	if res.Error != nil {
+		return 0, errors.Wrap(res.Error, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+
+	return int(res.RowsAffected), nil
+}
+
+type deadLetterQueryImpl struct {
+	mysql MySql
+}
+
+func NewDeadLetterQuery(mysql MySql) ports.DeadLetterQuery {
+	return &deadLetterQueryImpl{mysql: mysql}
+}
+
+func (d *deadLetterQueryImpl) Find(ctx context.Context, filter models.DeadLetterFilter) (deadLetters []models.DeadLetter, page models.Pagination, err error) {
+	page = filter.Pagination
+	// the session lets the count and the page share the filter
+	txn := beforeFind(d.mysql.Slave().WithContext(ctx).Model(&models.DeadLetter{}), filter).
+		Session(&gorm.Session{})
+
+	err = txn.Count(&page.TotalData).Error
+	if err == nil {
+		err = pagination(txn, page).Find(&deadLetters).Error
+	}
+	if err != nil {
+		return nil, page, errors.Wrap(err, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+	if page.Limit > 0 {
+		page.Total = (page.TotalData + int64(page.Limit) - 1) / int64(page.Limit)
+	}
+
+	return deadLetters, page, nil
+}
+
+// Get reads from the master so a replay sees the status it just changed.
+func (d *deadLetterQueryImpl) Get(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error) {
+	deadLetter := &models.DeadLetter{}
+	err := d.mysql.Master().
+		WithContext(ctx).
+		Where("id = ?", id.String()).
+		First(deadLetter).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrapf(errors.ERROR_NOT_FOUND, "dead letter %s", id)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+
+	return deadLetter, nil
+}
+
+func (d *deadLetterQueryImpl) Due(ctx context.Context, rounds int, before time.Time, limit int) (deadLetters []models.DeadLetter, err error) {
+	err = d.mysql.Master().
+		WithContext(ctx).
+		Where("status = ? AND reprocessed < ? AND updated_at <= ?", models.DEAD_LETTER_STATUS_OPEN, rounds, before).
+		Order("updated_at").
+		Limit(limit).
+		Find(&deadLetters).Error
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+
+	return deadLetters, nil
+}
@@ -16,5 +16,8 @@ func NewInjector(digger *dig.Container) {
 
 	digger.Provide(NewHealthRepo)
 	digger.Provide(NewDeviceMessageCommand)
+	digger.Provide(NewOutboxCommand)
+	digger.Provide(NewDeadLetterCommand)
+	digger.Provide(NewDeadLetterQuery)
 	digger.Provide(NewTransaction)
 }
@@ -0,0 +1,95 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type outboxCommandImpl struct {
+	mysql MySql
+}
+
+func NewOutboxCommand(mysql MySql) ports.OutboxCommand {
+	return &outboxCommandImpl{mysql: mysql}
+}
+
+func (o *outboxCommandImpl) Create(ctx context.Context, entries []models.OutboxEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ctx, txn := begin(ctx, o.mysql.Master())
+
+	err := txn.
+		WithContext(ctx).
+		Create(&entries).Error
+	if err != nil {
+		return errors.Wrap(err, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+
+	return nil
+}
+
+func (o *outboxCommandImpl) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) (entries []models.OutboxEntry, err error) {
+	err = o.mysql.Master().WithContext(ctx).Transaction(func(txn *gorm.DB) error {
+		err := txn.
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.OUTBOX_STATUS_PENDING, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&entries).Error
+		if err != nil || len(entries) == 0 {
+			return err
+		}
+
+		ids := make([]string, 0, len(entries))
+		for i := range entries {
+			entries[i].NextAttemptAt = now.Add(lease)
+			ids = append(ids, entries[i].ID.String())
+		}
+
+		return txn.
+			Model(&models.OutboxEntry{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+
+	return entries, nil
+}
+
+func (o *outboxCommandImpl) Update(ctx context.Context, entry *models.OutboxEntry) error {
+	ctx, txn := begin(ctx, o.mysql.Master())
+
+	err := txn.
+		WithContext(ctx).
+		Model(entry).
+		Select("status", "attempts", "last_error", "next_attempt_at", "delivered_at").
+		Updates(entry).Error
+	if err != nil {
+		return errors.Wrap(err, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+
+	return nil
+}
+
+func (o *outboxCommandImpl) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	res := o.mysql.Master().
+		WithContext(ctx).
+		Unscoped().
+		Where("status = ? AND delivered_at < ?", models.OUTBOX_STATUS_DELIVERED, before).
+		Limit(limit).
+		Delete(&models.OutboxEntry{})
+	if res.Error != nil {
+		return 0, errors.Wrap(res.Error, errors.ERROR_INTERNAL_SERVER.Error())
+	}
+
+	return int(res.RowsAffected), nil
+}
@@ -0,0 +1,165 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+)
+
+const DEFAULT_WORKER_CONCURRENCY = 4
+
+type workerJob struct {
+	task   ports.WorkerTask
+	result chan int
+}
+
+// WorkerPool runs every task on its interval with at most concurrency runs in
+// flight. A task never overlaps itself, a run that processed records is
+// queued again right away so a backlog drains without waiting.
+type WorkerPool struct {
+	concurrency int
+	tasks       []ports.WorkerTask
+
+	// runs use their own context so Shutdown lets them finish first
+	runCtx   context.Context
+	abort    context.CancelFunc
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewWorkerPool(concurrency int, tasks []ports.WorkerTask) *WorkerPool {
+	if concurrency <= 0 {
+		concurrency = DEFAULT_WORKER_CONCURRENCY
+	}
+	runCtx, abort := context.WithCancel(context.Background())
+
+	return &WorkerPool{
+		concurrency: concurrency,
+		tasks:       tasks,
+		runCtx:      runCtx,
+		abort:       abort,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Run schedules the tasks until Shutdown is called and returns once the runs
+// in flight have ended.
+func (p *WorkerPool) Run() error {
+	defer close(p.done)
+	defer p.abort()
+
+	queue := make(chan workerJob)
+	var workers sync.WaitGroup
+	for range p.concurrency {
+		workers.Go(func() {
+			for job := range queue {
+				job.result <- p.run(job.task)
+			}
+		})
+	}
+
+	var schedulers sync.WaitGroup
+	for _, task := range p.tasks {
+		schedulers.Go(func() {
+			p.schedule(task, queue)
+		})
+	}
+	schedulers.Wait()
+	close(queue)
+	workers.Wait()
+
+	return nil
+}
+
+// Shutdown stops scheduling and waits for the runs in flight, they are
+// cancelled when ctx ends first.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		p.abort()
+		<-p.done
+
+		return ctx.Err()
+	}
+}
+
+// schedule queues the task at start, then every interval after its last run
+// ended, or right away when that run processed records.
+func (p *WorkerPool) schedule(task ports.WorkerTask, queue chan<- workerJob) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	result := make(chan int, 1)
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-timer.C:
+		}
+		// select picks randomly among ready cases, a stopped pool must not
+		// start another run because the timer or a worker is ready too
+		if p.stopped() {
+			return
+		}
+
+		select {
+		case <-p.stop:
+			return
+		case queue <- workerJob{task: task, result: result}:
+		}
+
+		next := task.Interval()
+		if processed := <-result; processed > 0 {
+			next = 0
+		}
+		timer.Reset(next)
+	}
+}
+
+func (p *WorkerPool) stopped() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// run reports how many records the task processed, zero when it failed so a
+// failing task waits for its interval.
+func (p *WorkerPool) run(task ports.WorkerTask) (processed int) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.logFailure(task, errors.Wrapf(errors.ERROR_WORKER_TASK_PANIC, "%v", r))
+			processed = 0
+		}
+	}()
+
+	processed, err := task.Run(p.runCtx)
+	if err != nil {
+		p.logFailure(task, err)
+
+		return 0
+	}
+
+	return processed
+}
+
+func (p *WorkerPool) logFailure(task ports.WorkerTask, err error) {
+	utils.Log.Errorw("worker task failed", map[string]any{
+		"task":  task.Name(),
+		"error": err.Error(),
+	})
+}
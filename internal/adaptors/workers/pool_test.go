@@ -0,0 +1,181 @@
+package workers
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockTask struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) (int, error)
+	runs     atomic.Int32
+}
+
+func (m *mockTask) Name() string {
+	return m.name
+}
+
+func (m *mockTask) Interval() time.Duration {
+	return m.interval
+}
+
+func (m *mockTask) Run(ctx context.Context) (int, error) {
+	m.runs.Add(1)
+
+	return m.run(ctx)
+}
+
+func startPool(concurrency int, tasks ...ports.WorkerTask) (*WorkerPool, chan error) {
+	utils.NewZap()
+	pool := NewWorkerPool(concurrency, tasks)
+	done := make(chan error, 1)
+	go func() {
+		done <- pool.Run()
+	}()
+
+	return pool, done
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met")
+}
+
+func TestWorkerPoolConcurrency(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	release := make(chan struct{})
+	tasks := []ports.WorkerTask{}
+	for _, name := range []string{"a", "b", "c"} {
+		tasks = append(tasks, &mockTask{name: name, interval: time.Hour, run: func(ctx context.Context) (int, error) {
+			current := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				old := peak.Load()
+				if current <= old || peak.CompareAndSwap(old, current) {
+					break
+				}
+			}
+			<-release
+
+			return 0, nil
+		}})
+	}
+
+	pool, done := startPool(2, tasks...)
+	waitFor(t, func() bool { return inFlight.Load() == 2 })
+	// the third task waits for a free worker
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), peak.Load())
+
+	close(release)
+	waitFor(t, func() bool {
+		runs := int32(0)
+		for _, task := range tasks {
+			runs += task.(*mockTask).runs.Load()
+		}
+		return runs == 3
+	})
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.NoError(t, <-done)
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestWorkerPoolDrain(t *testing.T) {
+	// a run that processed records is repeated until the backlog is empty,
+	// then the task waits for its interval
+	var backlog atomic.Int32
+	backlog.Store(3)
+	task := &mockTask{name: "drain", interval: time.Hour, run: func(ctx context.Context) (int, error) {
+		if backlog.Load() == 0 {
+			return 0, nil
+		}
+		return int(backlog.Add(-1)) + 1, nil
+	}}
+
+	pool, done := startPool(1, task)
+	waitFor(t, func() bool { return task.runs.Load() == 4 })
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(4), task.runs.Load())
+
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.NoError(t, <-done)
+}
+
+func TestWorkerPoolRecover(t *testing.T) {
+	var once sync.Once
+	task := &mockTask{name: "panics", interval: 10 * time.Millisecond, run: func(ctx context.Context) (int, error) {
+		once.Do(func() {
+			panic("broken")
+		})
+		return 0, nil
+	}}
+
+	// the worker survives and the task keeps its schedule
+	pool, done := startPool(1, task)
+	waitFor(t, func() bool { return task.runs.Load() >= 3 })
+
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.NoError(t, <-done)
+}
+
+func TestWorkerPoolShutdown(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	graceful := &mockTask{name: "graceful", interval: time.Hour, run: func(ctx context.Context) (int, error) {
+		close(started)
+		select {
+		case <-finish:
+			return 1, nil
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}}
+
+	// the run in flight finishes before Shutdown returns
+	pool, done := startPool(1, graceful)
+	<-started
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- pool.Shutdown(context.Background())
+	}()
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown returned before the run ended")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(finish)
+	assert.NoError(t, <-shutdown)
+	assert.NoError(t, <-done)
+	assert.Equal(t, int32(1), graceful.runs.Load())
+
+	// runs still in flight when the deadline ends are cancelled
+	var cancelled atomic.Bool
+	started = make(chan struct{})
+	stuck := &mockTask{name: "stuck", interval: time.Hour, run: func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		cancelled.Store(true)
+		return 0, ctx.Err()
+	}}
+	pool, done = startPool(1, stuck)
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+	assert.NoError(t, <-done)
+	assert.True(t, cancelled.Load())
+}
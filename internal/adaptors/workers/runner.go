@@ -0,0 +1,52 @@
+package workers
+
+import (
+	"github.com/Calmantara/lis-backend/internal/adaptors/bootstrap"
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/dig"
+)
+
+type WorkerTasksInput struct {
+	dig.In
+	Tasks []ports.WorkerTask `group:"workerTasks"`
+}
+
+func RunWorker(cmd *cobra.Command, args []string) {
+	bootstrap.Load()
+
+	// os channel
+	stopChan := bootstrap.SignalChan(cmd)
+	// dig dependency injection
+	digger := bootstrap.Digger(cmd)
+	bootstrap.NewInjector(digger)
+
+	config := configurations.Config.Worker
+	var pool *WorkerPool
+	err := digger.Invoke(func(input WorkerTasksInput) {
+		pool = NewWorkerPool(config.Concurrency, input.Tasks)
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	go func() {
+		// Wait for interrupt signal to let the running tasks finish
+		<-stopChan
+		err := bootstrap.GracefulShutdown(pool.Shutdown)
+		utils.Log.Infow("gracefully shutting down the worker", map[string]any{"error": err})
+	}()
+
+	names := make([]string, 0, len(pool.tasks))
+	for _, task := range pool.tasks {
+		names = append(names, task.Name())
+	}
+	utils.Log.Infow("starting worker", map[string]any{
+		"concurrency": pool.concurrency,
+		"tasks":       names,
+	})
+	pool.Run()
+	bootstrap.Close(digger)
+}
@@ -1,6 +1,7 @@
 package configurations
 
 import (
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -27,6 +28,19 @@ type Configuration struct {
 	Application    Application
 	JWT            JWT
 	LisPlatform    LisPlatform
+	MLLP           MLLP
+	ASTM           ASTM
+	Serial         Serial
+	HL7            HL7
+	TextParser     TextParser
+	Urine          Urine
+	Parsing        Parsing
+	Units          Units
+	Clock          Clock
+	Charset        Charset
+	Outbox         Outbox
+	Routing        Routing
+	Worker         Worker
 
 	mx sync.Mutex
 }
@@ -49,6 +63,19 @@ func Load() *Configuration {
 	config.Application.load(vp)
 	config.JWT.load(vp)
 	config.LisPlatform.load(vp)
+	config.MLLP.load(vp)
+	config.ASTM.load(vp)
+	config.Serial.load(vp)
+	config.HL7.load(vp)
+	config.TextParser.load(vp)
+	config.Urine.load(vp)
+	config.Parsing.load(vp)
+	config.Units.load(vp)
+	config.Clock.load(vp)
+	config.Charset.load(vp)
+	config.Outbox.load(vp)
+	config.Routing.load(vp)
+	config.Worker.load(vp)
 
 	config.DatabaseMaster.load(
 		WithDatabaseViper(vp),
@@ -78,6 +105,36 @@ func keyBind(input any, vp *viper.Viper) {
 	}
 }
 
+// decodeHook extends the viper default hooks so map fields can be
+// configured from a single env value such as "key1=value1,key2=value2".
+func decodeHook() viper.DecoderConfigOption {
+	return viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToWeakSliceHookFunc(","),
+		stringToMapHookFunc(",", "="),
+	))
+}
+
+func stringToMapHookFunc(sep, kvSep string) mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data any) (any, error) {
+		if f.Kind() != reflect.String || t.Kind() != reflect.Map {
+			return data, nil
+		}
+
+		res := map[string]string{}
+		for _, pair := range strings.Split(data.(string), sep) {
+			key, value, found := strings.Cut(pair, kvSep)
+			key = strings.TrimSpace(key)
+			if !found || key == "" {
+				continue
+			}
+			res[key] = strings.TrimSpace(value)
+		}
+
+		return res, nil
+	}
+}
+
 type Application struct {
 	Name        string        `mapstructure:"APP_NAME"`
 	Environment Environment   `mapstructure:"APP_ENVIRONMENT"`
@@ -115,9 +172,205 @@ func (j *JWT) load(vp *viper.Viper) {
 
 type LisPlatform struct {
 	Url string `mapstructure:"LIS_PLATFORM_URL"`
+	// SigningKeyID picks the secret requests to the LIS platform are signed
+	// with, they are sent unsigned when it is empty
+	SigningKeyID string `mapstructure:"LIS_PLATFORM_SIGNING_KEY_ID"`
+	// SigningSecrets maps a key id to its HMAC secret, e.g.
+	// "2025-12=secret1,2026-01=secret2", the retired key is kept until the
+	// platform stops accepting it so secrets can be rotated. Destinations
+	// with hmac auth are signed with these secrets too
+	SigningSecrets map[string]string `mapstructure:"LIS_PLATFORM_SIGNING_SECRETS"`
 }
 
 func (j *LisPlatform) load(vp *viper.Viper) {
 	keyBind(j, vp)
-	vp.Unmarshal(&j)
+	vp.Unmarshal(&j, decodeHook())
+}
+
+type HL7 struct {
+	// FieldMappings maps "<device type code>:<name>" to a terser style path,
+	// e.g. "analyzer:equipment_id=OBX-18,analyzer:rack=ZXX-2"
+	FieldMappings map[string]string `mapstructure:"HL7_FIELD_MAPPINGS"`
+	// RejectProcessingIDs lists MSH-11 values that are stored but never
+	// published, e.g. "T,D" in production to drop training and debug messages
+	RejectProcessingIDs []string `mapstructure:"HL7_REJECT_PROCESSING_IDS"`
+}
+
+func (h *HL7) load(vp *viper.Viper) {
+	keyBind(h, vp)
+	vp.Unmarshal(&h, decodeHook())
+}
+
+// DeviceFieldMappings returns the name to path mappings of a device type.
+func (h *HL7) DeviceFieldMappings(deviceTypeCode string) map[string]string {
+	res := map[string]string{}
+	for key, path := range h.FieldMappings {
+		code, name, found := strings.Cut(key, ":")
+		if found && code == deviceTypeCode && name != "" {
+			res[name] = path
+		}
+	}
+
+	return res
+}
+
+type TextParser struct {
+	// DefinitionDir holds the YAML or JSON text parser definitions loaded at
+	// startup, none are loaded when empty
+	DefinitionDir string `mapstructure:"TEXT_PARSER_DEFINITION_DIR"`
+}
+
+func (t *TextParser) load(vp *viper.Viper) {
+	keyBind(t, vp)
+	vp.Unmarshal(&t)
+}
+
+type Urine struct {
+	// ReferenceRanges maps a urine parameter code to its reference range,
+	// either numeric such as "ph=5-8" or the expected grade such as
+	// "leukocytes=negative"
+	ReferenceRanges map[string]string `mapstructure:"URINE_REFERENCE_RANGES"`
+}
+
+func (u *Urine) load(vp *viper.Viper) {
+	keyBind(u, vp)
+	vp.Unmarshal(&u, decodeHook())
+}
+
+type Parsing struct {
+	// StrictDevices lists the device ids whose messages are rejected when
+	// the parser reports diagnostics instead of publishing partial results,
+	// "*" makes every device strict
+	StrictDevices []string `mapstructure:"PARSING_STRICT_DEVICES"`
+}
+
+func (p *Parsing) load(vp *viper.Viper) {
+	keyBind(p, vp)
+	vp.Unmarshal(&p, decodeHook())
+}
+
+// Strict tells whether messages of the device are parsed in strict mode.
+func (p *Parsing) Strict(deviceID string) bool {
+	for _, device := range p.StrictDevices {
+		if device = strings.TrimSpace(device); device == "*" || device == deviceID {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Units struct {
+	// Aliases maps instrument unit strings to UCUM codes on top of the
+	// built-in ones, e.g. "Ery/HPF=/[HPF]"
+	Aliases map[string]string `mapstructure:"UNITS_ALIASES"`
+	// Canonical maps a parameter code to the UCUM unit its results are
+	// converted to, e.g. "glucose=mmol/L,leukocytes=/uL"
+	Canonical map[string]string `mapstructure:"UNITS_CANONICAL"`
+	// MolarMasses maps a parameter code to its molar mass in g/mol to convert
+	// between mass and substance concentrations, e.g. "glucose=180.16"
+	MolarMasses map[string]string `mapstructure:"UNITS_MOLAR_MASSES"`
+}
+
+func (u *Units) load(vp *viper.Viper) {
+	keyBind(u, vp)
+	vp.Unmarshal(&u, decodeHook())
+}
+
+type Clock struct {
+	// Timezones maps a device id to the IANA timezone of the timestamps it
+	// prints without an offset, "*" sets the default of the other devices,
+	// e.g. "urine-1=Asia/Jakarta,*=UTC"
+	Timezones map[string]string `mapstructure:"CLOCK_TIMEZONES"`
+	// SkewThreshold flags messages whose instrument time is further than
+	// this from the server receive time, zero disables the flag
+	SkewThreshold time.Duration `mapstructure:"CLOCK_SKEW_THRESHOLD"`
+}
+
+func (c *Clock) load(vp *viper.Viper) {
+	keyBind(c, vp)
+	vp.Unmarshal(&c, decodeHook())
+}
+
+// Timezone returns the timezone name of the device, UTC when none is set.
+func (c *Clock) Timezone(deviceID string) string {
+	if timezone := strings.TrimSpace(c.Timezones[deviceID]); timezone != "" {
+		return timezone
+	}
+	if timezone := strings.TrimSpace(c.Timezones["*"]); timezone != "" {
+		return timezone
+	}
+
+	return "UTC"
+}
+
+type Charset struct {
+	// Devices maps a device id to the character set of its payloads, it wins
+	// over the MSH-18 declaration, e.g. "urine-1=windows-1252,hl7-2=GB2312".
+	// "*" sets the charset of undeclared payloads that are not valid UTF-8
+	Devices map[string]string `mapstructure:"CHARSET_DEVICES"`
+}
+
+func (c *Charset) load(vp *viper.Viper) {
+	keyBind(c, vp)
+	vp.Unmarshal(&c, decodeHook())
+}
+
+// Device returns the charset configured for the device, empty when none is.
+func (c *Charset) Device(deviceID string) string {
+	return strings.TrimSpace(c.Devices[deviceID])
+}
+
+// Fallback returns the charset of undeclared payloads that are not valid
+// UTF-8, windows-1252 when none is set as it maps every byte.
+func (c *Charset) Fallback() string {
+	if charset := strings.TrimSpace(c.Devices["*"]); charset != "" {
+		return charset
+	}
+
+	return "windows-1252"
+}
+
+type Outbox struct {
+	// BatchSize is the number of due entries one dispatch claims
+	BatchSize int `mapstructure:"OUTBOX_BATCH_SIZE"`
+	// Lease hides a claimed entry from other dispatchers while it is being
+	// delivered, it is retried once the lease ends without an outcome
+	Lease time.Duration `mapstructure:"OUTBOX_LEASE"`
+	// BackoffBase is the delay after the first failed attempt, it doubles
+	// after every further failure up to BackoffMax
+	BackoffBase time.Duration `mapstructure:"OUTBOX_BACKOFF_BASE"`
+	BackoffMax  time.Duration `mapstructure:"OUTBOX_BACKOFF_MAX"`
+	// MaxAttempts moves an entry to the dead letters after that many failed
+	// deliveries
+	MaxAttempts int `mapstructure:"OUTBOX_MAX_ATTEMPTS"`
+}
+
+func (o *Outbox) load(vp *viper.Viper) {
+	keyBind(o, vp)
+	vp.Unmarshal(&o, decodeHook())
+}
+
+type Worker struct {
+	// Concurrency bounds the number of task runs in flight at once
+	Concurrency int `mapstructure:"WORKER_CONCURRENCY"`
+	// OutboxInterval is how often pending outbox entries are retried
+	OutboxInterval time.Duration `mapstructure:"WORKER_OUTBOX_INTERVAL"`
+	// RetentionInterval is how often expired records are purged, records
+	// are kept forever when their retention is zero
+	RetentionInterval time.Duration `mapstructure:"WORKER_RETENTION_INTERVAL"`
+	OutboxRetention   time.Duration `mapstructure:"WORKER_OUTBOX_RETENTION"`
+	// DeadLetterRetention applies to replayed and discarded dead letters,
+	// open ones are kept until support staff close them
+	DeadLetterRetention time.Duration `mapstructure:"WORKER_DEAD_LETTER_RETENTION"`
+	// ReprocessRounds is how many times an open dead letter is processed
+	// again, ReprocessInterval apart, before it is left to support staff.
+	// Dead letters are never reprocessed when it is zero
+	ReprocessRounds   int           `mapstructure:"WORKER_REPROCESS_ROUNDS"`
+	ReprocessInterval time.Duration `mapstructure:"WORKER_REPROCESS_INTERVAL"`
+}
+
+func (w *Worker) load(vp *viper.Viper) {
+	keyBind(w, vp)
+	vp.Unmarshal(&w, decodeHook())
 }
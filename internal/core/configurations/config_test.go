@@ -119,3 +119,142 @@ func TestJWTLoad(t *testing.T) {
 	assert.Equal(t, 3600, jwt.SessionExpiration)
 	assert.Equal(t, 7200, jwt.UserExpiration)
 }
+
+func TestLisPlatformLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("LIS_PLATFORM_URL", "http://lis.local")
+	t.Setenv("LIS_PLATFORM_SIGNING_KEY_ID", "2026-01")
+	t.Setenv("LIS_PLATFORM_SIGNING_SECRETS", "2025-12=old-secret,2026-01=new-secret")
+
+	lisPlatform := LisPlatform{}
+	lisPlatform.load(vp)
+
+	assert.Equal(t, LisPlatform{
+		Url:            "http://lis.local",
+		SigningKeyID:   "2026-01",
+		SigningSecrets: map[string]string{"2025-12": "old-secret", "2026-01": "new-secret"},
+	}, lisPlatform)
+}
+
+func TestHL7Load(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("HL7_FIELD_MAPPINGS", "analyzer:equipment_id=OBX-18,analyzer:rack=ZXX-2,hematology:rack=ZHM-4,invalid=PID-3")
+	t.Setenv("HL7_REJECT_PROCESSING_IDS", "T,D")
+
+	hl7 := HL7{}
+	hl7.load(vp)
+
+	assert.Equal(t, []string{"T", "D"}, hl7.RejectProcessingIDs)
+	assert.Equal(t, map[string]string{"equipment_id": "OBX-18", "rack": "ZXX-2"}, hl7.DeviceFieldMappings("analyzer"))
+	assert.Equal(t, map[string]string{"rack": "ZHM-4"}, hl7.DeviceFieldMappings("hematology"))
+	assert.Empty(t, hl7.DeviceFieldMappings("urine"))
+}
+
+func TestUrineLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("URINE_REFERENCE_RANGES", "specific_gravity=1.005-1.030, ph=5-8,leukocytes=negative")
+
+	urine := Urine{}
+	urine.load(vp)
+
+	assert.Equal(t, map[string]string{
+		"specific_gravity": "1.005-1.030",
+		"ph":               "5-8",
+		"leukocytes":       "negative",
+	}, urine.ReferenceRanges)
+}
+
+func TestParsingLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("PARSING_STRICT_DEVICES", "urine-1, hl7-2")
+
+	parsing := Parsing{}
+	parsing.load(vp)
+
+	assert.True(t, parsing.Strict("urine-1"))
+	assert.True(t, parsing.Strict("hl7-2"))
+	assert.False(t, parsing.Strict("astm-3"))
+	assert.True(t, (&Parsing{StrictDevices: []string{"*"}}).Strict("astm-3"))
+}
+
+func TestUnitsLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("UNITS_ALIASES", "Ery/HPF=/[HPF]")
+	t.Setenv("UNITS_CANONICAL", "glucose=mmol/L,leukocytes=/uL")
+	t.Setenv("UNITS_MOLAR_MASSES", "glucose=180.16")
+
+	units := Units{}
+	units.load(vp)
+
+	assert.Equal(t, map[string]string{"Ery/HPF": "/[HPF]"}, units.Aliases)
+	assert.Equal(t, map[string]string{"glucose": "mmol/L", "leukocytes": "/uL"}, units.Canonical)
+	assert.Equal(t, map[string]string{"glucose": "180.16"}, units.MolarMasses)
+}
+
+func TestClockLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("CLOCK_TIMEZONES", "urine-1=Asia/Jakarta,*=Asia/Makassar")
+	t.Setenv("CLOCK_SKEW_THRESHOLD", "5m")
+
+	clock := Clock{}
+	clock.load(vp)
+
+	assert.Equal(t, 5*time.Minute, clock.SkewThreshold)
+	assert.Equal(t, "Asia/Jakarta", clock.Timezone("urine-1"))
+	assert.Equal(t, "Asia/Makassar", clock.Timezone("hl7-2"))
+	assert.Equal(t, "UTC", (&Clock{}).Timezone("hl7-2"))
+}
+
+func TestCharsetLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("CHARSET_DEVICES", "urine-1=windows-1252,*=ISO-8859-1")
+
+	charset := Charset{}
+	charset.load(vp)
+
+	assert.Equal(t, "windows-1252", charset.Device("urine-1"))
+	assert.Empty(t, charset.Device("hl7-2"))
+	assert.Equal(t, "ISO-8859-1", charset.Fallback())
+	assert.Equal(t, "windows-1252", (&Charset{}).Fallback())
+}
+
+func TestOutboxLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("OUTBOX_BATCH_SIZE", "50")
+	t.Setenv("OUTBOX_LEASE", "1m")
+	t.Setenv("OUTBOX_BACKOFF_BASE", "10s")
+	t.Setenv("OUTBOX_BACKOFF_MAX", "1h")
+	t.Setenv("OUTBOX_MAX_ATTEMPTS", "12")
+
+	outbox := Outbox{}
+	outbox.load(vp)
+
+	assert.Equal(t, Outbox{BatchSize: 50, Lease: time.Minute, BackoffBase: 10 * time.Second, BackoffMax: time.Hour, MaxAttempts: 12}, outbox)
+}
+
+func TestWorkerLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("WORKER_CONCURRENCY", "4")
+	t.Setenv("WORKER_OUTBOX_INTERVAL", "15s")
+	t.Setenv("WORKER_RETENTION_INTERVAL", "1h")
+	t.Setenv("WORKER_OUTBOX_RETENTION", "720h")
+
+	worker := Worker{}
+	worker.load(vp)
+
+	assert.Equal(t, Worker{
+		Concurrency:       4,
+		OutboxInterval:    15 * time.Second,
+		RetentionInterval: time.Hour,
+		OutboxRetention:   720 * time.Hour,
+	}, worker)
+}
@@ -0,0 +1,63 @@
+package configurations
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type MLLP struct {
+	Port           uint64            `mapstructure:"MLLP_PORT"`
+	DeviceID       string            `mapstructure:"MLLP_DEVICE_ID"`
+	DeviceTypeCode string            `mapstructure:"MLLP_DEVICE_TYPE_CODE"`
+	Peers          map[string]string `mapstructure:"MLLP_PEERS"`
+	MaxConnection  int               `mapstructure:"MLLP_MAX_CONNECTION"`
+	MaxMessageSize int               `mapstructure:"MLLP_MAX_MESSAGE_SIZE"`
+	ReadTimeout    time.Duration     `mapstructure:"MLLP_READ_TIMEOUT"`
+}
+
+func (m *MLLP) load(vp *viper.Viper) {
+	keyBind(m, vp)
+	vp.Unmarshal(&m, decodeHook())
+}
+
+// PeerDevice resolves the device ID for a connected peer host, falling back
+// to the listener device ID when the peer is not configured.
+func (m *MLLP) PeerDevice(host string) string {
+	if deviceID, ok := m.Peers[host]; ok && deviceID != "" {
+		return deviceID
+	}
+
+	return m.DeviceID
+}
+
+type ASTM struct {
+	// Mode is server (analyzer connects), client (we connect to the
+	// analyzer) or serial
+	Mode              string            `mapstructure:"ASTM_MODE"`
+	Port              uint64            `mapstructure:"ASTM_PORT"`
+	Address           string            `mapstructure:"ASTM_ADDRESS"`
+	SerialPort        string            `mapstructure:"ASTM_SERIAL_PORT"`
+	BaudRate          int               `mapstructure:"ASTM_BAUD_RATE"`
+	DeviceID          string            `mapstructure:"ASTM_DEVICE_ID"`
+	DeviceTypeCode    string            `mapstructure:"ASTM_DEVICE_TYPE_CODE"`
+	Peers             map[string]string `mapstructure:"ASTM_PEERS"`
+	MaxConnection     int               `mapstructure:"ASTM_MAX_CONNECTION"`
+	MaxMessageSize    int               `mapstructure:"ASTM_MAX_MESSAGE_SIZE"`
+	ReconnectInterval time.Duration     `mapstructure:"ASTM_RECONNECT_INTERVAL"`
+}
+
+func (a *ASTM) load(vp *viper.Viper) {
+	keyBind(a, vp)
+	vp.Unmarshal(&a, decodeHook())
+}
+
+// PeerDevice resolves the device ID for a connected peer host, falling back
+// to the listener device ID when the peer is not configured.
+func (a *ASTM) PeerDevice(host string) string {
+	if deviceID, ok := a.Peers[host]; ok && deviceID != "" {
+		return deviceID
+	}
+
+	return a.DeviceID
+}
@@ -0,0 +1,54 @@
+package configurations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMLLPLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("MLLP_PORT", "2575")
+	t.Setenv("MLLP_DEVICE_ID", "default-device")
+	t.Setenv("MLLP_DEVICE_TYPE_CODE", "analyzer")
+	t.Setenv("MLLP_PEERS", "10.0.0.5=chemistry-1, 10.0.0.6=immuno-1,invalid")
+	t.Setenv("MLLP_READ_TIMEOUT", "30s")
+
+	mllp := MLLP{}
+	mllp.load(vp)
+
+	assert.Equal(t, uint64(2575), mllp.Port)
+	assert.Equal(t, "analyzer", mllp.DeviceTypeCode)
+	assert.Equal(t, 30*time.Second, mllp.ReadTimeout)
+	assert.Equal(t, map[string]string{
+		"10.0.0.5": "chemistry-1",
+		"10.0.0.6": "immuno-1",
+	}, mllp.Peers)
+
+	assert.Equal(t, "chemistry-1", mllp.PeerDevice("10.0.0.5"))
+	assert.Equal(t, "default-device", mllp.PeerDevice("10.0.0.7"))
+}
+
+func TestASTMLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("ASTM_MODE", "serial")
+	t.Setenv("ASTM_SERIAL_PORT", "/dev/ttyUSB0")
+	t.Setenv("ASTM_BAUD_RATE", "9600")
+	t.Setenv("ASTM_DEVICE_ID", "coagulation-1")
+	t.Setenv("ASTM_PEERS", "10.0.0.9=chemistry-2")
+	t.Setenv("ASTM_RECONNECT_INTERVAL", "5s")
+
+	astm := ASTM{}
+	astm.load(vp)
+
+	assert.Equal(t, "serial", astm.Mode)
+	assert.Equal(t, "/dev/ttyUSB0", astm.SerialPort)
+	assert.Equal(t, 9600, astm.BaudRate)
+	assert.Equal(t, 5*time.Second, astm.ReconnectInterval)
+	assert.Equal(t, "chemistry-2", astm.PeerDevice("10.0.0.9"))
+	assert.Equal(t, "coagulation-1", astm.PeerDevice("10.0.0.10"))
+}
@@ -0,0 +1,328 @@
+package configurations
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+const (
+	// DESTINATION_LIS_PLATFORM is the LIS platform at LIS_PLATFORM_URL, it
+	// receives every result unless a rule of its own is configured
+	DESTINATION_LIS_PLATFORM = "lis"
+
+	DESTINATION_AUTH_NONE   = "none"
+	DESTINATION_AUTH_BEARER = "bearer"
+	DESTINATION_AUTH_BASIC  = "basic"
+	DESTINATION_AUTH_HMAC   = "hmac"
+
+	// DESTINATION_FORMAT_JSON posts the result as one JSON document,
+	// DESTINATION_FORMAT_FLAT as an array with one row per result value
+	DESTINATION_FORMAT_JSON = "json"
+	DESTINATION_FORMAT_FLAT = "flat"
+
+	ROUTING_MATCH_ALL  = "*"
+	ROUTING_MATCH_NONE = "-"
+)
+
+type Routing struct {
+	// Destinations and Rules are either a JSON object, {"lake":"url:..."},
+	// or name=spec pairs separated by ','. A url or credential containing
+	// a ',' needs the JSON form.
+	//
+	// Destinations maps a destination name to ';' separated settings:
+	// url:<http(s), amqp(s), kafka, nats or file url>,
+	// auth:<none|bearer <token>|basic <user>:<password>|hmac <key id>>,
+	// attempts:<n>, backoff:<base duration>, max_backoff:<duration> and
+	// format:<json|flat>. Only the url is required, the retry policy defaults
+	// to the OUTBOX_* settings. Auth applies to http destinations, the hmac
+	// secret comes from LIS_PLATFORM_SIGNING_SECRETS, brokers take their
+	// credentials from the url.
+	Destinations map[string]string `mapstructure:"ROUTING_DESTINATIONS"`
+	// Rules maps a destination name to ';' separated conditions a result
+	// must all meet: device:<id>, device_type:<code>, protocol:<protocol>,
+	// parameter:<code> and message_type:<type>, '|' separates the accepted
+	// values. "*" routes every result and "-" none, a destination without a
+	// rule receives nothing.
+	Rules map[string]string `mapstructure:"ROUTING_RULES"`
+
+	// err keeps a routing env value that could not be decoded, the router
+	// reports it when it starts
+	err error
+}
+
+// Destination is where results are delivered to and how.
+type Destination struct {
+	Name string
+	Url  string
+	Auth DestinationAuth
+	// MaxAttempts, BackoffBase and BackoffMax override the outbox retry
+	// policy when set
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	Format      string
+}
+
+type DestinationAuth struct {
+	Scheme   string
+	Token    string
+	Username string
+	Password string
+	KeyID    string
+}
+
+// RoutingRule selects the results of a destination, an empty list accepts
+// any value.
+type RoutingRule struct {
+	Destination     string
+	All             bool
+	None            bool
+	DeviceIDs       []string
+	DeviceTypeCodes []string
+	Protocols       []string
+	ParameterCodes  []string
+	MessageTypes    []string
+}
+
+func (r *Routing) load(vp *viper.Viper) {
+	keyBind(r, vp)
+	if err := vp.Unmarshal(&r, viper.DecodeHook(stringToRoutingMapHookFunc())); err != nil {
+		r.err = errors.Wrap(errors.ERROR_INVALID_ROUTING_CONFIG, err.Error())
+	}
+}
+
+// stringToRoutingMapHookFunc decodes a routing env value. Unlike the other
+// map settings a pair without "=" is rejected instead of skipped, it is the
+// tail of a url or credential that was cut at a ','.
+func stringToRoutingMapHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data any) (any, error) {
+		if f.Kind() != reflect.String || t.Kind() != reflect.Map {
+			return data, nil
+		}
+
+		res := map[string]string{}
+		raw := strings.TrimSpace(data.(string))
+		if strings.HasPrefix(raw, "{") {
+			err := json.Unmarshal([]byte(raw), &res)
+
+			return res, err
+		}
+
+		for pair := range strings.SplitSeq(raw, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, value, found := strings.Cut(pair, "=")
+			key = strings.TrimSpace(key)
+			if !found || key == "" {
+				return nil, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "pair %q is not name=spec, use a JSON object for values with ','", pair)
+			}
+			res[key] = strings.TrimSpace(value)
+		}
+
+		return res, nil
+	}
+}
+
+// DestinationList parses the configured destinations. The LIS platform is
+// always one of them, signed with the LIS platform key, unless a destination
+// named "lis" replaces it.
+func (r *Routing) DestinationList(lisPlatform LisPlatform) ([]Destination, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	destinations := []Destination{}
+	if _, ok := r.Destinations[DESTINATION_LIS_PLATFORM]; !ok {
+		// results routed to a platform without url could only be dead-lettered
+		if !validDestinationURL(lisPlatform.Url) {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s url %q, set LIS_PLATFORM_URL", DESTINATION_LIS_PLATFORM, lisPlatform.Url)
+		}
+		destination := Destination{
+			Name:   DESTINATION_LIS_PLATFORM,
+			Url:    lisPlatform.Url,
+			Auth:   DestinationAuth{Scheme: DESTINATION_AUTH_NONE},
+			Format: DESTINATION_FORMAT_JSON,
+		}
+		if lisPlatform.SigningKeyID != "" {
+			destination.Auth = DestinationAuth{Scheme: DESTINATION_AUTH_HMAC, KeyID: lisPlatform.SigningKeyID}
+		}
+		destinations = append(destinations, destination)
+	}
+
+	for name, spec := range r.Destinations {
+		destination, err := parseDestination(name, spec)
+		if err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, destination)
+	}
+
+	return destinations, nil
+}
+
+// RuleList parses the routing rules, the LIS platform routes every result
+// when no rule of its own is set.
+func (r *Routing) RuleList() ([]RoutingRule, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	rules := []RoutingRule{}
+	if _, ok := r.Rules[DESTINATION_LIS_PLATFORM]; !ok {
+		rules = append(rules, RoutingRule{Destination: DESTINATION_LIS_PLATFORM, All: true})
+	}
+
+	for destination, spec := range r.Rules {
+		rule, err := parseRoutingRule(destination, spec)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func parseDestination(name, spec string) (Destination, error) {
+	destination := Destination{
+		Name:   name,
+		Auth:   DestinationAuth{Scheme: DESTINATION_AUTH_NONE},
+		Format: DESTINATION_FORMAT_JSON,
+	}
+
+	for setting := range strings.SplitSeq(spec, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(setting), ":")
+		value = strings.TrimSpace(value)
+		switch key {
+		case "":
+		case "url":
+			if !validDestinationURL(value) {
+				return destination, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s url %q", name, value)
+			}
+			destination.Url = value
+		case "auth":
+			auth, err := parseDestinationAuth(value)
+			if err != nil {
+				return destination, errors.Wrapf(err, "destination %s auth", name)
+			}
+			destination.Auth = auth
+		case "attempts":
+			attempts, err := strconv.Atoi(value)
+			if err != nil || attempts <= 0 {
+				return destination, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s attempts %q", name, value)
+			}
+			destination.MaxAttempts = attempts
+		case "backoff", "max_backoff":
+			delay, err := time.ParseDuration(value)
+			if err != nil || delay <= 0 {
+				return destination, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s %s %q", name, key, value)
+			}
+			if key == "backoff" {
+				destination.BackoffBase = delay
+			} else {
+				destination.BackoffMax = delay
+			}
+		case "format":
+			switch value {
+			case DESTINATION_FORMAT_JSON, DESTINATION_FORMAT_FLAT:
+				destination.Format = value
+			default:
+				return destination, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s format %q", name, value)
+			}
+		default:
+			return destination, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s setting %q", name, key)
+		}
+	}
+
+	if destination.Url == "" {
+		return destination, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "destination %s has no url", name)
+	}
+
+	return destination, nil
+}
+
+func validDestinationURL(value string) bool {
+	u, err := url.Parse(value)
+
+	return err == nil && u.Scheme != "" && (u.Host != "" || u.Path != "")
+}
+
+func parseDestinationAuth(spec string) (DestinationAuth, error) {
+	scheme, credential, _ := strings.Cut(spec, " ")
+	credential = strings.TrimSpace(credential)
+	auth := DestinationAuth{Scheme: strings.ToLower(scheme)}
+
+	switch auth.Scheme {
+	case DESTINATION_AUTH_NONE:
+		return auth, nil
+	case DESTINATION_AUTH_BEARER:
+		auth.Token = credential
+	case DESTINATION_AUTH_BASIC:
+		auth.Username, auth.Password, _ = strings.Cut(credential, ":")
+		credential = auth.Username
+	case DESTINATION_AUTH_HMAC:
+		auth.KeyID = credential
+	default:
+		return auth, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "scheme %q", scheme)
+	}
+	if credential == "" {
+		return auth, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "%s without credential", auth.Scheme)
+	}
+
+	return auth, nil
+}
+
+func parseRoutingRule(destination, spec string) (RoutingRule, error) {
+	rule := RoutingRule{Destination: destination}
+	switch strings.TrimSpace(spec) {
+	case ROUTING_MATCH_ALL:
+		rule.All = true
+
+		return rule, nil
+	case ROUTING_MATCH_NONE, "":
+		rule.None = true
+
+		return rule, nil
+	}
+
+	for condition := range strings.SplitSeq(spec, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(condition), ":")
+		values := []string{}
+		for v := range strings.SplitSeq(value, "|") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+
+		switch key {
+		case "":
+			continue
+		case "device":
+			rule.DeviceIDs = values
+		case "device_type":
+			rule.DeviceTypeCodes = values
+		case "protocol":
+			rule.Protocols = values
+		case "parameter":
+			rule.ParameterCodes = values
+		case "message_type":
+			rule.MessageTypes = values
+		default:
+			return rule, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "rule of %s condition %q", destination, key)
+		}
+		if len(values) == 0 {
+			return rule, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "rule of %s condition %s has no value", destination, key)
+		}
+	}
+
+	return rule, nil
+}
@@ -0,0 +1,122 @@
+package configurations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoutingLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("ROUTING_DESTINATIONS", "lake=url:https://lake.local/results;auth:bearer token-1;format:flat;attempts:5;backoff:1m;max_backoff:2h, qc=url:https://qc.local;auth:basic qc:secret")
+	t.Setenv("ROUTING_RULES", "lake=device:urine-1|urine-2;protocol:rs232, qc=parameter:QC-GLU")
+
+	routing := Routing{}
+	routing.load(vp)
+
+	destinations, err := routing.DestinationList(LisPlatform{Url: "https://lis.local", SigningKeyID: "2026-01"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []Destination{
+		{
+			Name:   DESTINATION_LIS_PLATFORM,
+			Url:    "https://lis.local",
+			Auth:   DestinationAuth{Scheme: DESTINATION_AUTH_HMAC, KeyID: "2026-01"},
+			Format: DESTINATION_FORMAT_JSON,
+		},
+		{
+			Name:        "lake",
+			Url:         "https://lake.local/results",
+			Auth:        DestinationAuth{Scheme: DESTINATION_AUTH_BEARER, Token: "token-1"},
+			MaxAttempts: 5,
+			BackoffBase: time.Minute,
+			BackoffMax:  2 * time.Hour,
+			Format:      DESTINATION_FORMAT_FLAT,
+		},
+		{
+			Name:   "qc",
+			Url:    "https://qc.local",
+			Auth:   DestinationAuth{Scheme: DESTINATION_AUTH_BASIC, Username: "qc", Password: "secret"},
+			Format: DESTINATION_FORMAT_JSON,
+		},
+	}, destinations)
+
+	rules, err := routing.RuleList()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []RoutingRule{
+		{Destination: DESTINATION_LIS_PLATFORM, All: true},
+		{Destination: "lake", DeviceIDs: []string{"urine-1", "urine-2"}, Protocols: []string{"rs232"}},
+		{Destination: "qc", ParameterCodes: []string{"QC-GLU"}},
+	}, rules)
+}
+
+func TestRoutingLoadJSON(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	// commas in a url or a credential need the JSON form
+	t.Setenv("ROUTING_DESTINATIONS", `{"lake":"url:https://lake.local/results?fields=glu,pro;auth:basic lake:se,cret"}`)
+	t.Setenv("ROUTING_RULES", `{"lake":"device:urine-1"}`)
+
+	routing := Routing{}
+	routing.load(vp)
+
+	destinations, err := routing.DestinationList(LisPlatform{Url: "https://lis.local"})
+	assert.NoError(t, err)
+	assert.Contains(t, destinations, Destination{
+		Name:   "lake",
+		Url:    "https://lake.local/results?fields=glu,pro",
+		Auth:   DestinationAuth{Scheme: DESTINATION_AUTH_BASIC, Username: "lake", Password: "se,cret"},
+		Format: DESTINATION_FORMAT_JSON,
+	})
+
+	// the same value as pairs is cut at the comma and rejected
+	t.Setenv("ROUTING_DESTINATIONS", "lake=url:https://lake.local/results?fields=glu,pro")
+	routing = Routing{}
+	routing.load(vp)
+	_, err = routing.DestinationList(LisPlatform{Url: "https://lis.local"})
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG)
+	_, err = routing.RuleList()
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG)
+}
+
+func TestRoutingInvalid(t *testing.T) {
+	// the lis platform needs a url unless a destination replaces it
+	for _, lisUrl := range []string{"", "lis.local", "://lis.local"} {
+		_, err := (&Routing{}).DestinationList(LisPlatform{Url: lisUrl})
+		assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG, lisUrl)
+	}
+	routing := Routing{Destinations: map[string]string{DESTINATION_LIS_PLATFORM: "url:file:///var/spool/lis.jsonl"}}
+	_, err := routing.DestinationList(LisPlatform{})
+	assert.NoError(t, err)
+
+	for _, spec := range []string{
+		"auth:bearer token-1",
+		"url:lake.local",
+		"url:kafka://",
+		"url:https://lake.local;auth:bearer",
+		"url:https://lake.local;auth:digest user",
+		"url:https://lake.local;attempts:0",
+		"url:https://lake.local;backoff:soon",
+		"url:https://lake.local;format:xml",
+		"url:https://lake.local;timeout:1s",
+	} {
+		routing := Routing{Destinations: map[string]string{"lake": spec}}
+		_, err := routing.DestinationList(LisPlatform{Url: "https://lis.local"})
+		assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG, spec)
+	}
+
+	for _, spec := range []string{"device:", "patient:P-1"} {
+		routing := Routing{Rules: map[string]string{"lake": spec}}
+		_, err := routing.RuleList()
+		assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG, spec)
+	}
+
+	// "-" keeps a destination, the lis platform among them, from any result
+	routing = Routing{Rules: map[string]string{DESTINATION_LIS_PLATFORM: "-"}}
+	rules, err := routing.RuleList()
+	assert.NoError(t, err)
+	assert.Equal(t, []RoutingRule{{Destination: DESTINATION_LIS_PLATFORM, None: true}}, rules)
+}
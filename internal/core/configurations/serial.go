@@ -0,0 +1,219 @@
+package configurations
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/spf13/viper"
+)
+
+const (
+	SERIAL_PARITY_NONE = "none"
+	SERIAL_PARITY_ODD  = "odd"
+	SERIAL_PARITY_EVEN = "even"
+
+	SERIAL_FLOW_NONE    = "none"
+	SERIAL_FLOW_RTSCTS  = "rtscts"
+	SERIAL_FLOW_XONXOFF = "xonxoff"
+
+	SERIAL_PROTOCOL_RS232 = "rs232"
+	SERIAL_PROTOCOL_ASTM  = "astm"
+
+	DEFAULT_SERIAL_BAUD_RATE = 9600
+)
+
+type Serial struct {
+	// Devices maps a device ID to its port settings written as
+	// <port>|<device type>|<baud>|<frame>|<flow>|<protocol>, for example
+	// /dev/ttyUSB0|urine|9600|8N1|none|rs232. Only the port is required,
+	// flow control other than none is only supported on Linux.
+	Devices map[string]string `mapstructure:"SERIAL_DEVICES"`
+	// Boundaries maps a device type to ';' separated message boundary rules:
+	// idle:<duration>, terminator:<byte> and header:<line prefix>
+	Boundaries        map[string]string `mapstructure:"SERIAL_BOUNDARIES"`
+	IdleTimeout       time.Duration     `mapstructure:"SERIAL_IDLE_TIMEOUT"`
+	MaxMessageSize    int               `mapstructure:"SERIAL_MAX_MESSAGE_SIZE"`
+	ReconnectInterval time.Duration     `mapstructure:"SERIAL_RECONNECT_INTERVAL"`
+}
+
+// SerialDevice is one configured serial port.
+type SerialDevice struct {
+	DeviceID       string
+	DeviceTypeCode string
+	Port           string
+	BaudRate       int
+	DataBits       int
+	Parity         string
+	StopBits       int
+	FlowControl    string
+	Protocol       string
+}
+
+// SerialBoundary tells where one message ends in the byte stream. Every rule
+// that is set applies, whichever matches first ends the message.
+type SerialBoundary struct {
+	// IdleTimeout ends a message when the line stays quiet that long
+	IdleTimeout time.Duration
+	// Terminator ends a message on this byte, zero when unused
+	Terminator byte
+	// Header starts a new message on a line beginning with it
+	Header string
+}
+
+func (s *Serial) load(vp *viper.Viper) {
+	keyBind(s, vp)
+	vp.Unmarshal(&s, decodeHook())
+}
+
+// DeviceList parses the configured devices, applying 9600 8N1 without flow
+// control for the rs232 protocol when a setting is left out.
+func (s *Serial) DeviceList() ([]SerialDevice, error) {
+	devices := make([]SerialDevice, 0, len(s.Devices))
+	for deviceID, spec := range s.Devices {
+		device, err := parseSerialDevice(deviceID, spec)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+
+	return devices, nil
+}
+
+// Boundary resolves the boundary rules of a device type. The idle timeout
+// always applies so a message is never held back forever.
+func (s *Serial) Boundary(deviceTypeCode string) (SerialBoundary, error) {
+	boundary := SerialBoundary{IdleTimeout: s.IdleTimeout}
+	rules, ok := s.Boundaries[deviceTypeCode]
+	if !ok {
+		return boundary, nil
+	}
+
+	for _, rule := range strings.Split(rules, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(rule), ":")
+		switch name {
+		case "":
+		case "idle":
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return boundary, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "idle rule %q of %s", value, deviceTypeCode)
+			}
+			boundary.IdleTimeout = timeout
+		case "terminator":
+			terminator, err := strconv.ParseUint(value, 0, 8)
+			if err != nil || terminator == 0 {
+				return boundary, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "terminator rule %q of %s", value, deviceTypeCode)
+			}
+			boundary.Terminator = byte(terminator)
+		case "header":
+			if value == "" {
+				return boundary, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "empty header rule of %s", deviceTypeCode)
+			}
+			boundary.Header = value
+		default:
+			return boundary, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "unknown rule %q of %s", name, deviceTypeCode)
+		}
+	}
+
+	return boundary, nil
+}
+
+func parseSerialDevice(deviceID, spec string) (SerialDevice, error) {
+	device := SerialDevice{
+		DeviceID:    deviceID,
+		BaudRate:    DEFAULT_SERIAL_BAUD_RATE,
+		DataBits:    8,
+		Parity:      SERIAL_PARITY_NONE,
+		StopBits:    1,
+		FlowControl: SERIAL_FLOW_NONE,
+		Protocol:    SERIAL_PROTOCOL_RS232,
+	}
+
+	parts := strings.Split(spec, "|")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	part := func(i int) string {
+		if i < len(parts) {
+			return parts[i]
+		}
+
+		return ""
+	}
+
+	device.Port = part(0)
+	if device.Port == "" {
+		return device, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "device %s has no port", deviceID)
+	}
+	device.DeviceTypeCode = part(1)
+
+	if value := part(2); value != "" {
+		baudRate, err := strconv.Atoi(value)
+		if err != nil || baudRate <= 0 {
+			return device, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "device %s baud rate %q", deviceID, value)
+		}
+		device.BaudRate = baudRate
+	}
+
+	if value := part(3); value != "" {
+		if err := device.setFrame(value); err != nil {
+			return device, errors.Wrapf(err, "device %s frame %q", deviceID, value)
+		}
+	}
+
+	if value := strings.ToLower(part(4)); value != "" {
+		switch value {
+		case SERIAL_FLOW_NONE, SERIAL_FLOW_RTSCTS, SERIAL_FLOW_XONXOFF:
+			device.FlowControl = value
+		default:
+			return device, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "device %s flow control %q", deviceID, value)
+		}
+	}
+
+	if value := strings.ToLower(part(5)); value != "" {
+		switch value {
+		case SERIAL_PROTOCOL_RS232, SERIAL_PROTOCOL_ASTM:
+			device.Protocol = value
+		default:
+			return device, errors.Wrapf(errors.ERROR_INVALID_SERIAL_CONFIG, "device %s protocol %q", deviceID, value)
+		}
+	}
+
+	return device, nil
+}
+
+// setFrame reads the usual data bits, parity and stop bits notation such as
+// 8N1 or 7E2.
+func (d *SerialDevice) setFrame(frame string) error {
+	if len(frame) != 3 {
+		return errors.ERROR_INVALID_SERIAL_CONFIG
+	}
+
+	dataBits := int(frame[0] - '0')
+	if dataBits < 5 || dataBits > 8 {
+		return errors.ERROR_INVALID_SERIAL_CONFIG
+	}
+
+	var parity string
+	switch frame[1] {
+	case 'N', 'n':
+		parity = SERIAL_PARITY_NONE
+	case 'O', 'o':
+		parity = SERIAL_PARITY_ODD
+	case 'E', 'e':
+		parity = SERIAL_PARITY_EVEN
+	default:
+		return errors.ERROR_INVALID_SERIAL_CONFIG
+	}
+
+	stopBits := int(frame[2] - '0')
+	if stopBits != 1 && stopBits != 2 {
+		return errors.ERROR_INVALID_SERIAL_CONFIG
+	}
+
+	d.DataBits, d.Parity, d.StopBits = dataBits, parity, stopBits
+
+	return nil
+}
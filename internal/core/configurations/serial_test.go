@@ -0,0 +1,76 @@
+package configurations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerialLoad(t *testing.T) {
+	vp := viper.New()
+	vp.AutomaticEnv()
+	t.Setenv("SERIAL_DEVICES", "urine-1=/dev/ttyUSB0|urine|19200|7E2|rtscts, hema-1=/dev/ttyUSB1")
+	t.Setenv("SERIAL_BOUNDARIES", "urine=header:NO.;idle:3s, hematology=terminator:0x04")
+	t.Setenv("SERIAL_IDLE_TIMEOUT", "2s")
+	t.Setenv("SERIAL_RECONNECT_INTERVAL", "5s")
+
+	serial := Serial{}
+	serial.load(vp)
+
+	assert.Equal(t, 5*time.Second, serial.ReconnectInterval)
+
+	devices, err := serial.DeviceList()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []SerialDevice{
+		{
+			DeviceID:       "urine-1",
+			DeviceTypeCode: "urine",
+			Port:           "/dev/ttyUSB0",
+			BaudRate:       19200,
+			DataBits:       7,
+			Parity:         SERIAL_PARITY_EVEN,
+			StopBits:       2,
+			FlowControl:    SERIAL_FLOW_RTSCTS,
+			Protocol:       SERIAL_PROTOCOL_RS232,
+		},
+		{
+			DeviceID:    "hema-1",
+			Port:        "/dev/ttyUSB1",
+			BaudRate:    DEFAULT_SERIAL_BAUD_RATE,
+			DataBits:    8,
+			Parity:      SERIAL_PARITY_NONE,
+			StopBits:    1,
+			FlowControl: SERIAL_FLOW_NONE,
+			Protocol:    SERIAL_PROTOCOL_RS232,
+		},
+	}, devices)
+
+	boundary, err := serial.Boundary("urine")
+	assert.NoError(t, err)
+	assert.Equal(t, SerialBoundary{IdleTimeout: 3 * time.Second, Header: "NO."}, boundary)
+
+	boundary, err = serial.Boundary("hematology")
+	assert.NoError(t, err)
+	assert.Equal(t, SerialBoundary{IdleTimeout: 2 * time.Second, Terminator: 0x04}, boundary)
+
+	boundary, err = serial.Boundary("unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, SerialBoundary{IdleTimeout: 2 * time.Second}, boundary)
+}
+
+func TestSerialInvalidConfig(t *testing.T) {
+	for _, spec := range []string{"", "|urine", "/dev/ttyS0||fast", "/dev/ttyS0||9600|9N1", "/dev/ttyS0||9600|8N1|dtr", "/dev/ttyS0||9600|8N1|none|hl7"} {
+		serial := Serial{Devices: map[string]string{"device": spec}}
+		_, err := serial.DeviceList()
+		assert.ErrorIs(t, err, errors.ERROR_INVALID_SERIAL_CONFIG, spec)
+	}
+
+	for _, rules := range []string{"idle:soon", "terminator:0", "header:", "length:10"} {
+		serial := Serial{Boundaries: map[string]string{"urine": rules}}
+		_, err := serial.Boundary("urine")
+		assert.ErrorIs(t, err, errors.ERROR_INVALID_SERIAL_CONFIG, rules)
+	}
+}
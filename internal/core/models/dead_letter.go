@@ -0,0 +1,113 @@
+package models
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/google/uuid"
+)
+
+const (
+	DEAD_LETTER_REASON_PARSE_FAILED       = "parse_failed"
+	DEAD_LETTER_REASON_DELIVERY_EXHAUSTED = "delivery_exhausted"
+
+	DEAD_LETTER_STATUS_OPEN      = "open"
+	DEAD_LETTER_STATUS_REPLAYED  = "replayed"
+	DEAD_LETTER_STATUS_DISCARDED = "discarded"
+)
+
+// deadLetterSorts are the columns a dead letter list can be sorted by
+var deadLetterSorts = []string{"created_at", "updated_at", "attempts", "device_id"}
+
+// DeadLetter keeps a message that failed parsing or a result whose delivery
+// retries ran out, with a snapshot of the payload so it can be replayed.
+type DeadLetter struct {
+	ID              *uuid.UUID `json:"id" gorm:"column:id;type:uuid;primaryKey;default:uuid_generate_v4();"`
+	Reason          string     `json:"reason" gorm:"column:reason"`
+	Status          string     `json:"status" gorm:"column:status"`
+	DeviceMessageID string     `json:"device_message_id" gorm:"column:device_message_id"`
+	OutboxEntryID   string     `json:"outbox_entry_id,omitempty" gorm:"column:outbox_entry_id"`
+	Destination     string     `json:"destination,omitempty" gorm:"column:destination"`
+	DeviceID        string     `json:"device_id" gorm:"column:device_id"`
+	DeviceTypeCode  string     `json:"device_type_code" gorm:"column:device_type_code"`
+	Protocol        string     `json:"protocol" gorm:"column:protocol"`
+	// Errors is the error chain of the last failure, outermost first
+	Errors   []string `json:"errors" gorm:"column:error_chain;serializer:json"`
+	Attempts int      `json:"attempts" gorm:"column:attempts"`
+	// Reprocessed counts the rounds the worker ran it in, a message that
+	// fails parsing again carries it to its new dead letter
+	Reprocessed int `json:"reprocessed" gorm:"column:reprocessed"`
+	// Payload is the device message of a parse failure or the serialized
+	// result of a delivery failure, ReplayedPayload the edited one replayed
+	Payload         string     `json:"payload" gorm:"column:payload"`
+	ReplayedPayload string     `json:"replayed_payload,omitempty" gorm:"column:replayed_payload"`
+	ReplayedAt      *time.Time `json:"replayed_at" gorm:"column:replayed_at"`
+	DiscardedAt     *time.Time `json:"discarded_at" gorm:"column:discarded_at"`
+	Default
+}
+
+func (DeadLetter) TableName() string {
+	return "dead_letters"
+}
+
+type DeadLetterFilter struct {
+	Status          string `json:"status" query:"status" filter:"status"`
+	Reason          string `json:"reason" query:"reason" filter:"reason"`
+	DeviceID        string `json:"device_id" query:"device_id" filter:"device_id"`
+	DeviceMessageID string `json:"device_message_id" query:"device_message_id" filter:"device_message_id"`
+	Destination     string `json:"destination" query:"destination" filter:"destination"`
+	// SortBy lists "<column>.<asc|desc>" pairs, e.g. "created_at.desc"
+	SortBy string `json:"sort_by" query:"sort_by" sort:"sort_by"`
+	Pagination
+}
+
+// Validate lists the open dead letters, newest first, by default and only
+// lets known columns through to the order clause.
+func (d *DeadLetterFilter) Validate() error {
+	if d.Status == "" {
+		d.Status = DEAD_LETTER_STATUS_OPEN
+	}
+	if d.SortBy == "" {
+		d.SortBy = "created_at.desc"
+	}
+	for sort := range strings.SplitSeq(d.SortBy, ",") {
+		column, direction, _ := strings.Cut(sort, ".")
+		if !slices.Contains(deadLetterSorts, column) ||
+			(direction != "" && direction != "asc" && direction != "desc") {
+			return errors.Wrapf(errors.ERROR_BAD_REQUEST, "sort by %q", sort)
+		}
+	}
+	if d.Page <= 0 {
+		d.Page = 1
+	}
+	if d.Limit <= 0 || d.Limit > 100 {
+		d.Limit = 20
+	}
+
+	return nil
+}
+
+type DeadLetterReplayParam struct {
+	// Payload replaces the snapshot when set, a device message for parse
+	// failures and a result JSON for delivery failures
+	Payload string `json:"payload" form:"payload"`
+}
+
+// Validate checks the edited result is still JSON, device messages are
+// checked by parsing them again.
+func (d DeadLetterReplayParam) Validate(deadLetter DeadLetter) error {
+	if d.Payload != "" && deadLetter.Reason == DEAD_LETTER_REASON_DELIVERY_EXHAUSTED && !json.Valid([]byte(d.Payload)) {
+		return errors.Wrap(errors.ERROR_BAD_REQUEST, "payload is not JSON")
+	}
+
+	return nil
+}
+
+type DeadLetterReplayOutput struct {
+	DeadLetter    DeadLetter           `json:"dead_letter"`
+	DeviceMessage *DeviceMessageOutput `json:"device_message,omitempty"`
+	OutboxEntry   *OutboxEntry         `json:"outbox_entry,omitempty"`
+}
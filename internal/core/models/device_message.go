@@ -2,17 +2,51 @@ package models
 
 import (
 	"encoding/base64"
+	"sync"
+	"time"
 
 	"github.com/Calmantara/lis-backend/internal/helpers/errors"
 	"github.com/google/uuid"
 )
 
+const (
+	PROTOCOL_HL7   = "hl7"
+	PROTOCOL_ASTM  = "astm"
+	PROTOCOL_RS232 = "rs232"
+)
+
 type DeviceMessage struct {
 	ID             *uuid.UUID `gorm:"column:id;type:uuid;primaryKey;default:uuid_generate_v4();"`
 	DeviceID       string     `json:"device_id" gorm:"column:device_id"`
 	DeviceTypeCode string     `json:"device_type_code" gorm:"column:device_type_code"`
 	Message        string     `json:"message" gorm:"column:message"`
 	Protocol       string     `json:"protocol" gorm:"column:protocol"`
+	// message header metadata, e.g. HL7 MSH or ASTM H
+	MessageType        string `json:"message_type" gorm:"column:message_type"`
+	MessageControlID   string `json:"message_control_id" gorm:"column:message_control_id"`
+	ProcessingID       string `json:"processing_id" gorm:"column:processing_id"`
+	SendingApplication string `json:"sending_application" gorm:"column:sending_application"`
+	SendingFacility    string `json:"sending_facility" gorm:"column:sending_facility"`
+	ProtocolVersion    string `json:"protocol_version" gorm:"column:protocol_version"`
+	// ParseError keeps why the message could not be parsed
+	ParseError string `json:"parse_error" gorm:"column:parse_error"`
+	// ParseDiagnostics keeps the JSON list of what the parser could not read
+	ParseDiagnostics string `json:"parse_diagnostics" gorm:"column:parse_diagnostics"`
+	// Timezone is the IANA timezone of the timestamps the instrument prints
+	// without an offset
+	Timezone string `json:"timezone" gorm:"column:timezone"`
+	// InstrumentTime is the header time of the message, or the latest result
+	// time when the protocol has none, ReceivedAt is the server clock
+	InstrumentTime *time.Time `json:"instrument_time" gorm:"column:instrument_time"`
+	ReceivedAt     time.Time  `json:"received_at" gorm:"column:received_at"`
+	// ClockSkew is InstrumentTime minus ReceivedAt in seconds, ClockSkewed
+	// is set above the configured threshold
+	ClockSkew   int64 `json:"clock_skew" gorm:"column:clock_skew"`
+	ClockSkewed bool  `json:"clock_skewed" gorm:"column:clock_skewed"`
+	// Charset is the character set the message was transcoded from,
+	// RawMessage keeps the bytes as received when transcoding changed them
+	Charset    string `json:"charset" gorm:"column:charset"`
+	RawMessage []byte `json:"raw_message,omitempty" gorm:"column:raw_message"`
 	Default
 }
 
@@ -20,6 +54,35 @@ func (DeviceMessage) TableName() string {
 	return "device_messages"
 }
 
+// locations caches the loaded timezones by name
+var locations sync.Map
+
+// Location returns the timezone of the naive timestamps of the message, UTC
+// when it is not set or unknown.
+func (d DeviceMessage) Location() *time.Location {
+	if d.Timezone == "" {
+		return time.UTC
+	}
+	if location, ok := locations.Load(d.Timezone); ok {
+		return location.(*time.Location)
+	}
+
+	location, err := time.LoadLocation(d.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	locations.Store(d.Timezone, location)
+
+	return location
+}
+
+// ParserKey selects the parser of a message. An empty device type code
+// matches every device type of the protocol.
+type ParserKey struct {
+	Protocol       string
+	DeviceTypeCode string
+}
+
 type DeviceMessageParam struct {
 	DeviceID       string `json:"device_id" form:"device_id"`
 	DeviceTypeCode string `json:"device_type_code" form:"device_type_code"`
@@ -55,4 +118,11 @@ type DeviceMessageInput struct {
 	DeviceTypeCode string `json:"device_type_code"`
 	Message        string `json:"message"`
 	Protocol       string `json:"protocol"`
+	// Reprocessed is set when the worker processes a dead letter again
+	Reprocessed int `json:"-"`
+}
+
+type DeviceMessageOutput struct {
+	ID              *uuid.UUID `json:"id"`
+	Acknowledgement string     `json:"acknowledgement,omitempty"`
 }
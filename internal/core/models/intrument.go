@@ -1,10 +1,16 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/parsers"
+)
 
 type Message interface {
 	Stringify() string
 	Serialize(DeviceMessage) Serializer
+	// ParseDiagnostics lists what the parser could not read from the message
+	ParseDiagnostics() []parsers.Diagnostic
 }
 
 type Serializer struct {
@@ -14,16 +20,114 @@ type Serializer struct {
 	PatientID      string    `json:"patient_id"`
 	Timestamp      time.Time `json:"timestamp"`
 	DeviceTypeCode string    `json:"device_type_code"`
-	Results        []Result  `json:"results"`
+	// message header metadata so the LIS platform can correlate and route
+	MessageType        string   `json:"message_type,omitempty"`
+	MessageControlID   string   `json:"message_control_id,omitempty"`
+	ProcessingID       string   `json:"processing_id,omitempty"`
+	SendingApplication string   `json:"sending_application,omitempty"`
+	SendingFacility    string   `json:"sending_facility,omitempty"`
+	ProtocolVersion    string   `json:"protocol_version,omitempty"`
+	Results            []Result `json:"results"`
+	// Orders groups the same results per order for devices that send several
+	// orders in one message, Results stays the flat list of all of them
+	Orders []Order `json:"orders,omitempty"`
+	// Extras carries device specific values pulled through field mappings
+	Extras map[string]string `json:"extras,omitempty"`
+	// DeviceMessageID links the result to the stored device message, a
+	// message with several specimens is published once per RecordNumber
+	DeviceMessageID string `json:"device_message_id,omitempty"`
+	RecordNumber    int    `json:"record_number,omitempty"`
+	RecordCount     int    `json:"record_count,omitempty"`
+	// Timestamp is the instrument time, ReceivedAt the server clock when the
+	// message arrived and ClockSkew their difference in seconds
+	ReceivedAt  time.Time `json:"received_at"`
+	ClockSkew   int64     `json:"clock_skew"`
+	ClockSkewed bool      `json:"clock_skewed,omitempty"`
+}
+
+type Order struct {
+	PlacerOrderNumber string    `json:"placer_order_number"`
+	FillerOrderNumber string    `json:"filler_order_number"`
+	ServiceCode       string    `json:"service_code"`
+	ServiceName       string    `json:"service_name"`
+	Timestamp         time.Time `json:"timestamp"`
+	Comments          []string  `json:"comments,omitempty"`
+	Results           []Result  `json:"results"`
 }
 
 type Result struct {
-	ParameterCode  string  `json:"parameter_code"`
-	ParameterName  string  `json:"parameter_name"`
-	Value          string  `json:"value"`
-	NumericValue   float64 `json:"numeric_value"`
-	Unit           string  `json:"unit"`
-	Qualitative    string  `json:"qualitative"`
-	ReferenceRange string  `json:"reference_range"`
-	AbnormalFlags  string  `json:"abnormal_flag"`
+	ParameterCode string  `json:"parameter_code"`
+	ParameterName string  `json:"parameter_name"`
+	Value         string  `json:"value"`
+	NumericValue  float64 `json:"numeric_value"`
+	Comparator    string  `json:"comparator,omitempty"`
+	Unit          string  `json:"unit"`
+	Qualitative   string  `json:"qualitative"`
+	// QualitativeCode is the code of a coded (CE, CWE, CNE) value, Qualitative
+	// its display text
+	QualitativeCode string            `json:"qualitative_code,omitempty"`
+	ReferenceRange  string            `json:"reference_range"`
+	AbnormalFlags   string            `json:"abnormal_flag"`
+	Comments        []string          `json:"comments,omitempty"`
+	Extras          map[string]string `json:"extras,omitempty"`
+	// OriginalValue and OriginalUnit keep what the instrument printed when
+	// the unit was normalized to UCUM or the value converted
+	OriginalValue string `json:"original_value,omitempty"`
+	OriginalUnit  string `json:"original_unit,omitempty"`
+}
+
+// FlatResult is one result value with the message it came from, destinations
+// with the flat format receive a list of them instead of a Serializer.
+type FlatResult struct {
+	DeviceMessageID string    `json:"device_message_id,omitempty"`
+	RecordNumber    int       `json:"record_number,omitempty"`
+	DeviceID        string    `json:"device_id"`
+	DeviceTypeCode  string    `json:"device_type_code"`
+	Protocol        string    `json:"protocol"`
+	MessageType     string    `json:"message_type,omitempty"`
+	SequenceNumber  string    `json:"sequence_number"`
+	PatientID       string    `json:"patient_id"`
+	Timestamp       time.Time `json:"timestamp"`
+	ReceivedAt      time.Time `json:"received_at"`
+	ParameterCode   string    `json:"parameter_code"`
+	ParameterName   string    `json:"parameter_name"`
+	Value           string    `json:"value"`
+	NumericValue    float64   `json:"numeric_value"`
+	Comparator      string    `json:"comparator,omitempty"`
+	Unit            string    `json:"unit"`
+	Qualitative     string    `json:"qualitative"`
+	QualitativeCode string    `json:"qualitative_code,omitempty"`
+	ReferenceRange  string    `json:"reference_range"`
+	AbnormalFlags   string    `json:"abnormal_flag"`
+}
+
+// Flatten lists every result of the serializer as its own row.
+func (s Serializer) Flatten() []FlatResult {
+	rows := make([]FlatResult, 0, len(s.Results))
+	for _, result := range s.Results {
+		rows = append(rows, FlatResult{
+			DeviceMessageID: s.DeviceMessageID,
+			RecordNumber:    s.RecordNumber,
+			DeviceID:        s.DeviceID,
+			DeviceTypeCode:  s.DeviceTypeCode,
+			Protocol:        s.Protocol,
+			MessageType:     s.MessageType,
+			SequenceNumber:  s.SequenceNumber,
+			PatientID:       s.PatientID,
+			Timestamp:       s.Timestamp,
+			ReceivedAt:      s.ReceivedAt,
+			ParameterCode:   result.ParameterCode,
+			ParameterName:   result.ParameterName,
+			Value:           result.Value,
+			NumericValue:    result.NumericValue,
+			Comparator:      result.Comparator,
+			Unit:            result.Unit,
+			Qualitative:     result.Qualitative,
+			QualitativeCode: result.QualitativeCode,
+			ReferenceRange:  result.ReferenceRange,
+			AbnormalFlags:   result.AbnormalFlags,
+		})
+	}
+
+	return rows
 }
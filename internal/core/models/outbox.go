@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	OUTBOX_STATUS_PENDING   = "pending"
+	OUTBOX_STATUS_DELIVERED = "delivered"
+	// OUTBOX_STATUS_DEAD entries ran out of attempts, they are kept as a
+	// dead letter
+	OUTBOX_STATUS_DEAD = "dead"
+)
+
+// OutboxEntry is a serialized result waiting to be delivered to one
+// destination, it is written in the same transaction as its device message.
+type OutboxEntry struct {
+	ID              *uuid.UUID `json:"id" gorm:"column:id;type:uuid;primaryKey;default:uuid_generate_v4();"`
+	DeviceMessageID string     `json:"device_message_id" gorm:"column:device_message_id"`
+	// Destination names where the entry goes, a result routed to several
+	// destinations has one entry for each so they are tracked on their own
+	Destination string `json:"destination" gorm:"column:destination"`
+	// Payload is the result in the format of the destination
+	Payload string `json:"payload" gorm:"column:payload"`
+	Status  string `json:"status" gorm:"column:status"`
+	// Attempts counts the delivery attempts, LastError keeps why the latest
+	// one failed
+	Attempts  int    `json:"attempts" gorm:"column:attempts"`
+	LastError string `json:"last_error" gorm:"column:last_error"`
+	// NextAttemptAt is when a pending entry is due, claiming an entry moves
+	// it to the end of the lease
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"column:next_attempt_at"`
+	DeliveredAt   *time.Time `json:"delivered_at" gorm:"column:delivered_at"`
+	Default
+}
+
+func (OutboxEntry) TableName() string {
+	return "outbox_entries"
+}
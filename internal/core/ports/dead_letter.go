@@ -0,0 +1,52 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+type (
+	DeadLetterCommand interface {
+		Create(ctx context.Context, deadLetter *models.DeadLetter) error
+		// Close stores the replayed or discarded status of an open dead
+		// letter, it fails when another request closed it first
+		Close(ctx context.Context, deadLetter *models.DeadLetter) error
+		// Retry stores the attempts, rounds and error chain of an open dead
+		// letter that failed reprocessing
+		Retry(ctx context.Context, deadLetter *models.DeadLetter) error
+		// Purge deletes up to limit dead letters closed before the time
+		Purge(ctx context.Context, before time.Time, limit int) (int, error)
+	}
+
+	DeadLetterQuery interface {
+		Find(ctx context.Context, filter models.DeadLetterFilter) ([]models.DeadLetter, models.Pagination, error)
+		Get(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error)
+		// Due returns up to limit open dead letters unchanged since the time
+		// that were reprocessed fewer than rounds times, oldest first
+		Due(ctx context.Context, rounds int, before time.Time, limit int) ([]models.DeadLetter, error)
+	}
+
+	// DeadLetterService lets support staff recover failed messages and
+	// results without database access.
+	DeadLetterService interface {
+		List(ctx context.Context, filter models.DeadLetterFilter) ([]models.DeadLetter, models.Pagination, error)
+		Get(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error)
+		Replay(ctx context.Context, id uuid.UUID, param models.DeadLetterReplayParam) (*models.DeadLetterReplayOutput, error)
+		Discard(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error)
+		// Reprocess runs one round over the due dead letters and returns
+		// how many it reprocessed
+		Reprocess(ctx context.Context, rounds int, interval time.Duration) (int, error)
+	}
+
+	DeadLetterHdl interface {
+		RouterV1
+		List(ctx echo.Context) error
+		Get(ctx echo.Context) error
+		Replay(ctx echo.Context) error
+		Discard(ctx echo.Context) error
+	}
+)
@@ -13,7 +13,14 @@ type (
 	}
 
 	DeviceMessageService interface {
-		Process(ctx context.Context, inputs *models.DeviceMessageInput) (err error)
+		Process(ctx context.Context, inputs *models.DeviceMessageInput) (output *models.DeviceMessageOutput, err error)
+	}
+
+	// MessageParser turns a stored device message into results for the
+	// protocol and device type pairs it registers for, one per specimen.
+	MessageParser interface {
+		Keys() []models.ParserKey
+		Parse(deviceMessage models.DeviceMessage) ([]models.Message, error)
 	}
 
 	DeviceMessageHdl interface {
@@ -0,0 +1,31 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/models"
+)
+
+type (
+	OutboxCommand interface {
+		Create(ctx context.Context, entries []models.OutboxEntry) error
+		// Claim returns up to limit pending entries due at now and pushes
+		// their next attempt to the end of the lease, entries claimed by
+		// another dispatcher are skipped
+		Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.OutboxEntry, error)
+		Update(ctx context.Context, entry *models.OutboxEntry) error
+		// Purge deletes up to limit entries delivered before the time
+		Purge(ctx context.Context, before time.Time, limit int) (int, error)
+	}
+
+	// OutboxDispatcher delivers outbox entries to their destination and
+	// records the outcome of every attempt.
+	OutboxDispatcher interface {
+		Deliver(ctx context.Context, entry *models.OutboxEntry) error
+		// Redeliver makes one more attempt at an entry that ran out of
+		// attempts, only a success is recorded on the entry
+		Redeliver(ctx context.Context, entry *models.OutboxEntry) error
+		Dispatch(ctx context.Context) (attempted int, err error)
+	}
+)
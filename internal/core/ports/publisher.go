@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+)
+
+type (
+	// ResultPublisher delivers an outbox entry to a destination, the
+	// dispatcher picks the publisher by the scheme of the destination url.
+	// Publish returns once the receiver accepted the entry so a nil error
+	// marks it delivered. Close releases the connections it keeps open.
+	ResultPublisher interface {
+		Schemes() []string
+		Publish(ctx context.Context, destination configurations.Destination, entry models.OutboxEntry) error
+		Close() error
+	}
+)
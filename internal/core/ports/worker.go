@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// WorkerTask is a background processor run by the worker every interval,
+	// a run that processed records is repeated right away to drain a backlog.
+	WorkerTask interface {
+		Name() string
+		Interval() time.Duration
+		Run(ctx context.Context) (processed int, err error)
+	}
+)
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/parsers"
+	"github.com/Calmantara/lis-backend/internal/utils"
+)
+
+type ASTMTestResult struct {
+	parsers.ASTMResult
+}
+
+func (s *ASTMTestResult) Stringify() string {
+	b, _ := json.Marshal(s)
+
+	return string(b)
+}
+
+func (s *ASTMTestResult) ParseDiagnostics() []parsers.Diagnostic {
+	return s.Diagnostics
+}
+
+func (s *ASTMTestResult) Serialize(deviceMessage models.DeviceMessage) models.Serializer {
+	res := models.Serializer{
+		DeviceID:       deviceMessage.DeviceID,
+		Protocol:       string(deviceMessage.Protocol),
+		DeviceTypeCode: deviceMessage.DeviceTypeCode,
+		Timestamp:      parseISOTimestamp(s.Header.DateTime, deviceMessage.Location()),
+		// message header
+		MessageControlID:   s.Header.ControlID,
+		ProcessingID:       s.Header.ProcessingID,
+		SendingApplication: s.Header.Sender,
+		ProtocolVersion:    s.Header.Version,
+	}
+
+	// Parse splits the message per specimen, so there is at most one patient
+	// with one order
+	for _, patient := range s.Patients {
+		res.PatientID = firstNonEmpty(patient.LaboratoryID, patient.PracticeID, patient.PatientID3)
+
+		for _, order := range patient.Orders {
+			// the specimen identifies the message like the rs232 parsers
+			if order.SpecimenID != "" {
+				res.SequenceNumber = strconv.Itoa(utils.FindAllInteger(order.SpecimenID))
+				if res.PatientID == "" {
+					res.PatientID = order.SpecimenID
+				}
+			}
+
+			serialized := models.Order{
+				PlacerOrderNumber: order.SpecimenID,
+				FillerOrderNumber: order.InstrumentSpecimenID,
+				ServiceCode:       strings.Join(order.TestCodes, ","),
+				Timestamp:         parseISOTimestamp(order.CollectionDatetime, deviceMessage.Location()),
+				Comments:          order.Comments,
+				Results:           []models.Result{},
+			}
+			for _, observation := range order.Results {
+				result := models.Result{
+					ParameterCode:  observation.Code,
+					ParameterName:  observation.Name,
+					Value:          observation.Value,
+					Comparator:     observation.Comparator,
+					Unit:           observation.Units,
+					ReferenceRange: observation.ReferenceRange,
+					AbnormalFlags:  observation.AbnormalFlags,
+					Comments:       observation.Comments,
+				}
+				if observation.NumericValue != nil {
+					result.NumericValue = *observation.NumericValue
+				}
+				serialized.Results = append(serialized.Results, result)
+				res.Results = append(res.Results, result)
+			}
+			res.Orders = append(res.Orders, serialized)
+		}
+	}
+
+	return res
+}
+
+func parseASTMMessage(deviceMessage models.DeviceMessage) (res *ASTMTestResult, err error) {
+	parsedMessage, err := parsers.ParseASTMMessage(strings.TrimSpace(deviceMessage.Message))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ASTMTestResult{ASTMResult: parsedMessage}, nil
+}
+
+// setASTMHeader copies the H record metadata to the stored message, leaving
+// the columns empty when the header cannot be read.
+func setASTMHeader(deviceMessage *models.DeviceMessage) {
+	header, err := parsers.ParseASTMHeader(deviceMessage.Message)
+	if err != nil {
+		return
+	}
+
+	deviceMessage.MessageControlID = header.ControlID
+	deviceMessage.ProcessingID = header.ProcessingID
+	deviceMessage.SendingApplication = header.Sender
+	deviceMessage.ProtocolVersion = header.Version
+	deviceMessage.InstrumentTime = parseInstrumentTime(header.DateTime, deviceMessage.Location())
+}
+
+// parseISOTimestamp reads the RFC3339, local or date only strings produced by
+// the parsers, the location of the device places those without an offset.
+// It returns the zero time otherwise.
+func parseISOTimestamp(value string, location *time.Location) time.Time {
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp
+	}
+	if timestamp, err := time.ParseInLocation(parsers.LOCAL_DATETIME, value, location); err == nil {
+		return timestamp
+	}
+	timestamp, err := time.ParseInLocation(time.DateOnly, value, location)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return timestamp
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+type astmParser struct{}
+
+// NewASTMParser parses ASTM E1394 messages of every device type.
+func NewASTMParser() ports.MessageParser {
+	return &astmParser{}
+}
+
+func (p *astmParser) Keys() []models.ParserKey {
+	return []models.ParserKey{{Protocol: models.PROTOCOL_ASTM}}
+}
+
+func (p *astmParser) Parse(deviceMessage models.DeviceMessage) ([]models.Message, error) {
+	res, err := parseASTMMessage(deviceMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.split(), nil
+}
+
+// split returns one result per specimen with its own patient, so the results
+// of a batch are never published under another patient. Diagnostics and
+// queries stay with the first one. A message without specimens, such as a
+// host query, has nothing to publish.
+func (s *ASTMTestResult) split() []models.Message {
+	messages := []models.Message{}
+	for _, patient := range s.Patients {
+		for _, order := range patient.Orders {
+			specimen := &ASTMTestResult{ASTMResult: parsers.ASTMResult{
+				Header:          s.Header,
+				TerminationCode: s.TerminationCode,
+			}}
+			specimen.Patients = []parsers.ASTMPatient{patient}
+			specimen.Patients[0].Orders = []parsers.ASTMOrder{order}
+			if len(messages) == 0 {
+				specimen.Queries = s.Queries
+				specimen.Diagnostics = s.Diagnostics
+			}
+			messages = append(messages, specimen)
+		}
+	}
+
+	return messages
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestASTMParserSplitsPatients(t *testing.T) {
+	msg, err := os.ReadFile("testdata/astm/olympus_au_multi_patient.astm")
+	assert.NoError(t, err)
+	deviceMessage := models.DeviceMessage{
+		DeviceID: "au-1",
+		Protocol: models.PROTOCOL_ASTM,
+		Message:  string(msg),
+	}
+
+	messages, err := NewASTMParser().Parse(deviceMessage)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+
+	// the results of every patient stay under that patient
+	first := messages[0].Serialize(deviceMessage)
+	assert.Equal(t, "MRN-2001", first.PatientID)
+	assert.Equal(t, "3301", first.SequenceNumber)
+	assert.Equal(t, "MSG-77", first.MessageControlID)
+	assert.Len(t, first.Orders, 1)
+	assert.Equal(t, []string{"GLU", "ALB"}, []string{first.Results[0].ParameterCode, first.Results[1].ParameterCode})
+
+	second := messages[1].Serialize(deviceMessage)
+	assert.Equal(t, "MRN-2002", second.PatientID)
+	assert.Equal(t, "3302", second.SequenceNumber)
+	assert.Len(t, second.Results, 1)
+	assert.Equal(t, "210", second.Results[0].Value)
+}
+
+func TestASTMParserSplitsSpecimens(t *testing.T) {
+	deviceMessage := models.DeviceMessage{
+		DeviceID: "ca-1",
+		Protocol: models.PROTOCOL_ASTM,
+		Message: "H|\\^&|||CA-660|||||LIS||P|1394-97|20250801093012\r" +
+			"P|1||MRN-1\r" +
+			"O|1|SPC-1||^^^PT\r" +
+			"R|1|^^^PT|12.1|s\r" +
+			"O|2|SPC-2||^^^APTT\r" +
+			"R|1|^^^APTT|31.0|s\r" +
+			"O|3|||^^^FIB\r" +
+			"L|1|N",
+	}
+
+	messages, err := NewASTMParser().Parse(deviceMessage)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 3)
+
+	// the diagnostics of the batch are reported once
+	diagnostics := 0
+	for i, message := range messages {
+		serializer := message.Serialize(deviceMessage)
+		assert.Equal(t, "MRN-1", serializer.PatientID)
+		assert.Len(t, serializer.Orders, 1)
+		diagnostics += len(message.ParseDiagnostics())
+		if i < 2 {
+			assert.Len(t, serializer.Results, 1)
+		}
+	}
+	assert.Equal(t, 1, diagnostics)
+	assert.Equal(t, "APTT", messages[1].Serialize(deviceMessage).Results[0].ParameterCode)
+}
+
+func TestASTMQueryIsNotPublished(t *testing.T) {
+	msg, err := os.ReadFile("../../helpers/parsers/testdata/astm/sysmex_xn_query.astm")
+	assert.NoError(t, err)
+	service := newTestDeviceMessageService(t, "http://lis.local", DeviceMessageServiceInput{
+		Parsers: []ports.MessageParser{NewASTMParser()},
+	})
+
+	// a host query is stored but has no result for any destination
+	output, err := service.Process(context.Background(), &models.DeviceMessageInput{
+		DeviceID: "xn-1",
+		Protocol: models.PROTOCOL_ASTM,
+		Message:  string(msg),
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, output.ID)
+	assert.Len(t, service.command.created, 1)
+	assert.Empty(t, service.outbox.list())
+	assert.Empty(t, service.deadLetters.list())
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/charset"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+)
+
+// charsetDecoder transcodes instrument payloads to UTF-8 before they are
+// parsed and stored.
+type charsetDecoder struct {
+	config configurations.Charset
+}
+
+func newCharsetDecoder(config configurations.Charset) (*charsetDecoder, error) {
+	for deviceID, name := range config.Devices {
+		if _, _, err := charset.Lookup(name); err != nil {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_CHARSET_CONFIG, "charset %q of %s", name, deviceID)
+		}
+	}
+
+	return &charsetDecoder{config: config}, nil
+}
+
+// decode picks the charset configured for the device, then the one declared
+// in MSH-18, then UTF-8 when the bytes are valid and the fallback otherwise.
+// The received bytes are kept when transcoding changed them.
+func (c *charsetDecoder) decode(deviceMessage *models.DeviceMessage) {
+	raw := []byte(deviceMessage.Message)
+
+	name := c.config.Device(deviceMessage.DeviceID)
+	if name == "" && deviceMessage.Protocol == models.PROTOCOL_HL7 {
+		if declared := charset.DeclaredHL7(raw); declared != "" {
+			if _, _, err := charset.Lookup(declared); err == nil {
+				name = declared
+			} else {
+				utils.Log.Infow("ignoring unsupported declared charset", map[string]any{
+					"device_id": deviceMessage.DeviceID,
+					"charset":   declared,
+				})
+			}
+		}
+	}
+	if name == "" {
+		name = charset.UTF8
+		if !charset.Valid(raw) {
+			name = c.config.Fallback()
+		}
+	}
+
+	_, canonical, err := charset.Lookup(name)
+	if err != nil {
+		// the fallback of an empty configuration is always supported
+		canonical = charset.UTF8
+	}
+	deviceMessage.Charset = canonical
+
+	decoded, err := charset.Decode(raw, canonical)
+	if err != nil || decoded == deviceMessage.Message {
+		return
+	}
+	deviceMessage.RawMessage = raw
+	deviceMessage.Message = decoded
+}
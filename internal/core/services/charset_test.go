@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCharsetDecoder(t *testing.T) {
+	// unsupported declarations are logged
+	utils.NewZap()
+	decoder, err := newCharsetDecoder(configurations.Charset{
+		Devices: map[string]string{"hl7-override": "windows-1252", "*": "ISO-8859-1"},
+	})
+	assert.NoError(t, err)
+
+	msh := "MSH|^~\\&|LAB|FAC|LIS|FAC|20250304091530||ORU^R01|1|P|2.5|||||IDN|"
+	for _, tc := range []struct {
+		name     string
+		message  models.DeviceMessage
+		charset  string
+		expected string
+	}{
+		{
+			name:     "declared in MSH-18",
+			message:  models.DeviceMessage{DeviceID: "hl7-1", Protocol: models.PROTOCOL_HL7, Message: msh + "GB 18030\rPID|1||||\xd5\xc5^\xc8\xfd"},
+			charset:  "GB18030",
+			expected: msh + "GB 18030\rPID|1||||张^三",
+		},
+		{
+			name:     "device wins over MSH-18",
+			message:  models.DeviceMessage{DeviceID: "hl7-override", Protocol: models.PROTOCOL_HL7, Message: msh + "UNICODE UTF-8\rOBX|1|NM|GLU||5.4|\xb5mol/L"},
+			charset:  "windows-1252",
+			expected: msh + "UNICODE UTF-8\rOBX|1|NM|GLU||5.4|µmol/L",
+		},
+		{
+			name:     "unsupported declaration falls back",
+			message:  models.DeviceMessage{DeviceID: "hl7-1", Protocol: models.PROTOCOL_HL7, Message: msh + "ISO IR159\rPID|1||||Jos\xe9"},
+			charset:  "ISO-8859-1",
+			expected: msh + "ISO IR159\rPID|1||||José",
+		},
+		{
+			name:     "valid UTF-8 is kept",
+			message:  models.DeviceMessage{DeviceID: "urine-1", Protocol: models.PROTOCOL_RS232, Message: "NO.0012\nUBG 3.2 µmol/L"},
+			charset:  "UTF-8",
+			expected: "NO.0012\nUBG 3.2 µmol/L",
+		},
+		{
+			name:     "undeclared invalid UTF-8 uses the fallback",
+			message:  models.DeviceMessage{DeviceID: "urine-1", Protocol: models.PROTOCOL_RS232, Message: "NO.0012\nUBG 3.2 \xb5mol/L"},
+			charset:  "ISO-8859-1",
+			expected: "NO.0012\nUBG 3.2 µmol/L",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			deviceMessage := tc.message
+			decoder.decode(&deviceMessage)
+			assert.Equal(t, tc.charset, deviceMessage.Charset)
+			assert.Equal(t, tc.expected, deviceMessage.Message)
+			if tc.expected == tc.message.Message {
+				assert.Nil(t, deviceMessage.RawMessage)
+			} else {
+				assert.Equal(t, []byte(tc.message.Message), deviceMessage.RawMessage)
+			}
+		})
+	}
+}
+
+func TestCharsetDecoderInvalidConfig(t *testing.T) {
+	_, err := newCharsetDecoder(configurations.Charset{Devices: map[string]string{"urine-1": "EBCDIC-XYZ"}})
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_CHARSET_CONFIG)
+}
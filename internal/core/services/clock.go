@@ -0,0 +1,74 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// deviceClock places the naive timestamps of a device in its timezone and
+// compares the instrument clock with the server clock.
+type deviceClock struct {
+	config configurations.Clock
+	now    func() time.Time
+}
+
+func newDeviceClock(config configurations.Clock) (*deviceClock, error) {
+	for deviceID, timezone := range config.Timezones {
+		if _, err := time.LoadLocation(strings.TrimSpace(timezone)); err != nil {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_CLOCK_CONFIG, "timezone %q of %s", timezone, deviceID)
+		}
+	}
+
+	return &deviceClock{config: config, now: time.Now}, nil
+}
+
+// receive stamps the message with the device timezone and the server time.
+func (c *deviceClock) receive(deviceMessage *models.DeviceMessage) {
+	deviceMessage.Timezone = c.config.Timezone(deviceMessage.DeviceID)
+	deviceMessage.ReceivedAt = c.now()
+}
+
+// measure falls back to the latest result time when the header carries no
+// instrument time, then flags a skew above the threshold on the message and
+// its results.
+func (c *deviceClock) measure(deviceMessage *models.DeviceMessage, serializers []models.Serializer) {
+	if deviceMessage.InstrumentTime == nil {
+		for _, serializer := range serializers {
+			if timestamp := serializer.Timestamp; !timestamp.IsZero() &&
+				(deviceMessage.InstrumentTime == nil || timestamp.After(*deviceMessage.InstrumentTime)) {
+				deviceMessage.InstrumentTime = &timestamp
+			}
+		}
+	}
+
+	if deviceMessage.InstrumentTime != nil {
+		skew := deviceMessage.InstrumentTime.Sub(deviceMessage.ReceivedAt)
+		threshold := c.config.SkewThreshold
+		deviceMessage.ClockSkew = int64(skew.Round(time.Second) / time.Second)
+		deviceMessage.ClockSkewed = threshold > 0 && (skew > threshold || skew < -threshold)
+	}
+
+	for i := range serializers {
+		serializers[i].ReceivedAt = deviceMessage.ReceivedAt
+		serializers[i].ClockSkew = deviceMessage.ClockSkew
+		serializers[i].ClockSkewed = deviceMessage.ClockSkewed
+	}
+}
+
+// parseInstrumentTime reads the header time of a message, a date without a
+// time tells nothing about the instrument clock.
+func parseInstrumentTime(value string, location *time.Location) *time.Time {
+	if len(value) <= len(time.DateOnly) {
+		return nil
+	}
+	timestamp := parseISOTimestamp(value, location)
+	if timestamp.IsZero() {
+		return nil
+	}
+
+	return &timestamp
+}
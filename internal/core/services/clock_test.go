@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeviceClock(t *testing.T) {
+	clock, err := newDeviceClock(configurations.Clock{
+		Timezones:     map[string]string{"urine-1": "Asia/Jakarta"},
+		SkewThreshold: 5 * time.Minute,
+	})
+	assert.NoError(t, err)
+	receivedAt := time.Date(2025, 3, 4, 7, 0, 0, 0, time.UTC)
+	clock.now = func() time.Time { return receivedAt }
+
+	// naive timestamps are read in the timezone of the device
+	deviceMessage := &models.DeviceMessage{DeviceID: "urine-1"}
+	clock.receive(deviceMessage)
+	assert.Equal(t, "Asia/Jakarta", deviceMessage.Timezone)
+	results, err := parseUrineTestText("NO.0012 2025-03-04\n14:00:00\nLEU -\n", deviceMessage.Location())
+	assert.NoError(t, err)
+	assert.Equal(t, receivedAt, results[0].DateTime.UTC())
+
+	serializers := []models.Serializer{results[0].Serialize(*deviceMessage)}
+	clock.measure(deviceMessage, serializers)
+	assert.Equal(t, int64(0), deviceMessage.ClockSkew)
+	assert.False(t, deviceMessage.ClockSkewed)
+	assert.Equal(t, receivedAt, serializers[0].ReceivedAt)
+
+	// other devices fall back to UTC, seven hours ahead of the server
+	deviceMessage = &models.DeviceMessage{DeviceID: "urine-2"}
+	clock.receive(deviceMessage)
+	assert.Equal(t, "UTC", deviceMessage.Timezone)
+	results, err = parseUrineTestText("NO.0013 2025-03-04\n14:00:00\nLEU -\n", deviceMessage.Location())
+	assert.NoError(t, err)
+
+	serializers = []models.Serializer{results[0].Serialize(*deviceMessage)}
+	clock.measure(deviceMessage, serializers)
+	assert.Equal(t, int64(7*60*60), deviceMessage.ClockSkew)
+	assert.True(t, deviceMessage.ClockSkewed)
+	assert.Equal(t, int64(7*60*60), serializers[0].ClockSkew)
+	assert.True(t, serializers[0].ClockSkewed)
+
+	// a header time wins over the result times, a date alone is ignored
+	assert.Nil(t, parseInstrumentTime("2025-03-04", time.UTC))
+	instrumentTime := parseInstrumentTime("2025-03-04T07:01:00", time.UTC)
+	deviceMessage = &models.DeviceMessage{InstrumentTime: instrumentTime, ReceivedAt: receivedAt}
+	clock.measure(deviceMessage, serializers)
+	assert.Equal(t, int64(60), deviceMessage.ClockSkew)
+	assert.False(t, deviceMessage.ClockSkewed)
+}
+
+func TestDeviceClockInvalidConfig(t *testing.T) {
+	_, err := newDeviceClock(configurations.Clock{Timezones: map[string]string{"*": "Mars/Olympus"}})
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_CLOCK_CONFIG)
+}
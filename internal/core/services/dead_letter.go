@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/google/uuid"
+	"go.uber.org/dig"
+)
+
+type deadLetterSvcImpl struct {
+	deadLetterCommand    ports.DeadLetterCommand
+	deadLetterQuery      ports.DeadLetterQuery
+	outboxCommand        ports.OutboxCommand
+	outboxDispatcher     ports.OutboxDispatcher
+	deviceMessageService ports.DeviceMessageService
+	transaction          ports.Transaction
+	outboxConfig         configurations.Outbox
+	now                  func() time.Time
+}
+
+type DeadLetterServiceInput struct {
+	dig.In
+	DeadLetterCommand    ports.DeadLetterCommand
+	DeadLetterQuery      ports.DeadLetterQuery
+	OutboxCommand        ports.OutboxCommand
+	OutboxDispatcher     ports.OutboxDispatcher
+	DeviceMessageService ports.DeviceMessageService
+	Transaction          ports.Transaction
+	Outbox               configurations.Outbox
+}
+
+func NewDeadLetterService(input DeadLetterServiceInput) ports.DeadLetterService {
+	return &deadLetterSvcImpl{
+		deadLetterCommand:    input.DeadLetterCommand,
+		deadLetterQuery:      input.DeadLetterQuery,
+		outboxCommand:        input.OutboxCommand,
+		outboxDispatcher:     input.OutboxDispatcher,
+		deviceMessageService: input.DeviceMessageService,
+		transaction:          input.Transaction,
+		outboxConfig:         outboxDefaults(input.Outbox),
+		now:                  time.Now,
+	}
+}
+
+// newDeadLetter opens a dead letter keeping the error chain of its failure.
+func newDeadLetter(reason string, cause error) *models.DeadLetter {
+	id := uuid.New()
+
+	return &models.DeadLetter{
+		ID:     &id,
+		Reason: reason,
+		Status: models.DEAD_LETTER_STATUS_OPEN,
+		Errors: errors.Unpack(cause),
+	}
+}
+
+func (d *deadLetterSvcImpl) List(ctx context.Context, filter models.DeadLetterFilter) ([]models.DeadLetter, models.Pagination, error) {
+	return d.deadLetterQuery.Find(ctx, filter)
+}
+
+func (d *deadLetterSvcImpl) Get(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error) {
+	return d.deadLetterQuery.Get(ctx, id)
+}
+
+// Replay sends the payload, edited or not, through the step that failed. A
+// device message is processed again as a new message, a failed replay is
+// dead-lettered on its own. A result is queued as a new outbox entry.
+func (d *deadLetterSvcImpl) Replay(ctx context.Context, id uuid.UUID, param models.DeadLetterReplayParam) (*models.DeadLetterReplayOutput, error) {
+	deadLetter, err := d.open(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := param.Validate(*deadLetter); err != nil {
+		return nil, err
+	}
+
+	now := d.now()
+	payload := deadLetter.Payload
+	if param.Payload != "" {
+		payload = param.Payload
+		deadLetter.ReplayedPayload = param.Payload
+	}
+	deadLetter.Status = models.DEAD_LETTER_STATUS_REPLAYED
+	deadLetter.ReplayedAt = &now
+	output := &models.DeadLetterReplayOutput{}
+
+	if deadLetter.Reason == models.DEAD_LETTER_REASON_PARSE_FAILED {
+		if err := d.deadLetterCommand.Close(ctx, deadLetter); err != nil {
+			return nil, err
+		}
+		output.DeadLetter = *deadLetter
+		output.DeviceMessage, err = d.deviceMessageService.Process(ctx, &models.DeviceMessageInput{
+			DeviceID:       deadLetter.DeviceID,
+			DeviceTypeCode: deadLetter.DeviceTypeCode,
+			Protocol:       deadLetter.Protocol,
+			Message:        payload,
+		})
+
+		return output, err
+	}
+
+	entryID := uuid.New()
+	entry := models.OutboxEntry{
+		ID:              &entryID,
+		DeviceMessageID: deadLetter.DeviceMessageID,
+		Destination:     deadLetter.Destination,
+		Payload:         payload,
+		Status:          models.OUTBOX_STATUS_PENDING,
+		NextAttemptAt:   now.Add(d.outboxConfig.Lease),
+	}
+	txnCtx := d.transaction.Begin(ctx)
+	err = d.deadLetterCommand.Close(txnCtx, deadLetter)
+	if err == nil {
+		err = d.outboxCommand.Create(txnCtx, []models.OutboxEntry{entry})
+	}
+	if endErr := d.transaction.End(txnCtx, &err); err == nil {
+		err = endErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a failed delivery is retried by the worker like any other entry
+	entries := []models.OutboxEntry{entry}
+	deliverInline(ctx, d.outboxDispatcher, d.outboxConfig, entries)
+	output.DeadLetter = *deadLetter
+	output.OutboxEntry = &entries[0]
+
+	return output, nil
+}
+
+// Discard closes the dead letter without replaying it, it is kept for audit.
+func (d *deadLetterSvcImpl) Discard(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error) {
+	deadLetter, err := d.open(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := d.now()
+	deadLetter.Status = models.DEAD_LETTER_STATUS_DISCARDED
+	deadLetter.DiscardedAt = &now
+	if err := d.deadLetterCommand.Close(ctx, deadLetter); err != nil {
+		return nil, err
+	}
+
+	return deadLetter, nil
+}
+
+// Reprocess gives the dead letters unchanged for an interval another round,
+// a message that failed parsing is processed again and a result that ran out
+// of delivery attempts gets one more. A dead letter is left to support staff
+// once it failed the given rounds.
+func (d *deadLetterSvcImpl) Reprocess(ctx context.Context, rounds int, interval time.Duration) (int, error) {
+	deadLetters, err := d.deadLetterQuery.Due(ctx, rounds, d.now().Add(-interval), d.outboxConfig.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	reprocessed := 0
+	for i := range deadLetters {
+		if err := ctx.Err(); err != nil {
+			return reprocessed, err
+		}
+
+		deadLetter := &deadLetters[i]
+		if deadLetter.Reason == models.DEAD_LETTER_REASON_PARSE_FAILED {
+			err = d.reprocessMessage(ctx, deadLetter)
+		} else {
+			err = d.redeliver(ctx, deadLetter)
+		}
+		if err != nil {
+			utils.Log.Errorw("failed to reprocess dead letter", map[string]any{
+				"dead_letter_id": deadLetter.ID.String(),
+				"reason":         deadLetter.Reason,
+				"reprocessed":    deadLetter.Reprocessed,
+				"error":          err.Error(),
+			})
+		}
+		reprocessed++
+	}
+
+	return reprocessed, nil
+}
+
+// reprocessMessage replays the message, a new failure is dead-lettered with
+// the rounds it already went through.
+func (d *deadLetterSvcImpl) reprocessMessage(ctx context.Context, deadLetter *models.DeadLetter) error {
+	now := d.now()
+	deadLetter.Status = models.DEAD_LETTER_STATUS_REPLAYED
+	deadLetter.ReplayedAt = &now
+	if err := d.deadLetterCommand.Close(ctx, deadLetter); err != nil {
+		return err
+	}
+
+	_, err := d.deviceMessageService.Process(ctx, &models.DeviceMessageInput{
+		DeviceID:       deadLetter.DeviceID,
+		DeviceTypeCode: deadLetter.DeviceTypeCode,
+		Protocol:       deadLetter.Protocol,
+		Message:        deadLetter.Payload,
+		Reprocessed:    deadLetter.Reprocessed + 1,
+	})
+
+	return err
+}
+
+// redeliver sends the result again as the outbox entry that ran out of
+// attempts, a failure is counted on the dead letter that stays open.
+func (d *deadLetterSvcImpl) redeliver(ctx context.Context, deadLetter *models.DeadLetter) error {
+	entryID, err := uuid.Parse(deadLetter.OutboxEntryID)
+	if err != nil {
+		err = errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "outbox entry %q", deadLetter.OutboxEntryID)
+	}
+	if err == nil {
+		err = d.outboxDispatcher.Redeliver(ctx, &models.OutboxEntry{
+			ID:              &entryID,
+			DeviceMessageID: deadLetter.DeviceMessageID,
+			Destination:     deadLetter.Destination,
+			Payload:         deadLetter.Payload,
+			Status:          models.OUTBOX_STATUS_DEAD,
+			Attempts:        deadLetter.Attempts,
+			NextAttemptAt:   d.now(),
+		})
+	}
+
+	// the outcome is recorded even when the round ran out of time
+	ctx = context.WithoutCancel(ctx)
+	if err != nil {
+		deadLetter.Attempts++
+		deadLetter.Reprocessed++
+		deadLetter.Errors = errors.Unpack(err)
+		if retryErr := d.deadLetterCommand.Retry(ctx, deadLetter); retryErr != nil {
+			return retryErr
+		}
+
+		return err
+	}
+
+	now := d.now()
+	deadLetter.Status = models.DEAD_LETTER_STATUS_REPLAYED
+	deadLetter.ReplayedAt = &now
+
+	return d.deadLetterCommand.Close(ctx, deadLetter)
+}
+
+func (d *deadLetterSvcImpl) open(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error) {
+	deadLetter, err := d.deadLetterQuery.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if deadLetter.Status != models.DEAD_LETTER_STATUS_OPEN {
+		return nil, errors.Wrapf(errors.ERROR_DEAD_LETTER_CLOSED, "dead letter %s is %s", id, deadLetter.Status)
+	}
+
+	return deadLetter, nil
+}
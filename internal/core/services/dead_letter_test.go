@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDeadLetterService(t *testing.T, url string) (*deadLetterSvcImpl, testDeviceMessageService) {
+	deviceMessageService := newTestDeviceMessageService(t, url, DeviceMessageServiceInput{
+		Parsing: configurations.Parsing{StrictDevices: []string{"*"}},
+	})
+	service := NewDeadLetterService(DeadLetterServiceInput{
+		DeadLetterCommand:    deviceMessageService.deadLetters,
+		DeadLetterQuery:      deviceMessageService.deadLetters,
+		OutboxCommand:        deviceMessageService.outbox,
+		OutboxDispatcher:     deviceMessageService.dispatcher,
+		DeviceMessageService: deviceMessageService,
+		Transaction:          &mockTransaction{},
+	}).(*deadLetterSvcImpl)
+
+	return service, deviceMessageService
+}
+
+func TestDeadLetterReplayParseFailure(t *testing.T) {
+	var mx sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mx.Lock()
+		bodies = append(bodies, string(body))
+		mx.Unlock()
+	}))
+	defer server.Close()
+
+	service, deviceMessages := newTestDeadLetterService(t, server.URL)
+	input := &models.DeviceMessageInput{
+		DeviceID:       "urine-1",
+		DeviceTypeCode: URINE_DEVICE_TYPE_CODE,
+		Protocol:       models.PROTOCOL_RS232,
+		Message:        "NO.0012 2025-03-04\n09:15:30\nLEU -\nSG         high\n",
+	}
+	_, err := deviceMessages.Process(context.Background(), input)
+	assert.ErrorIs(t, err, errors.ERROR_PARSE_DIAGNOSTICS)
+
+	deadLetters, page, err := service.List(context.Background(), models.DeadLetterFilter{Status: models.DEAD_LETTER_STATUS_OPEN})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.TotalData)
+	id := *deadLetters[0].ID
+
+	// replaying it unchanged fails again and is dead-lettered on its own
+	output, err := service.Replay(context.Background(), id, models.DeadLetterReplayParam{})
+	assert.ErrorIs(t, err, errors.ERROR_PARSE_DIAGNOSTICS)
+	assert.Equal(t, models.DEAD_LETTER_STATUS_REPLAYED, output.DeadLetter.Status)
+	assert.Len(t, deviceMessages.deadLetters.list(), 2)
+
+	// a closed dead letter cannot be replayed twice
+	_, err = service.Replay(context.Background(), id, models.DeadLetterReplayParam{})
+	assert.ErrorIs(t, err, errors.ERROR_DEAD_LETTER_CLOSED)
+
+	// the edited message is processed and published
+	id = *deviceMessages.deadLetters.list()[1].ID
+	edited := "NO.0012 2025-03-04\n09:15:30\nLEU -\nSG         1.015\n"
+	output, err = service.Replay(context.Background(), id, models.DeadLetterReplayParam{Payload: edited})
+	assert.NoError(t, err)
+	assert.Equal(t, edited, output.DeadLetter.ReplayedPayload)
+	assert.NotNil(t, output.DeviceMessage.ID)
+	assert.Len(t, bodies, 1)
+	assert.Contains(t, bodies[0], `"device_id":"urine-1"`)
+
+	replayed, err := service.Get(context.Background(), id)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DEAD_LETTER_STATUS_REPLAYED, replayed.Status)
+	assert.Equal(t, input.Message, replayed.Payload)
+	assert.NotNil(t, replayed.ReplayedAt)
+}
+
+func TestDeadLetterReplayDeliveryFailure(t *testing.T) {
+	var mx sync.Mutex
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mx.Lock()
+		bodies = append(bodies, string(body))
+		mx.Unlock()
+	}))
+	defer server.Close()
+
+	service, deviceMessages := newTestDeadLetterService(t, server.URL)
+	deadLetter := newDeadLetter(models.DEAD_LETTER_REASON_DELIVERY_EXHAUSTED, errors.ERROR_DELIVERY_FAILED)
+	deadLetter.DeviceMessageID = "message-1"
+	deadLetter.Payload = `{"device_id":"urine-1","patient_id":""}`
+	assert.NoError(t, deviceMessages.deadLetters.Create(context.Background(), deadLetter))
+
+	// edited results must stay JSON
+	_, err := service.Replay(context.Background(), *deadLetter.ID, models.DeadLetterReplayParam{Payload: "not json"})
+	assert.ErrorIs(t, err, errors.ERROR_BAD_REQUEST)
+
+	edited := `{"device_id":"urine-1","patient_id":"P-42"}`
+	output, err := service.Replay(context.Background(), *deadLetter.ID, models.DeadLetterReplayParam{Payload: edited})
+	assert.NoError(t, err)
+	assert.Equal(t, models.OUTBOX_STATUS_DELIVERED, output.OutboxEntry.Status)
+	assert.Equal(t, "message-1", output.OutboxEntry.DeviceMessageID)
+	assert.Equal(t, []string{edited}, bodies)
+
+	entries := deviceMessages.outbox.list()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, models.OUTBOX_STATUS_DELIVERED, entries[0].Status)
+}
+
+func TestDeadLetterReprocessParseFailure(t *testing.T) {
+	service, deviceMessages := newTestDeadLetterService(t, "http://lis.local")
+	_, err := deviceMessages.Process(context.Background(), &models.DeviceMessageInput{
+		DeviceID:       "urine-1",
+		DeviceTypeCode: URINE_DEVICE_TYPE_CODE,
+		Protocol:       models.PROTOCOL_RS232,
+		Message:        "NO.0012 2025-03-04\n09:15:30\nLEU -\nSG         high\n",
+	})
+	assert.ErrorIs(t, err, errors.ERROR_PARSE_DIAGNOSTICS)
+
+	// every round replays the message, its new failure carries the rounds
+	for round := 1; round <= 2; round++ {
+		reprocessed, err := service.Reprocess(context.Background(), 2, time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, reprocessed)
+
+		deadLetters := deviceMessages.deadLetters.list()
+		assert.Len(t, deadLetters, round+1)
+		assert.Equal(t, models.DEAD_LETTER_STATUS_REPLAYED, deadLetters[round-1].Status)
+		assert.Equal(t, models.DEAD_LETTER_STATUS_OPEN, deadLetters[round].Status)
+		assert.Equal(t, round, deadLetters[round].Reprocessed)
+	}
+
+	// out of rounds, the dead letter is left to support staff
+	reprocessed, err := service.Reprocess(context.Background(), 2, time.Minute)
+	assert.NoError(t, err)
+	assert.Zero(t, reprocessed)
+	assert.Len(t, deviceMessages.deadLetters.list(), 3)
+}
+
+func TestDeadLetterReprocessDelivery(t *testing.T) {
+	var mx sync.Mutex
+	accept := false
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mx.Lock()
+		defer mx.Unlock()
+		if !accept {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	service, deviceMessages := newTestDeadLetterService(t, server.URL)
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	entryID := uuid.New()
+	entry := models.OutboxEntry{
+		ID:          &entryID,
+		Destination: configurations.DESTINATION_LIS_PLATFORM,
+		Payload:     `{"device_id":"urine-1"}`,
+		Status:      models.OUTBOX_STATUS_DEAD,
+		Attempts:    20,
+	}
+	assert.NoError(t, deviceMessages.outbox.Create(context.Background(), []models.OutboxEntry{entry}))
+	deadLetter := newDeadLetter(models.DEAD_LETTER_REASON_DELIVERY_EXHAUSTED, errors.ERROR_DELIVERY_FAILED)
+	deadLetter.OutboxEntryID = entryID.String()
+	deadLetter.Destination = entry.Destination
+	deadLetter.Attempts = entry.Attempts
+	deadLetter.Payload = entry.Payload
+	deadLetter.UpdatedAt = now.Add(-time.Hour)
+	assert.NoError(t, deviceMessages.deadLetters.Create(context.Background(), deadLetter))
+
+	// a failed round is counted on the dead letter that stays open
+	reprocessed, err := service.Reprocess(context.Background(), 3, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reprocessed)
+	failed, err := service.Get(context.Background(), *deadLetter.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DEAD_LETTER_STATUS_OPEN, failed.Status)
+	assert.Equal(t, 21, failed.Attempts)
+	assert.Equal(t, 1, failed.Reprocessed)
+	assert.Equal(t, models.OUTBOX_STATUS_DEAD, deviceMessages.outbox.list()[0].Status)
+
+	// the next round delivers the same outbox entry and closes the dead letter
+	mx.Lock()
+	accept = true
+	mx.Unlock()
+	reprocessed, err = service.Reprocess(context.Background(), 3, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reprocessed)
+	replayed, err := service.Get(context.Background(), *deadLetter.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DEAD_LETTER_STATUS_REPLAYED, replayed.Status)
+	assert.Equal(t, []string{entry.Payload}, bodies)
+
+	entries := deviceMessages.outbox.list()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, entryID, *entries[0].ID)
+	assert.Equal(t, models.OUTBOX_STATUS_DELIVERED, entries[0].Status)
+}
+
+func TestDeadLetterDiscard(t *testing.T) {
+	service, deviceMessages := newTestDeadLetterService(t, "http://lis.local")
+	deadLetter := newDeadLetter(models.DEAD_LETTER_REASON_DELIVERY_EXHAUSTED, errors.ERROR_DELIVERY_FAILED)
+	assert.NoError(t, deviceMessages.deadLetters.Create(context.Background(), deadLetter))
+
+	discarded, err := service.Discard(context.Background(), *deadLetter.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.DEAD_LETTER_STATUS_DISCARDED, discarded.Status)
+	assert.NotNil(t, discarded.DiscardedAt)
+
+	_, err = service.Discard(context.Background(), *deadLetter.ID)
+	assert.ErrorIs(t, err, errors.ERROR_DEAD_LETTER_CLOSED)
+	_, err = service.Replay(context.Background(), *deadLetter.ID, models.DeadLetterReplayParam{})
+	assert.ErrorIs(t, err, errors.ERROR_DEAD_LETTER_CLOSED)
+	_, err = service.Get(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, errors.ERROR_NOT_FOUND)
+}
+
+func TestDeadLetterFilterValidate(t *testing.T) {
+	filter := models.DeadLetterFilter{}
+	assert.NoError(t, filter.Validate())
+	assert.Equal(t, models.DEAD_LETTER_STATUS_OPEN, filter.Status)
+	assert.Equal(t, "created_at.desc", filter.SortBy)
+	assert.Equal(t, models.Pagination{Page: 1, Limit: 20}, filter.Pagination)
+
+	filter = models.DeadLetterFilter{SortBy: "attempts.desc,created_at", Pagination: models.Pagination{Page: 2, Limit: 500}}
+	assert.NoError(t, filter.Validate())
+	assert.Equal(t, 20, filter.Limit)
+
+	for _, sortBy := range []string{"payload", "created_at.sideways", "created_at; DROP TABLE dead_letters"} {
+		filter = models.DeadLetterFilter{SortBy: sortBy}
+		assert.ErrorIs(t, filter.Validate(), errors.ERROR_BAD_REQUEST, sortBy)
+	}
+}
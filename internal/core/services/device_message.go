@@ -3,33 +3,91 @@ package services
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"time"
+	"slices"
 
 	"github.com/Calmantara/lis-backend/internal/core/configurations"
 	"github.com/Calmantara/lis-backend/internal/core/models"
 	"github.com/Calmantara/lis-backend/internal/core/ports"
-	"github.com/go-resty/resty/v2"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/helpers/parsers"
+	"github.com/google/uuid"
+	"go.uber.org/dig"
 )
 
 type deviceMessageSvcImpl struct {
 	deviceMessageCommand ports.DeviceMessageCommand
-	client               *resty.Client
-	lisPlatformConfig    configurations.LisPlatform
+	outboxCommand        ports.OutboxCommand
+	outboxDispatcher     ports.OutboxDispatcher
+	deadLetterCommand    ports.DeadLetterCommand
+	transaction          ports.Transaction
+	outboxConfig         configurations.Outbox
+	hl7Config            configurations.HL7
+	parsingConfig        configurations.Parsing
+	parsers              *parserRegistry
+	units                *unitNormalizer
+	clock                *deviceClock
+	charsets             *charsetDecoder
+	router               *resultRouter
 }
 
-func NewDeviceMessageService(
-	deviceMessageCommand ports.DeviceMessageCommand,
-	lisPlatform configurations.LisPlatform,
-) ports.DeviceMessageService {
-	return &deviceMessageSvcImpl{
-		lisPlatformConfig:    lisPlatform,
-		deviceMessageCommand: deviceMessageCommand,
-		client:               setHTTPClient(),
+type DeviceMessageServiceInput struct {
+	dig.In
+	DeviceMessageCommand ports.DeviceMessageCommand
+	OutboxCommand        ports.OutboxCommand
+	OutboxDispatcher     ports.OutboxDispatcher
+	DeadLetterCommand    ports.DeadLetterCommand
+	Transaction          ports.Transaction
+	Outbox               configurations.Outbox
+	HL7                  configurations.HL7
+	Parsing              configurations.Parsing
+	Units                configurations.Units
+	Clock                configurations.Clock
+	Charset              configurations.Charset
+	LisPlatform          configurations.LisPlatform
+	Routing              configurations.Routing
+	Parsers              []ports.MessageParser `group:"messageParsers"`
+}
+
+func NewDeviceMessageService(input DeviceMessageServiceInput) (ports.DeviceMessageService, error) {
+	parsers, err := newParserRegistry(input.Parsers)
+	if err != nil {
+		return nil, err
+	}
+	units, err := newUnitNormalizer(input.Units)
+	if err != nil {
+		return nil, err
+	}
+	clock, err := newDeviceClock(input.Clock)
+	if err != nil {
+		return nil, err
+	}
+	charsets, err := newCharsetDecoder(input.Charset)
+	if err != nil {
+		return nil, err
 	}
+	router, err := newResultRouter(input.Routing, input.LisPlatform)
+	if err != nil {
+		return nil, err
+	}
+
+	return &deviceMessageSvcImpl{
+		hl7Config:            input.HL7,
+		parsingConfig:        input.Parsing,
+		deviceMessageCommand: input.DeviceMessageCommand,
+		outboxCommand:        input.OutboxCommand,
+		outboxDispatcher:     input.OutboxDispatcher,
+		deadLetterCommand:    input.DeadLetterCommand,
+		transaction:          input.Transaction,
+		outboxConfig:         outboxDefaults(input.Outbox),
+		parsers:              parsers,
+		units:                units,
+		clock:                clock,
+		charsets:             charsets,
+		router:               router,
+	}, nil
 }
 
-func (d *deviceMessageSvcImpl) Process(ctx context.Context, inputs *models.DeviceMessageInput) (err error) {
+func (d *deviceMessageSvcImpl) Process(ctx context.Context, inputs *models.DeviceMessageInput) (output *models.DeviceMessageOutput, err error) {
 	// store to database
 	deviceMessage := &models.DeviceMessage{
 		DeviceID:       inputs.DeviceID,
@@ -37,59 +95,132 @@ func (d *deviceMessageSvcImpl) Process(ctx context.Context, inputs *models.Devic
 		Message:        inputs.Message,
 		Protocol:       inputs.Protocol,
 	}
-	err = d.deviceMessageCommand.Create(ctx, deviceMessage)
-	if err != nil {
-		return err
+	output = &models.DeviceMessageOutput{}
+	// acknowledge hl7 messages with the processing outcome
+	defer func() {
+		if deviceMessage.Protocol == models.PROTOCOL_HL7 {
+			output.Acknowledgement = acknowledgeHL7(deviceMessage.Message, err)
+		}
+	}()
+
+	d.charsets.decode(deviceMessage)
+	d.clock.receive(deviceMessage)
+	switch deviceMessage.Protocol {
+	case models.PROTOCOL_HL7:
+		setHL7Header(deviceMessage)
+	case models.PROTOCOL_ASTM:
+		setASTMHeader(deviceMessage)
+	}
+
+	// parse before storing so the failure is kept with the message
+	messages, parseErr := d.parsers.parse(*deviceMessage)
+	diagnostics := []parsers.Diagnostic{}
+	for _, message := range messages {
+		diagnostics = append(diagnostics, message.ParseDiagnostics()...)
+	}
+	if len(diagnostics) > 0 {
+		b, _ := json.Marshal(diagnostics)
+		deviceMessage.ParseDiagnostics = string(b)
+		// strict devices never publish partial results
+		if parseErr == nil && d.parsingConfig.Strict(deviceMessage.DeviceID) {
+			parseErr = errors.Wrapf(errors.ERROR_PARSE_DIAGNOSTICS, "%d diagnostics", len(diagnostics))
+		}
+	}
+	if parseErr != nil {
+		deviceMessage.ParseError = parseErr.Error()
 	}
 
-	// routing message
-	message, err := d.routingMessage(ctx, *deviceMessage)
-	if err != nil || message == nil {
-		return
+	serializers := make([]models.Serializer, 0, len(messages))
+	for _, message := range messages {
+		serializer := message.Serialize(*deviceMessage)
+		d.units.normalize(&serializer)
+		serializers = append(serializers, serializer)
 	}
+	d.clock.measure(deviceMessage, serializers)
 
-	// publish message
-	err = d.publish(ctx, deviceMessage, message)
-	if err != nil {
-		return nil
+	// training and debug messages are kept for audit but never published
+	var rejectErr error
+	if deviceMessage.Protocol == models.PROTOCOL_HL7 &&
+		slices.Contains(d.hl7Config.RejectProcessingIDs, deviceMessage.ProcessingID) {
+		rejectErr = errors.Wrapf(errors.ERROR_PROCESSING_ID_REJECTED, "processing id %s", deviceMessage.ProcessingID)
 	}
 
-	return err
-}
+	id := uuid.New()
+	deviceMessage.ID = &id
+	output.ID = deviceMessage.ID
 
-func (d *deviceMessageSvcImpl) routingMessage(ctx context.Context, deviceMessage models.DeviceMessage) (parsedMessage models.Message, err error) {
+	// every specimen is published on its own to each destination it is
+	// routed to, a message that could not be parsed is kept as a dead letter
+	// instead
+	entries := []models.OutboxEntry{}
+	var deadLetter *models.DeadLetter
+	switch {
+	case rejectErr != nil:
+	case parseErr != nil:
+		deadLetter = newDeadLetter(models.DEAD_LETTER_REASON_PARSE_FAILED, parseErr)
+		deadLetter.DeviceMessageID = deviceMessage.ID.String()
+		deadLetter.DeviceID = deviceMessage.DeviceID
+		deadLetter.DeviceTypeCode = deviceMessage.DeviceTypeCode
+		deadLetter.Protocol = deviceMessage.Protocol
+		deadLetter.Attempts = 1
+		deadLetter.Reprocessed = inputs.Reprocessed
+		deadLetter.Payload = deviceMessage.Message
+	default:
+		entries = d.outboxEntries(*deviceMessage, serializers)
+	}
 
-	switch deviceMessage.Protocol {
-	case "hl7":
-		parsedMessage, err = parseHL7Message(deviceMessage)
-		if err != nil {
-			return nil, err
-		}
-	case "rs232":
-		switch deviceMessage.DeviceTypeCode {
-		default:
-			parsedMessage, err = parseUrineTestText(deviceMessage.Message)
-			if err != nil {
-				return nil, err
-			}
-		}
+	// the results are stored with their message so none is lost on a crash
+	txnCtx := d.transaction.Begin(ctx)
+	err = d.deviceMessageCommand.Create(txnCtx, deviceMessage)
+	if err == nil {
+		err = d.outboxCommand.Create(txnCtx, entries)
+	}
+	if err == nil && deadLetter != nil {
+		err = d.deadLetterCommand.Create(txnCtx, deadLetter)
+	}
+	if endErr := d.transaction.End(txnCtx, &err); err == nil {
+		err = endErr
+	}
+	if err != nil {
+		return output, err
 	}
 
-	return
-}
+	if rejectErr != nil {
+		return output, rejectErr
+	}
+	if parseErr != nil {
+		return output, parseErr
+	}
+
+	// a failed delivery is not reported back to the device, the worker
+	// retries it
+	deliverInline(ctx, d.outboxDispatcher, d.outboxConfig, entries)
 
-func (d *deviceMessageSvcImpl) publish(ctx context.Context, deviceMessage *models.DeviceMessage, message models.Message) (err error) {
-	serializer := message.Serialize(*deviceMessage)
-	cln := d.client.SetTimeout(time.Duration(10) * time.Second)
+	return output, nil
+}
 
-	// Make a request
-	jsonData, _ := json.Marshal(serializer)
-	fmt.Println("JSON output:", string(jsonData))
+// outboxEntries serializes the results of a message once for every
+// destination they are routed to, they are claimed for a lease so only the
+// first delivery attempt of Process sends them right away.
+func (d *deviceMessageSvcImpl) outboxEntries(deviceMessage models.DeviceMessage, serializers []models.Serializer) []models.OutboxEntry {
+	entries := make([]models.OutboxEntry, 0, len(serializers))
+	for i, serializer := range serializers {
+		serializer.DeviceMessageID = deviceMessage.ID.String()
+		serializer.RecordNumber = i + 1
+		serializer.RecordCount = len(serializers)
 
-	_, err = cln.R().
-		SetHeader("Accept", "application/json").
-		SetBody(serializer).
-		Post(d.lisPlatformConfig.Url)
+		for _, destination := range d.router.route(serializer) {
+			id := uuid.New()
+			entries = append(entries, models.OutboxEntry{
+				ID:              &id,
+				DeviceMessageID: deviceMessage.ID.String(),
+				Destination:     destination.Name,
+				Payload:         d.router.format(destination, serializer),
+				Status:          models.OUTBOX_STATUS_PENDING,
+				NextAttemptAt:   deviceMessage.ReceivedAt.Add(d.outboxConfig.Lease),
+			})
+		}
+	}
 
-	return err
+	return entries
 }
@@ -0,0 +1,544 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockDeviceMessageCommand struct {
+	created []models.DeviceMessage
+}
+
+func (m *mockDeviceMessageCommand) Create(ctx context.Context, deviceMessage *models.DeviceMessage) error {
+	m.created = append(m.created, *deviceMessage)
+
+	return nil
+}
+
+type mockTransaction struct {
+	ended []error
+}
+
+func (m *mockTransaction) Begin(ctx context.Context) context.Context {
+	return ctx
+}
+
+func (m *mockTransaction) End(ctx context.Context, err *error) error {
+	m.ended = append(m.ended, *err)
+
+	return nil
+}
+
+// mockOutboxCommand keeps the entries by id, Claim returns the due ones
+type mockOutboxCommand struct {
+	mx      sync.Mutex
+	entries map[uuid.UUID]models.OutboxEntry
+	order   []uuid.UUID
+	err     error
+}
+
+func (m *mockOutboxCommand) Create(ctx context.Context, entries []models.OutboxEntry) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	if m.entries == nil {
+		m.entries = map[uuid.UUID]models.OutboxEntry{}
+	}
+	for _, entry := range entries {
+		m.entries[*entry.ID] = entry
+		m.order = append(m.order, *entry.ID)
+	}
+
+	return nil
+}
+
+func (m *mockOutboxCommand) Claim(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.OutboxEntry, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	var claimed []models.OutboxEntry
+	for _, id := range m.order {
+		entry := m.entries[id]
+		if entry.Status != models.OUTBOX_STATUS_PENDING || entry.NextAttemptAt.After(now) || len(claimed) == limit {
+			continue
+		}
+		entry.NextAttemptAt = now.Add(lease)
+		m.entries[id] = entry
+		claimed = append(claimed, entry)
+	}
+
+	return claimed, nil
+}
+
+func (m *mockOutboxCommand) Update(ctx context.Context, entry *models.OutboxEntry) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.entries[*entry.ID] = *entry
+
+	return nil
+}
+
+func (m *mockOutboxCommand) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	purged := 0
+	order := m.order[:0]
+	for _, id := range m.order {
+		entry := m.entries[id]
+		if entry.Status == models.OUTBOX_STATUS_DELIVERED && entry.DeliveredAt.Before(before) && purged < limit {
+			delete(m.entries, id)
+			purged++
+			continue
+		}
+		order = append(order, id)
+	}
+	m.order = order
+
+	return purged, nil
+}
+
+func (m *mockOutboxCommand) list() []models.OutboxEntry {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	res := make([]models.OutboxEntry, 0, len(m.order))
+	for _, id := range m.order {
+		res = append(res, m.entries[id])
+	}
+
+	return res
+}
+
+// mockDeadLetterStore is both the command and the query of the dead letters
+type mockDeadLetterStore struct {
+	mx          sync.Mutex
+	deadLetters []models.DeadLetter
+}
+
+func (m *mockDeadLetterStore) Create(ctx context.Context, deadLetter *models.DeadLetter) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	m.deadLetters = append(m.deadLetters, *deadLetter)
+
+	return nil
+}
+
+func (m *mockDeadLetterStore) Close(ctx context.Context, deadLetter *models.DeadLetter) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	for i := range m.deadLetters {
+		if *m.deadLetters[i].ID != *deadLetter.ID {
+			continue
+		}
+		if m.deadLetters[i].Status != models.DEAD_LETTER_STATUS_OPEN {
+			return errors.ERROR_DEAD_LETTER_CLOSED
+		}
+		m.deadLetters[i] = *deadLetter
+
+		return nil
+	}
+
+	return errors.ERROR_NOT_FOUND
+}
+
+func (m *mockDeadLetterStore) Retry(ctx context.Context, deadLetter *models.DeadLetter) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	for i := range m.deadLetters {
+		if *m.deadLetters[i].ID != *deadLetter.ID {
+			continue
+		}
+		if m.deadLetters[i].Status != models.DEAD_LETTER_STATUS_OPEN {
+			return errors.ERROR_DEAD_LETTER_CLOSED
+		}
+		m.deadLetters[i].Attempts = deadLetter.Attempts
+		m.deadLetters[i].Reprocessed = deadLetter.Reprocessed
+		m.deadLetters[i].Errors = deadLetter.Errors
+
+		return nil
+	}
+
+	return errors.ERROR_NOT_FOUND
+}
+
+func (m *mockDeadLetterStore) Purge(ctx context.Context, before time.Time, limit int) (int, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	purged := 0
+	deadLetters := m.deadLetters[:0]
+	for _, deadLetter := range m.deadLetters {
+		if deadLetter.Status != models.DEAD_LETTER_STATUS_OPEN && deadLetter.UpdatedAt.Before(before) && purged < limit {
+			purged++
+			continue
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	m.deadLetters = deadLetters
+
+	return purged, nil
+}
+
+func (m *mockDeadLetterStore) Due(ctx context.Context, rounds int, before time.Time, limit int) ([]models.DeadLetter, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	res := []models.DeadLetter{}
+	for _, deadLetter := range m.deadLetters {
+		if deadLetter.Status == models.DEAD_LETTER_STATUS_OPEN && deadLetter.Reprocessed < rounds &&
+			!deadLetter.UpdatedAt.After(before) && len(res) < limit {
+			res = append(res, deadLetter)
+		}
+	}
+
+	return res, nil
+}
+
+func (m *mockDeadLetterStore) Find(ctx context.Context, filter models.DeadLetterFilter) ([]models.DeadLetter, models.Pagination, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	res := []models.DeadLetter{}
+	for _, deadLetter := range m.deadLetters {
+		if deadLetter.Status == filter.Status {
+			res = append(res, deadLetter)
+		}
+	}
+
+	return res, models.Pagination{TotalData: int64(len(res))}, nil
+}
+
+func (m *mockDeadLetterStore) Get(ctx context.Context, id uuid.UUID) (*models.DeadLetter, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	for _, deadLetter := range m.deadLetters {
+		if *deadLetter.ID == id {
+			return &deadLetter, nil
+		}
+	}
+
+	return nil, errors.ERROR_NOT_FOUND
+}
+
+func (m *mockDeadLetterStore) list() []models.DeadLetter {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+
+	return slices.Clone(m.deadLetters)
+}
+
+// mockPublisher posts the payload to http destinations with the status
+// handling of the http publisher.
+type mockPublisher struct{}
+
+func (m mockPublisher) Schemes() []string {
+	return []string{"http"}
+}
+
+func (m mockPublisher) Close() error {
+	return nil
+}
+
+func (m mockPublisher) Publish(ctx context.Context, destination configurations.Destination, entry models.OutboxEntry) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, destination.Url, strings.NewReader(entry.Payload))
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "post: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+type testDeviceMessageService struct {
+	ports.DeviceMessageService
+	command     *mockDeviceMessageCommand
+	outbox      *mockOutboxCommand
+	deadLetters *mockDeadLetterStore
+	dispatcher  ports.OutboxDispatcher
+}
+
+func newTestDeviceMessageService(t *testing.T, url string, input DeviceMessageServiceInput) testDeviceMessageService {
+	utils.NewZap()
+	res := testDeviceMessageService{
+		command:     &mockDeviceMessageCommand{},
+		outbox:      &mockOutboxCommand{},
+		deadLetters: &mockDeadLetterStore{},
+	}
+	input.LisPlatform.Url = url
+
+	var err error
+	res.dispatcher, err = NewOutboxDispatcher(OutboxDispatcherInput{
+		OutboxCommand:     res.outbox,
+		DeadLetterCommand: res.deadLetters,
+		Transaction:       &mockTransaction{},
+		LisPlatform:       input.LisPlatform,
+		Outbox:            input.Outbox,
+		Routing:           input.Routing,
+		Publishers:        []ports.ResultPublisher{mockPublisher{}},
+	})
+	assert.NoError(t, err)
+	input.DeviceMessageCommand = res.command
+	input.OutboxCommand = res.outbox
+	input.OutboxDispatcher = res.dispatcher
+	input.DeadLetterCommand = res.deadLetters
+	input.Transaction = &mockTransaction{}
+	if input.Parsers == nil {
+		input.Parsers = []ports.MessageParser{NewUrineParser(configurations.Urine{})}
+	}
+
+	res.DeviceMessageService, err = NewDeviceMessageService(input)
+	assert.NoError(t, err)
+
+	return res
+}
+
+func TestDeviceMessageStrictParsing(t *testing.T) {
+	var published atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		published.Add(1)
+	}))
+	defer server.Close()
+
+	service := newTestDeviceMessageService(t, server.URL, DeviceMessageServiceInput{
+		Parsing: configurations.Parsing{StrictDevices: []string{"urine-strict"}},
+	})
+	command := service.command
+
+	message := "NO.0012 2025-03-04\n09:15:30\nLEU -\nSG         high\nXYZ 1\n"
+	input := &models.DeviceMessageInput{
+		DeviceID:       "urine-lenient",
+		DeviceTypeCode: URINE_DEVICE_TYPE_CODE,
+		Protocol:       models.PROTOCOL_RS232,
+		Message:        message,
+	}
+
+	// partial results are published, the diagnostics are kept
+	_, err := service.Process(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), published.Load())
+	assert.JSONEq(t, `[
+		{"kind": "invalid_number", "location": "line 4", "field": "specific_gravity", "value": "high"},
+		{"kind": "unrecognized", "location": "line 5", "value": "XYZ 1"}
+	]`, command.created[0].ParseDiagnostics)
+	assert.Empty(t, command.created[0].ParseError)
+
+	// strict devices store the message but never publish it
+	input.DeviceID = "urine-strict"
+	_, err = service.Process(context.Background(), input)
+	assert.ErrorIs(t, err, errors.ERROR_PARSE_DIAGNOSTICS)
+	assert.Equal(t, int32(1), published.Load())
+	assert.Equal(t, command.created[0].ParseDiagnostics, command.created[1].ParseDiagnostics)
+	assert.NotEmpty(t, command.created[1].ParseError)
+
+	// and keep it as a dead letter to be replayed
+	deadLetters := service.deadLetters.list()
+	assert.Len(t, deadLetters, 1)
+	assert.Equal(t, models.DEAD_LETTER_REASON_PARSE_FAILED, deadLetters[0].Reason)
+	assert.Equal(t, models.DEAD_LETTER_STATUS_OPEN, deadLetters[0].Status)
+	assert.Equal(t, command.created[1].ID.String(), deadLetters[0].DeviceMessageID)
+	assert.Equal(t, "urine-strict", deadLetters[0].DeviceID)
+	assert.Equal(t, message, deadLetters[0].Payload)
+	assert.Equal(t, []string{"2 diagnostics", errors.ERROR_PARSE_DIAGNOSTICS.Error()}, deadLetters[0].Errors)
+
+	// clean messages of strict devices are published
+	input.Message = "NO.0013 2025-03-04\n09:20:00\nLEU -\n"
+	_, err = service.Process(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), published.Load())
+	assert.Empty(t, command.created[2].ParseDiagnostics)
+}
+
+func TestDeviceMessageOutbox(t *testing.T) {
+	var failing atomic.Bool
+	var published atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		published.Add(1)
+	}))
+	defer server.Close()
+
+	service := newTestDeviceMessageService(t, server.URL, DeviceMessageServiceInput{})
+	command, outbox := service.command, service.outbox
+	input := &models.DeviceMessageInput{
+		DeviceID:       "urine-1",
+		DeviceTypeCode: URINE_DEVICE_TYPE_CODE,
+		Protocol:       models.PROTOCOL_RS232,
+		Message:        "NO.0012 2025-03-04\n09:15:30\nLEU -\nNO.0013 2025-03-04\n09:20:00\nLEU -\n",
+	}
+
+	// a rejected delivery keeps the entries pending with the attempt recorded
+	failing.Store(true)
+	output, err := service.Process(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Equal(t, command.created[0].ID, output.ID)
+
+	entries := outbox.list()
+	assert.Len(t, entries, 2)
+	for i, entry := range entries {
+		assert.Equal(t, output.ID.String(), entry.DeviceMessageID)
+		assert.Equal(t, models.OUTBOX_STATUS_PENDING, entry.Status)
+		assert.Equal(t, 1, entry.Attempts)
+		assert.Contains(t, entry.LastError, "status 400")
+		assert.Nil(t, entry.DeliveredAt)
+
+		var serializer models.Serializer
+		assert.NoError(t, json.Unmarshal([]byte(entry.Payload), &serializer))
+		assert.Equal(t, i+1, serializer.RecordNumber)
+		assert.Equal(t, 2, serializer.RecordCount)
+	}
+
+	// a failed outbox write fails the transaction of the message
+	outbox.err = errors.ERROR_INTERNAL_SERVER
+	_, err = service.Process(context.Background(), input)
+	assert.ErrorIs(t, err, errors.ERROR_INTERNAL_SERVER)
+	assert.Len(t, outbox.list(), 2)
+	assert.Equal(t, int32(0), published.Load())
+}
+
+func TestDeviceMessageInlineDeliveryBudget(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// a hanging destination holds the device for a fraction of the lease
+	service := newTestDeviceMessageService(t, server.URL, DeviceMessageServiceInput{
+		Outbox: configurations.Outbox{Lease: 200 * time.Millisecond},
+	})
+	started := time.Now()
+	_, err := service.Process(context.Background(), &models.DeviceMessageInput{
+		DeviceID:       "urine-1",
+		DeviceTypeCode: URINE_DEVICE_TYPE_CODE,
+		Protocol:       models.PROTOCOL_RS232,
+		Message:        "NO.0012 2025-03-04\n09:15:30\nLEU -\nNO.0013 2025-03-04\n09:20:00\nLEU -\n",
+	})
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(started), 200*time.Millisecond)
+
+	// the entry cut off records its attempt, the next one is left to the
+	// worker once the lease ends
+	entries := service.outbox.list()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, 1, entries[0].Attempts)
+	assert.Equal(t, models.OUTBOX_STATUS_PENDING, entries[0].Status)
+	assert.Zero(t, entries[1].Attempts)
+}
+
+func TestOutboxDispatcher(t *testing.T) {
+	var failing atomic.Bool
+	var published atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		published.Add(1)
+	}))
+	defer server.Close()
+
+	outbox := &mockOutboxCommand{}
+	now := time.Date(2025, 3, 4, 9, 0, 0, 0, time.UTC)
+	id := uuid.New()
+	assert.NoError(t, outbox.Create(context.Background(), []models.OutboxEntry{{
+		ID: &id, Payload: `{"device_id":"urine-1"}`, Status: models.OUTBOX_STATUS_PENDING, NextAttemptAt: now,
+	}}))
+
+	deadLetters := &mockDeadLetterStore{}
+	svc, err := NewOutboxDispatcher(OutboxDispatcherInput{
+		OutboxCommand:     outbox,
+		DeadLetterCommand: deadLetters,
+		Transaction:       &mockTransaction{},
+		LisPlatform:       configurations.LisPlatform{Url: server.URL},
+		Outbox:            configurations.Outbox{BackoffBase: 10 * time.Second, BackoffMax: 30 * time.Second, MaxAttempts: 6},
+		Publishers:        []ports.ResultPublisher{mockPublisher{}},
+	})
+	assert.NoError(t, err)
+	dispatcher := svc.(*outboxDispatcherImpl)
+	dispatcher.now = func() time.Time { return now }
+
+	// failures back off 10s, 20s then stay at the 30s maximum
+	failing.Store(true)
+	for _, backoff := range []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second} {
+		attempted, err := dispatcher.Dispatch(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 1, attempted)
+		assert.Equal(t, models.OUTBOX_STATUS_PENDING, outbox.list()[0].Status)
+		assert.Equal(t, now.Add(backoff), outbox.list()[0].NextAttemptAt)
+
+		// not due before the backoff ends
+		attempted, _ = dispatcher.Dispatch(context.Background())
+		assert.Zero(t, attempted)
+		now = now.Add(backoff)
+	}
+
+	failing.Store(false)
+	attempted, err := dispatcher.Dispatch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+	assert.Equal(t, int32(1), published.Load())
+
+	entry := outbox.list()[0]
+	assert.Equal(t, models.OUTBOX_STATUS_DELIVERED, entry.Status)
+	assert.Equal(t, 5, entry.Attempts)
+	assert.Empty(t, entry.LastError)
+	assert.Equal(t, now, *entry.DeliveredAt)
+
+	// delivered entries are never claimed again
+	attempted, _ = dispatcher.Dispatch(context.Background())
+	assert.Zero(t, attempted)
+	assert.Empty(t, deadLetters.list())
+
+	// an entry out of attempts becomes a dead letter
+	failing.Store(true)
+	id = uuid.New()
+	payload := `{"device_id":"urine-1","device_type_code":"urine","protocol":"rs232"}`
+	assert.NoError(t, outbox.Create(context.Background(), []models.OutboxEntry{{
+		ID: &id, DeviceMessageID: "message-1", Payload: payload, Status: models.OUTBOX_STATUS_PENDING, Attempts: 5, NextAttemptAt: now,
+	}}))
+	attempted, err = dispatcher.Dispatch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+
+	entry = outbox.list()[1]
+	assert.Equal(t, models.OUTBOX_STATUS_DEAD, entry.Status)
+	assert.Equal(t, 6, entry.Attempts)
+	deadLetter := deadLetters.list()[0]
+	assert.Equal(t, models.DEAD_LETTER_REASON_DELIVERY_EXHAUSTED, deadLetter.Reason)
+	assert.Equal(t, id.String(), deadLetter.OutboxEntryID)
+	assert.Equal(t, "message-1", deadLetter.DeviceMessageID)
+	assert.Equal(t, "urine-1", deadLetter.DeviceID)
+	assert.Equal(t, models.PROTOCOL_RS232, deadLetter.Protocol)
+	assert.Equal(t, 6, deadLetter.Attempts)
+	assert.Equal(t, payload, deadLetter.Payload)
+	assert.Equal(t, []string{"status 422", errors.ERROR_DELIVERY_FAILED.Error()}, deadLetter.Errors)
+
+	// dead entries are never claimed again
+	now = now.Add(time.Hour)
+	attempted, _ = dispatcher.Dispatch(context.Background())
+	assert.Zero(t, attempted)
+}
@@ -3,14 +3,17 @@ package services
 import (
 	"strconv"
 	"strings"
-	"time"
 
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
 	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
 	"github.com/Calmantara/lis-backend/internal/helpers/parsers"
 	"github.com/Calmantara/lis-backend/internal/utils"
 )
 
 type HL7TestResult struct {
+	Header  parsers.MessageHeader `json:"header"`
 	Patient struct {
 		Identifiers []struct {
 			ID             string `json:"id"`
@@ -31,22 +34,48 @@ type HL7TestResult struct {
 			Country string `json:"country"`
 		} `json:"address"`
 	} `json:"patient"`
-	Tests []struct {
-		Code           string `json:"code"`
-		Name           string `json:"name"`
-		CodingSystem   string `json:"coding_system"`
-		Value          string `json:"value"`
-		Units          string `json:"units"`
-		ReferenceRange string `json:"reference_range"`
-		AbnormalFlags  string `json:"abnormal_flags"`
-	} `json:"tests"`
-	PrimaryOrderDatetime time.Time `json:"primary_order_datetime"`
+	Tests                []HL7Test            `json:"tests"`
+	Orders               []HL7Order           `json:"orders"`
+	PrimaryOrderDatetime string               `json:"primary_order_datetime"`
+	Extras               map[string]string    `json:"extras"`
+	Diagnostics          []parsers.Diagnostic `json:"diagnostics"`
+}
+
+type HL7Order struct {
+	PlacerOrderNumber   string    `json:"placer_order_number"`
+	FillerOrderNumber   string    `json:"filler_order_number"`
+	ServiceCode         string    `json:"service_code"`
+	ServiceName         string    `json:"service_name"`
+	ObservationDatetime string    `json:"observation_datetime"`
+	Comments            []string  `json:"comments"`
+	Tests               []HL7Test `json:"tests"`
+}
+
+type HL7Test struct {
+	Code           string            `json:"code"`
+	Name           string            `json:"name"`
+	CodingSystem   string            `json:"coding_system"`
+	ValueType      string            `json:"value_type"`
+	Value          string            `json:"value"`
+	Comparator     string            `json:"comparator"`
+	NumericValue   *float64          `json:"numeric_value"`
+	ValueCode      string            `json:"value_code"`
+	ValueText      string            `json:"value_text"`
+	Comments       []string          `json:"comments"`
+	Units          string            `json:"units"`
+	ReferenceRange string            `json:"reference_range"`
+	AbnormalFlags  string            `json:"abnormal_flags"`
+	Extras         map[string]string `json:"extras"`
 }
 
 func (s *HL7TestResult) Stringify() string {
 	return ""
 }
 
+func (s *HL7TestResult) ParseDiagnostics() []parsers.Diagnostic {
+	return s.Diagnostics
+}
+
 func (s *HL7TestResult) Serialize(deviceMessage models.DeviceMessage) models.Serializer {
 	seqNum := ""
 	patientID := ""
@@ -61,27 +90,67 @@ func (s *HL7TestResult) Serialize(deviceMessage models.DeviceMessage) models.Ser
 		DeviceTypeCode: deviceMessage.DeviceTypeCode,
 		SequenceNumber: seqNum,
 		PatientID:      patientID,
-		Timestamp:      s.PrimaryOrderDatetime,
+		Timestamp:      parseISOTimestamp(s.PrimaryOrderDatetime, deviceMessage.Location()),
+		Extras:         s.Extras,
+		// message header
+		MessageType:        s.Header.MessageType,
+		MessageControlID:   s.Header.ControlID,
+		ProcessingID:       s.Header.ProcessingID,
+		SendingApplication: s.Header.SendingApplication,
+		SendingFacility:    s.Header.SendingFacility,
+		ProtocolVersion:    s.Header.Version,
 	}
 
 	// transform result
 	for _, test := range s.Tests {
-		res.Results = append(res.Results, models.Result{
-			ParameterCode:  test.Code,
-			ParameterName:  test.Name,
-			Value:          test.Value,
-			Unit:           test.Units,
-			ReferenceRange: test.ReferenceRange,
-			AbnormalFlags:  test.AbnormalFlags,
-		})
+		res.Results = append(res.Results, test.serialize())
+	}
+
+	// transform orders
+	for _, order := range s.Orders {
+		serialized := models.Order{
+			PlacerOrderNumber: order.PlacerOrderNumber,
+			FillerOrderNumber: order.FillerOrderNumber,
+			ServiceCode:       order.ServiceCode,
+			ServiceName:       order.ServiceName,
+			Timestamp:         parseISOTimestamp(order.ObservationDatetime, deviceMessage.Location()),
+			Comments:          order.Comments,
+			Results:           []models.Result{},
+		}
+		for _, test := range order.Tests {
+			serialized.Results = append(serialized.Results, test.serialize())
+		}
+		res.Orders = append(res.Orders, serialized)
 	}
 
 	return res
 }
 
-func parseHL7Message(deviceMessage models.DeviceMessage) (res *HL7TestResult, err error) {
+func (t HL7Test) serialize() models.Result {
+	res := models.Result{
+		ParameterCode:   t.Code,
+		ParameterName:   t.Name,
+		Value:           t.Value,
+		Comparator:      t.Comparator,
+		Unit:            t.Units,
+		Qualitative:     t.ValueText,
+		QualitativeCode: t.ValueCode,
+		ReferenceRange:  t.ReferenceRange,
+		AbnormalFlags:   t.AbnormalFlags,
+		Comments:        t.Comments,
+		Extras:          t.Extras,
+	}
+	// only NM and SN observations carry a number
+	if t.NumericValue != nil {
+		res.NumericValue = *t.NumericValue
+	}
+
+	return res
+}
+
+func parseHL7Message(deviceMessage models.DeviceMessage, options ...parsers.HL7Option) (res *HL7TestResult, err error) {
 	msg := strings.TrimSpace(deviceMessage.Message)
-	parsedMessage, err := parsers.ParseHL7Message(msg)
+	parsedMessage, err := parsers.ParseHL7Message(msg, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -91,3 +160,64 @@ func parseHL7Message(deviceMessage models.DeviceMessage) (res *HL7TestResult, er
 
 	return
 }
+
+// setHL7Header copies the MSH metadata to the stored message, leaving the
+// columns empty when the header cannot be read.
+func setHL7Header(deviceMessage *models.DeviceMessage) {
+	header, err := parsers.ParseHL7Header(deviceMessage.Message)
+	if err != nil {
+		return
+	}
+
+	deviceMessage.MessageType = header.MessageType
+	deviceMessage.MessageControlID = header.ControlID
+	deviceMessage.ProcessingID = header.ProcessingID
+	deviceMessage.SendingApplication = header.SendingApplication
+	deviceMessage.SendingFacility = header.SendingFacility
+	deviceMessage.ProtocolVersion = header.Version
+	deviceMessage.InstrumentTime = parseInstrumentTime(header.DateTime, deviceMessage.Location())
+}
+
+// acknowledgeHL7 builds the ACK for a received message: AR when the header
+// cannot be read or the processing ID is rejected, AE when processing failed
+// and AA otherwise.
+func acknowledgeHL7(message string, err error) string {
+	header, headerErr := parsers.ParseHL7Header(message)
+	if headerErr != nil {
+		return parsers.BuildHL7Ack(header, parsers.ACK_APPLICATION_REJECT, headerErr)
+	}
+	if errors.Is(err, errors.ERROR_PROCESSING_ID_REJECTED) {
+		return parsers.BuildHL7Ack(header, parsers.ACK_APPLICATION_REJECT, err)
+	}
+	if err != nil {
+		return parsers.BuildHL7Ack(header, parsers.ACK_APPLICATION_ERROR, err)
+	}
+
+	return parsers.BuildHL7Ack(header, parsers.ACK_APPLICATION_ACCEPT, nil)
+}
+
+type hl7Parser struct {
+	hl7Config configurations.HL7
+}
+
+// NewHL7Parser parses HL7 messages of every device type, applying the field
+// mappings configured for the device type.
+func NewHL7Parser(hl7 configurations.HL7) ports.MessageParser {
+	return &hl7Parser{hl7Config: hl7}
+}
+
+func (p *hl7Parser) Keys() []models.ParserKey {
+	return []models.ParserKey{{Protocol: models.PROTOCOL_HL7}}
+}
+
+func (p *hl7Parser) Parse(deviceMessage models.DeviceMessage) ([]models.Message, error) {
+	res, err := parseHL7Message(
+		deviceMessage,
+		parsers.WithFieldMappings(p.hl7Config.DeviceFieldMappings(deviceMessage.DeviceTypeCode)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.Message{res}, nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHL7ParserCodedValues(t *testing.T) {
+	deviceMessage := models.DeviceMessage{
+		DeviceID: "micro-1",
+		Protocol: models.PROTOCOL_HL7,
+		Message: "MSH|^~\\&|VITEK|LAB|LIS|LAB|20250304091530||ORU^R01|MSG-1|P|2.5.1\r" +
+			"PID|1||MRN-9\r" +
+			"OBR|1|ORD-1||CULT^Culture\r" +
+			"OBX|1|CWE|ORG^Organism||112283007^Escherichia coli^SCT||||||F\r" +
+			"OBX|2|CE|GRAM^Gram stain||NEG||||||F",
+	}
+
+	messages, err := NewHL7Parser(configurations.HL7{}).Parse(deviceMessage)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+
+	// the code is published next to its display text
+	serializer := messages[0].Serialize(deviceMessage)
+	assert.Len(t, serializer.Results, 2)
+	assert.Equal(t, "Escherichia coli", serializer.Results[0].Value)
+	assert.Equal(t, "Escherichia coli", serializer.Results[0].Qualitative)
+	assert.Equal(t, "112283007", serializer.Results[0].QualitativeCode)
+	assert.Equal(t, "NEG", serializer.Results[1].Value)
+	assert.Equal(t, "NEG", serializer.Results[1].QualitativeCode)
+	assert.Equal(t, "112283007", serializer.Orders[0].Results[0].QualitativeCode)
+
+	rows := serializer.Flatten()
+	assert.Equal(t, "112283007", rows[0].QualitativeCode)
+	assert.Equal(t, "Escherichia coli", rows[0].Qualitative)
+}
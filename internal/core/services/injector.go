@@ -3,5 +3,19 @@ package services
 import "go.uber.org/dig"
 
 func NewInjector(digger *dig.Container) {
+	// message parsers, register new analyzers here
+	digger.Provide(NewHL7Parser, dig.Group(MESSAGE_PARSER_GROUP))
+	digger.Provide(NewASTMParser, dig.Group(MESSAGE_PARSER_GROUP))
+	digger.Provide(NewUrineParser, dig.Group(MESSAGE_PARSER_GROUP))
+	digger.Provide(NewTextParsers)
+
+	digger.Provide(NewOutboxDispatcher)
 	digger.Provide(NewDeviceMessageService)
+	digger.Provide(NewDeadLetterService)
+
+	// worker tasks, run by the worker command
+	digger.Provide(NewOutboxDeliveryTask, dig.Group(WORKER_TASK_GROUP))
+	digger.Provide(NewOutboxRetentionTask, dig.Group(WORKER_TASK_GROUP))
+	digger.Provide(NewDeadLetterRetentionTask, dig.Group(WORKER_TASK_GROUP))
+	digger.Provide(NewDeadLetterReprocessTask, dig.Group(WORKER_TASK_GROUP))
 }
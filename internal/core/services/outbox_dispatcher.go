@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"go.uber.org/dig"
+)
+
+const (
+	DEFAULT_OUTBOX_BATCH_SIZE   = 100
+	DEFAULT_OUTBOX_LEASE        = time.Minute
+	DEFAULT_OUTBOX_BACKOFF_BASE = 10 * time.Second
+	DEFAULT_OUTBOX_BACKOFF_MAX  = time.Hour
+	DEFAULT_OUTBOX_MAX_ATTEMPTS = 20
+	// DEFAULT_OUTBOX_INLINE_TIMEOUT bounds the first delivery attempt made
+	// while the device or caller waits, the worker owns every retry
+	DEFAULT_OUTBOX_INLINE_TIMEOUT = 5 * time.Second
+)
+
+type outboxDispatcherImpl struct {
+	outboxCommand     ports.OutboxCommand
+	deadLetterCommand ports.DeadLetterCommand
+	transaction       ports.Transaction
+	publishers        map[string]ports.ResultPublisher
+	config            configurations.Outbox
+	router            *resultRouter
+	now               func() time.Time
+}
+
+type OutboxDispatcherInput struct {
+	dig.In
+	OutboxCommand     ports.OutboxCommand
+	DeadLetterCommand ports.DeadLetterCommand
+	Transaction       ports.Transaction
+	LisPlatform       configurations.LisPlatform
+	Outbox            configurations.Outbox
+	Routing           configurations.Routing
+	Publishers        []ports.ResultPublisher `group:"resultPublishers"`
+}
+
+func NewOutboxDispatcher(input OutboxDispatcherInput) (ports.OutboxDispatcher, error) {
+	router, err := newResultRouter(input.Routing, input.LisPlatform)
+	if err != nil {
+		return nil, err
+	}
+	publishers := map[string]ports.ResultPublisher{}
+	for _, publisher := range input.Publishers {
+		for _, scheme := range publisher.Schemes() {
+			publishers[scheme] = publisher
+		}
+	}
+	for _, destination := range router.destinations {
+		scheme, _, _ := strings.Cut(destination.Url, "://")
+		if _, ok := publishers[scheme]; !ok {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "no publisher for %s of destination %s", scheme, destination.Name)
+		}
+	}
+
+	return &outboxDispatcherImpl{
+		outboxCommand:     input.OutboxCommand,
+		deadLetterCommand: input.DeadLetterCommand,
+		transaction:       input.Transaction,
+		publishers:        publishers,
+		config:            outboxDefaults(input.Outbox),
+		router:            router,
+		now:               time.Now,
+	}, nil
+}
+
+func outboxDefaults(config configurations.Outbox) configurations.Outbox {
+	if config.BatchSize <= 0 {
+		config.BatchSize = DEFAULT_OUTBOX_BATCH_SIZE
+	}
+	if config.Lease <= 0 {
+		config.Lease = DEFAULT_OUTBOX_LEASE
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = DEFAULT_OUTBOX_BACKOFF_BASE
+	}
+	if config.BackoffMax < config.BackoffBase {
+		config.BackoffMax = max(DEFAULT_OUTBOX_BACKOFF_MAX, config.BackoffBase)
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DEFAULT_OUTBOX_MAX_ATTEMPTS
+	}
+
+	return config
+}
+
+// Deliver posts the entry to its destination and records the attempt, a
+// failed entry stays pending until its backoff ends or becomes a dead letter
+// once it ran out of attempts.
+func (o *outboxDispatcherImpl) Deliver(ctx context.Context, entry *models.OutboxEntry) error {
+	// an entry of a destination that was removed fails until it is dead
+	destination, err := o.router.destination(entry.Destination)
+	if err == nil {
+		err = o.publish(ctx, destination, entry)
+	}
+	policy := o.retryPolicy(destination)
+	// the outcome is recorded even when the publish ran out of time
+	ctx = context.WithoutCancel(ctx)
+
+	now := o.now()
+	entry.Attempts++
+	if err != nil {
+		entry.LastError = err.Error()
+		entry.NextAttemptAt = now.Add(backoff(policy, entry.Attempts))
+		if entry.Attempts >= policy.MaxAttempts {
+			entry.Status = models.OUTBOX_STATUS_DEAD
+			if deadErr := o.deadLetter(ctx, entry, err); deadErr != nil {
+				return deadErr
+			}
+
+			return err
+		}
+	} else {
+		entry.Status = models.OUTBOX_STATUS_DELIVERED
+		entry.LastError = ""
+		entry.DeliveredAt = &now
+	}
+
+	if updateErr := o.outboxCommand.Update(ctx, entry); updateErr != nil {
+		return updateErr
+	}
+
+	return err
+}
+
+// Redeliver publishes an entry of a dead letter once more under its own id,
+// so the destination still sees the same idempotency key. A failure is left
+// to the dead letter.
+func (o *outboxDispatcherImpl) Redeliver(ctx context.Context, entry *models.OutboxEntry) error {
+	destination, err := o.router.destination(entry.Destination)
+	if err == nil {
+		err = o.publish(ctx, destination, entry)
+	}
+	if err != nil {
+		return err
+	}
+
+	now := o.now()
+	entry.Attempts++
+	entry.Status = models.OUTBOX_STATUS_DELIVERED
+	entry.LastError = ""
+	entry.DeliveredAt = &now
+
+	return o.outboxCommand.Update(context.WithoutCancel(ctx), entry)
+}
+
+// deadLetter stores the exhausted entry with its dead letter so the result
+// is either retried or recoverable.
+func (o *outboxDispatcherImpl) deadLetter(ctx context.Context, entry *models.OutboxEntry, cause error) (err error) {
+	deadLetter := newDeadLetter(models.DEAD_LETTER_REASON_DELIVERY_EXHAUSTED, cause)
+	deadLetter.DeviceMessageID = entry.DeviceMessageID
+	deadLetter.OutboxEntryID = entry.ID.String()
+	deadLetter.Destination = entry.Destination
+	deadLetter.Attempts = entry.Attempts
+	deadLetter.Payload = entry.Payload
+	// the device is read from the result, a payload edited into invalid JSON
+	// still keeps its dead letter
+	deadLetter.DeviceID, deadLetter.DeviceTypeCode, deadLetter.Protocol = payloadDevice(entry.Payload)
+
+	ctx = o.transaction.Begin(ctx)
+	err = o.outboxCommand.Update(ctx, entry)
+	if err == nil {
+		err = o.deadLetterCommand.Create(ctx, deadLetter)
+	}
+	if endErr := o.transaction.End(ctx, &err); err == nil {
+		err = endErr
+	}
+
+	return err
+}
+
+// Dispatch claims one batch of due entries and delivers them in order, it
+// returns how many were attempted so callers can drain the outbox.
+func (o *outboxDispatcherImpl) Dispatch(ctx context.Context) (attempted int, err error) {
+	entries, err := o.outboxCommand.Claim(ctx, o.now(), o.config.Lease, o.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range entries {
+		if err := ctx.Err(); err != nil {
+			// unattempted entries are claimed again once the lease ends
+			return attempted, err
+		}
+		// the outcome is recorded on the entry
+		o.Deliver(ctx, &entries[i])
+		attempted++
+	}
+
+	return attempted, nil
+}
+
+// deliverInline makes the first attempt of freshly created entries within
+// one short budget, well under the lease they were created with so the
+// worker never sends them at the same time. Entries left when the budget ends
+// stay claimed and are picked up by the worker once the lease ends.
+func deliverInline(ctx context.Context, dispatcher ports.OutboxDispatcher, config configurations.Outbox, entries []models.OutboxEntry) {
+	ctx, cancel := context.WithTimeout(ctx, min(DEFAULT_OUTBOX_INLINE_TIMEOUT, config.Lease/2))
+	defer cancel()
+
+	for i := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := dispatcher.Deliver(ctx, &entries[i]); err != nil {
+			utils.Log.Errorw("failed to deliver outbox entry, the worker retries it", map[string]any{
+				"outbox_entry_id": entries[i].ID.String(),
+				"destination":     entries[i].Destination,
+				"error":           err.Error(),
+			})
+		}
+	}
+}
+
+// retryPolicy applies the retry settings of the destination over the
+// outbox ones.
+func (o *outboxDispatcherImpl) retryPolicy(destination configurations.Destination) configurations.Outbox {
+	policy := o.config
+	if destination.MaxAttempts > 0 {
+		policy.MaxAttempts = destination.MaxAttempts
+	}
+	if destination.BackoffBase > 0 {
+		policy.BackoffBase = destination.BackoffBase
+	}
+	if destination.BackoffMax > 0 {
+		policy.BackoffMax = destination.BackoffMax
+	}
+
+	return outboxDefaults(policy)
+}
+
+// backoff doubles the base delay for every attempt after the first.
+func backoff(policy configurations.Outbox, attempts int) time.Duration {
+	delay := policy.BackoffBase
+	for i := 1; i < attempts && delay < policy.BackoffMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, policy.BackoffMax)
+}
+
+// publish hands the entry to the publisher of the destination url scheme.
+func (o *outboxDispatcherImpl) publish(ctx context.Context, destination configurations.Destination, entry *models.OutboxEntry) error {
+	scheme, _, _ := strings.Cut(destination.Url, "://")
+	publisher, ok := o.publishers[scheme]
+	if !ok {
+		return errors.Wrapf(errors.ERROR_DELIVERY_FAILED, "no publisher for %q of destination %s", destination.Url, destination.Name)
+	}
+
+	return publisher.Publish(ctx, destination, *entry)
+}
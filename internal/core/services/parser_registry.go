@@ -0,0 +1,66 @@
+package services
+
+import (
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// MESSAGE_PARSER_GROUP is the dig group every ports.MessageParser is
+// provided to.
+const MESSAGE_PARSER_GROUP = "messageParsers"
+
+// parserSource is implemented by parsers built from configuration, so a
+// clash names the definition to fix.
+type parserSource interface {
+	Source() string
+}
+
+type parserRegistry struct {
+	parsers map[models.ParserKey]ports.MessageParser
+}
+
+func newParserRegistry(parsers []ports.MessageParser) (*parserRegistry, error) {
+	registry := &parserRegistry{parsers: map[models.ParserKey]ports.MessageParser{}}
+	for _, parser := range parsers {
+		for _, key := range parser.Keys() {
+			if registered, ok := registry.parsers[key]; ok {
+				return nil, errors.Wrapf(errors.ERROR_DUPLICATED_PARSER, "protocol %q device type %q of %s and %s",
+					key.Protocol, key.DeviceTypeCode, describeParser(registered), describeParser(parser))
+			}
+			registry.parsers[key] = parser
+		}
+	}
+
+	return registry, nil
+}
+
+func describeParser(parser ports.MessageParser) string {
+	if source, ok := parser.(parserSource); ok {
+		return source.Source()
+	}
+
+	return "the built-in parser"
+}
+
+// lookup prefers the parser of the exact device type over the one
+// registered for the whole protocol.
+func (r *parserRegistry) lookup(protocol, deviceTypeCode string) (ports.MessageParser, error) {
+	if parser, ok := r.parsers[models.ParserKey{Protocol: protocol, DeviceTypeCode: deviceTypeCode}]; ok {
+		return parser, nil
+	}
+	if parser, ok := r.parsers[models.ParserKey{Protocol: protocol}]; ok {
+		return parser, nil
+	}
+
+	return nil, errors.Wrapf(errors.ERROR_UNSUPPORTED_MESSAGE, "protocol %q device type %q", protocol, deviceTypeCode)
+}
+
+func (r *parserRegistry) parse(deviceMessage models.DeviceMessage) ([]models.Message, error) {
+	parser, err := r.lookup(deviceMessage.Protocol, deviceMessage.DeviceTypeCode)
+	if err != nil {
+		return nil, err
+	}
+
+	return parser.Parse(deviceMessage)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParserRegistryLookup(t *testing.T) {
+	hl7 := NewHL7Parser(configurations.HL7{})
+	urine := NewUrineParser(configurations.Urine{})
+	registry, err := newParserRegistry([]ports.MessageParser{hl7, NewASTMParser(), urine})
+	assert.NoError(t, err)
+
+	parser, err := registry.lookup(models.PROTOCOL_HL7, "chemistry")
+	assert.NoError(t, err)
+	assert.Equal(t, hl7, parser)
+
+	parser, err = registry.lookup(models.PROTOCOL_RS232, URINE_DEVICE_TYPE_CODE)
+	assert.NoError(t, err)
+	assert.Equal(t, urine, parser)
+
+	// serial devices without a parser of their own fall back to the urine parser
+	parser, err = registry.lookup(models.PROTOCOL_RS232, "hematology")
+	assert.NoError(t, err)
+	assert.Equal(t, urine, parser)
+
+	_, err = registry.parse(models.DeviceMessage{Protocol: "fhir", DeviceTypeCode: "analyzer"})
+	assert.ErrorIs(t, err, errors.ERROR_UNSUPPORTED_MESSAGE)
+}
+
+func TestParserRegistryDuplicate(t *testing.T) {
+	_, err := newParserRegistry([]ports.MessageParser{NewUrineParser(configurations.Urine{}), NewUrineParser(configurations.Urine{})})
+	assert.ErrorIs(t, err, errors.ERROR_DUPLICATED_PARSER)
+}
+
+func TestParserRegistryTextDefinitions(t *testing.T) {
+	output, err := NewTextParsers(configurations.TextParser{DefinitionDir: "../../helpers/parsers/testdata/text/definitions"})
+	assert.NoError(t, err)
+	assert.Len(t, output.Parsers, 2)
+
+	// the urine definition clashes with the built-in urine parser, the error
+	// names the definition file
+	_, err = newParserRegistry(append(output.Parsers, NewUrineParser(configurations.Urine{})))
+	assert.ErrorIs(t, err, errors.ERROR_DUPLICATED_PARSER)
+	assert.ErrorContains(t, err, "text definition urine.yaml and the built-in parser")
+
+	registry, err := newParserRegistry(output.Parsers)
+	assert.NoError(t, err)
+	messages, err := registry.parse(models.DeviceMessage{
+		Protocol:       models.PROTOCOL_RS232,
+		DeviceTypeCode: "hematology",
+		Message:        "---- BEGIN\nSAMPLE ID: H-77\nHGB = 13.9\n---- END\n",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+
+	serialized := messages[0].Serialize(models.DeviceMessage{DeviceID: "hema-1"})
+	assert.Equal(t, "H-77", serialized.PatientID)
+	assert.Equal(t, []models.Result{{
+		ParameterCode: "hgb",
+		ParameterName: "Hemoglobin",
+		Value:         "13.9",
+		NumericValue:  13.9,
+		Unit:          "g/dL",
+		Qualitative:   "13.9",
+	}}, serialized.Results)
+}
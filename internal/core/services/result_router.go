@@ -0,0 +1,139 @@
+package services
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// resultRouter picks the destinations of a result and formats the result
+// for each of them.
+type resultRouter struct {
+	destinations map[string]configurations.Destination
+	rules        []configurations.RoutingRule
+}
+
+func newResultRouter(routing configurations.Routing, lisPlatform configurations.LisPlatform) (*resultRouter, error) {
+	destinations, err := routing.DestinationList(lisPlatform)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := routing.RuleList()
+	if err != nil {
+		return nil, err
+	}
+
+	router := &resultRouter{destinations: map[string]configurations.Destination{}}
+	for _, destination := range destinations {
+		// a key without a secret would silently send unsigned requests
+		if destination.Auth.Scheme == configurations.DESTINATION_AUTH_HMAC &&
+			lisPlatform.SigningSecrets[destination.Auth.KeyID] == "" {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_SIGNING_CONFIG, "no secret for key id %q of %s", destination.Auth.KeyID, destination.Name)
+		}
+		router.destinations[destination.Name] = destination
+	}
+	for _, rule := range rules {
+		if _, ok := router.destinations[rule.Destination]; !ok {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_ROUTING_CONFIG, "rule of unknown destination %s", rule.Destination)
+		}
+	}
+	// entries are created in the same order for every message
+	slices.SortFunc(rules, func(a, b configurations.RoutingRule) int {
+		return strings.Compare(a.Destination, b.Destination)
+	})
+	router.rules = rules
+
+	return router, nil
+}
+
+// destination returns the destination of an outbox entry, entries written
+// before routing existed go to the LIS platform.
+func (r *resultRouter) destination(name string) (configurations.Destination, error) {
+	if name == "" {
+		name = configurations.DESTINATION_LIS_PLATFORM
+	}
+	destination, ok := r.destinations[name]
+	if !ok {
+		return destination, errors.Wrapf(errors.ERROR_UNKNOWN_DESTINATION, "destination %s", name)
+	}
+
+	return destination, nil
+}
+
+// route lists the destinations whose rule the result meets.
+func (r *resultRouter) route(serializer models.Serializer) []configurations.Destination {
+	destinations := []configurations.Destination{}
+	for _, rule := range r.rules {
+		if matchRoutingRule(rule, serializer) {
+			destinations = append(destinations, r.destinations[rule.Destination])
+		}
+	}
+
+	return destinations
+}
+
+// format serializes the result in the payload format of the destination.
+func (r *resultRouter) format(destination configurations.Destination, serializer models.Serializer) string {
+	var payload []byte
+	switch destination.Format {
+	case configurations.DESTINATION_FORMAT_FLAT:
+		payload, _ = json.Marshal(serializer.Flatten())
+	default:
+		payload, _ = json.Marshal(serializer)
+	}
+
+	return string(payload)
+}
+
+func matchRoutingRule(rule configurations.RoutingRule, serializer models.Serializer) bool {
+	switch {
+	case rule.All:
+		return true
+	case rule.None:
+		return false
+	}
+
+	matchAny := func(values []string, value string) bool {
+		return len(values) == 0 || slices.Contains(values, value)
+	}
+	if !matchAny(rule.DeviceIDs, serializer.DeviceID) ||
+		!matchAny(rule.DeviceTypeCodes, serializer.DeviceTypeCode) ||
+		!matchAny(rule.Protocols, serializer.Protocol) {
+		return false
+	}
+
+	// "ORU" matches every ORU event, "ORU^R01" only that one
+	if len(rule.MessageTypes) > 0 && !slices.ContainsFunc(rule.MessageTypes, func(messageType string) bool {
+		return serializer.MessageType == messageType || strings.HasPrefix(serializer.MessageType, messageType+"^")
+	}) {
+		return false
+	}
+
+	// a result goes to the destination when any of its parameters is wanted
+	if len(rule.ParameterCodes) > 0 && !slices.ContainsFunc(serializer.Results, func(result models.Result) bool {
+		return slices.Contains(rule.ParameterCodes, result.ParameterCode)
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// payloadDevice reads the device of a payload in any destination format, it
+// is empty when the payload is not one.
+func payloadDevice(payload string) (deviceID, deviceTypeCode, protocol string) {
+	var serializer models.Serializer
+	if json.Unmarshal([]byte(payload), &serializer) == nil {
+		return serializer.DeviceID, serializer.DeviceTypeCode, serializer.Protocol
+	}
+	var rows []models.FlatResult
+	if json.Unmarshal([]byte(payload), &rows) == nil && len(rows) > 0 {
+		return rows[0].DeviceID, rows[0].DeviceTypeCode, rows[0].Protocol
+	}
+
+	return "", "", ""
+}
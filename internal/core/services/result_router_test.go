@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultRouterRoute(t *testing.T) {
+	router, err := newResultRouter(configurations.Routing{
+		Destinations: map[string]string{
+			"lake": "url:http://lake.local",
+			"qc":   "url:http://qc.local",
+		},
+		Rules: map[string]string{
+			"lake": "device:urine-1|urine-2",
+			"qc":   "message_type:OUL;parameter:QC-GLU|QC-HGB",
+		},
+	}, configurations.LisPlatform{Url: "http://lis.local"})
+	assert.NoError(t, err)
+
+	names := func(serializer models.Serializer) []string {
+		res := []string{}
+		for _, destination := range router.route(serializer) {
+			res = append(res, destination.Name)
+		}
+
+		return res
+	}
+
+	assert.Equal(t, []string{"lake", "lis"}, names(models.Serializer{DeviceID: "urine-2"}))
+	assert.Equal(t, []string{"lis"}, names(models.Serializer{DeviceID: "hema-1", MessageType: "ORU^R01"}))
+	// every condition of a rule must be met
+	assert.Equal(t, []string{"lis"}, names(models.Serializer{DeviceID: "hema-1", MessageType: "OUL^R22"}))
+	assert.Equal(t, []string{"lis", "qc"}, names(models.Serializer{
+		DeviceID:    "hema-1",
+		MessageType: "OUL^R22",
+		Results:     []models.Result{{ParameterCode: "HGB"}, {ParameterCode: "QC-HGB"}},
+	}))
+
+	// the lis platform can be routed like any other destination
+	router, err = newResultRouter(configurations.Routing{
+		Rules: map[string]string{"lis": "protocol:hl7"},
+	}, configurations.LisPlatform{Url: "http://lis.local"})
+	assert.NoError(t, err)
+	assert.Empty(t, names(models.Serializer{Protocol: models.PROTOCOL_RS232}))
+	assert.Equal(t, []string{"lis"}, names(models.Serializer{Protocol: models.PROTOCOL_HL7}))
+
+	// rules of unknown destinations and keys without a secret are refused
+	_, err = newResultRouter(configurations.Routing{
+		Rules: map[string]string{"lake": "*"},
+	}, configurations.LisPlatform{Url: "http://lis.local"})
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG)
+	_, err = newResultRouter(configurations.Routing{
+		Destinations: map[string]string{"lake": "url:http://lake.local;auth:hmac lake-1"},
+	}, configurations.LisPlatform{Url: "http://lis.local", SigningSecrets: map[string]string{"lis-1": "secret"}})
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_SIGNING_CONFIG)
+
+	// a lis platform without url could never take a result
+	_, err = newResultRouter(configurations.Routing{}, configurations.LisPlatform{})
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG)
+}
+
+func TestDeviceMessageRouting(t *testing.T) {
+	var mx sync.Mutex
+	requests := map[string][]*http.Request{}
+	bodies := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mx.Lock()
+		requests[r.URL.Path] = append(requests[r.URL.Path], r)
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], string(body))
+		mx.Unlock()
+		// the data lake is down
+		if r.URL.Path == "/lake" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	service := newTestDeviceMessageService(t, server.URL+"/lis", DeviceMessageServiceInput{
+		Routing: configurations.Routing{
+			Destinations: map[string]string{
+				"lake": "url:" + server.URL + "/lake;auth:bearer lake-token;format:flat;attempts:2",
+				"qc":   "url:" + server.URL + "/qc;auth:basic qc:secret",
+			},
+			Rules: map[string]string{
+				"lake": "device:urine-1",
+				"qc":   "parameter:hba1c",
+			},
+		},
+	})
+	output, err := service.Process(context.Background(), &models.DeviceMessageInput{
+		DeviceID:       "urine-1",
+		DeviceTypeCode: URINE_DEVICE_TYPE_CODE,
+		Protocol:       models.PROTOCOL_RS232,
+		Message:        "NO.0012 2025-03-04\n09:15:30\nLEU -\nNO.0013 2025-03-04\n09:20:00\nLEU -\n",
+	})
+	assert.NoError(t, err)
+
+	// every specimen is tracked on its own for each destination
+	entries := service.outbox.list()
+	assert.Len(t, entries, 4)
+	status := map[string][]string{}
+	for _, entry := range entries {
+		assert.Equal(t, output.ID.String(), entry.DeviceMessageID)
+		status[entry.Destination] = append(status[entry.Destination], entry.Status)
+	}
+	assert.Equal(t, map[string][]string{
+		"lake": {models.OUTBOX_STATUS_PENDING, models.OUTBOX_STATUS_PENDING},
+		"lis":  {models.OUTBOX_STATUS_DELIVERED, models.OUTBOX_STATUS_DELIVERED},
+	}, status)
+	assert.Empty(t, requests["/qc"])
+
+	// the lake gets flat rows
+	assert.Len(t, requests["/lake"], 2)
+	var rows []models.FlatResult
+	assert.NoError(t, json.Unmarshal([]byte(bodies["/lake"][0]), &rows))
+	assert.Equal(t, "urine-1", rows[0].DeviceID)
+	assert.Equal(t, "leukocytes", rows[0].ParameterCode)
+
+	// the lake retry policy dead-letters its entries after two attempts
+	for _, entry := range service.outbox.list() {
+		if entry.Destination == "lake" {
+			assert.ErrorIs(t, service.dispatcher.Deliver(context.Background(), &entry), errors.ERROR_DELIVERY_FAILED)
+			assert.Equal(t, models.OUTBOX_STATUS_DEAD, entry.Status)
+		}
+	}
+	deadLetters := service.deadLetters.list()
+	assert.Len(t, deadLetters, 2)
+	assert.Equal(t, "lake", deadLetters[0].Destination)
+	assert.Equal(t, "urine-1", deadLetters[0].DeviceID)
+	assert.Equal(t, models.PROTOCOL_RS232, deadLetters[0].Protocol)
+
+	// an entry of a removed destination fails instead of going elsewhere
+	id := uuid.New()
+	entry := models.OutboxEntry{ID: &id, Destination: "archive", Payload: "{}", Status: models.OUTBOX_STATUS_PENDING}
+	assert.ErrorIs(t, service.dispatcher.Deliver(context.Background(), &entry), errors.ERROR_UNKNOWN_DESTINATION)
+	assert.Equal(t, 1, entry.Attempts)
+
+	// a destination no publisher delivers to is refused up front
+	_, err = NewOutboxDispatcher(OutboxDispatcherInput{
+		LisPlatform: configurations.LisPlatform{Url: server.URL + "/lis"},
+		Routing: configurations.Routing{
+			Destinations: map[string]string{"events": "url:kafka://broker:9092/results"},
+		},
+		Publishers: []ports.ResultPublisher{mockPublisher{}},
+	})
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_ROUTING_CONFIG)
+}
@@ -6,11 +6,51 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
 	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/parsers"
 	"github.com/Calmantara/lis-backend/internal/utils"
 )
 
+// URINE_DEVICE_TYPE_CODE is the device type of the rs232 urine analyzer.
+const URINE_DEVICE_TYPE_CODE = "urine"
+
+type urineParser struct {
+	config configurations.Urine
+}
+
+// NewUrineParser parses the text printout of the rs232 urine analyzer.
+func NewUrineParser(config configurations.Urine) ports.MessageParser {
+	return &urineParser{config: config}
+}
+
+// Keys keeps the urine parser the fallback of every rs232 device, as it was
+// before device types had parsers of their own.
+func (p *urineParser) Keys() []models.ParserKey {
+	return []models.ParserKey{
+		{Protocol: models.PROTOCOL_RS232, DeviceTypeCode: URINE_DEVICE_TYPE_CODE},
+		{Protocol: models.PROTOCOL_RS232},
+	}
+}
+
+func (p *urineParser) Parse(deviceMessage models.DeviceMessage) ([]models.Message, error) {
+	results, err := parseUrineTestText(deviceMessage.Message, deviceMessage.Location())
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]models.Message, 0, len(results))
+	for _, res := range results {
+		res.referenceRanges = p.config.ReferenceRanges
+		messages = append(messages, res)
+	}
+
+	return messages, nil
+}
+
 type UrineTestResult struct {
 	RawMessage string    `json:"raw_message"`
 	SpecimenID string    `json:"specimen_id"`
@@ -26,8 +66,13 @@ type UrineTestResult struct {
 	Glucose         TestValue `json:"glucose"`
 	Blood           TestValue `json:"blood"`
 	AscorbicAcid    TestValue `json:"ascorbic_acid"`
-	SpecificGravity float64   `json:"specific_gravity"`
-	PH              float64   `json:"ph"`
+	SpecificGravity TestValue `json:"specific_gravity"`
+	PH              TestValue `json:"ph"`
+
+	Diagnostics []parsers.Diagnostic `json:"diagnostics,omitempty"`
+
+	// referenceRanges maps a parameter code to its reference range
+	referenceRanges map[string]string
 }
 
 type TestValue struct {
@@ -36,6 +81,43 @@ type TestValue struct {
 	Numeric     float64 `json:"numeric,omitempty"`
 	IsNumeric   bool    `json:"is_numeric"`
 	Qualitative string  `json:"qualitative,omitempty"`
+	// Abnormal is set when the analyzer marks the line with an asterisk
+	Abnormal bool `json:"abnormal,omitempty"`
+}
+
+// urineParameter maps an analyzer code to its result field.
+type urineParameter struct {
+	raw   string
+	code  string
+	field func(*UrineTestResult) *TestValue
+	// numeric parameters are only stored when the value is a number
+	numeric bool
+}
+
+// urineParameters are listed in the order the results are published.
+var urineParameters = []urineParameter{
+	{raw: "LEU", code: "leukocytes", field: func(r *UrineTestResult) *TestValue { return &r.Leukocytes }},
+	{raw: "KET", code: "ketones", field: func(r *UrineTestResult) *TestValue { return &r.Ketones }},
+	{raw: "NIT", code: "nitrites", field: func(r *UrineTestResult) *TestValue { return &r.Nitrites }},
+	{raw: "URO", code: "urobilinogen", field: func(r *UrineTestResult) *TestValue { return &r.Urobilinogen }},
+	{raw: "BIL", code: "bilirubin", field: func(r *UrineTestResult) *TestValue { return &r.Bilirubin }},
+	{raw: "PRO", code: "protein", field: func(r *UrineTestResult) *TestValue { return &r.Protein }},
+	{raw: "GLU", code: "glucose", field: func(r *UrineTestResult) *TestValue { return &r.Glucose }},
+	{raw: "BLD", code: "blood", field: func(r *UrineTestResult) *TestValue { return &r.Blood }},
+	{raw: "VC", code: "ascorbic_acid", field: func(r *UrineTestResult) *TestValue { return &r.AscorbicAcid }},
+	{raw: "SG", code: "specific_gravity", field: func(r *UrineTestResult) *TestValue { return &r.SpecificGravity }, numeric: true},
+	{raw: "PH", code: "ph", field: func(r *UrineTestResult) *TestValue { return &r.PH }, numeric: true},
+}
+
+// lookupUrineParameter finds the parameter of an analyzer code, ignoring case.
+func lookupUrineParameter(raw string) *urineParameter {
+	for i := range urineParameters {
+		if strings.EqualFold(urineParameters[i].raw, raw) {
+			return &urineParameters[i]
+		}
+	}
+
+	return nil
 }
 
 func (result *UrineTestResult) Stringify() string {
@@ -44,6 +126,10 @@ func (result *UrineTestResult) Stringify() string {
 	return string(b)
 }
 
+func (result *UrineTestResult) ParseDiagnostics() []parsers.Diagnostic {
+	return result.Diagnostics
+}
+
 func (result *UrineTestResult) Serialize(deviceMessage models.DeviceMessage) models.Serializer {
 	seqNum := strconv.Itoa(utils.FindAllInteger(result.SpecimenID))
 
@@ -56,132 +142,108 @@ func (result *UrineTestResult) Serialize(deviceMessage models.DeviceMessage) mod
 		Timestamp:      result.DateTime,
 	}
 
-	// Leukocytes      TestValue `json:"leukocytes"`
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "leukocytes",
-		Unit:          result.Leukocytes.Unit,
-		Value:         result.Leukocytes.Value,
-		NumericValue:  result.Leukocytes.Numeric,
-		Qualitative:   result.Leukocytes.Qualitative,
-	})
-
-	// Ketones         TestValue `json:"ketones"`
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "ketones",
-		Unit:          result.Ketones.Unit,
-		Value:         result.Ketones.Value,
-		NumericValue:  result.Ketones.Numeric,
-		Qualitative:   result.Ketones.Qualitative,
-	})
-
-	// Nitrites        TestValue `json:"nitrites"`
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "nitrites",
-		Unit:          result.Nitrites.Unit,
-		Value:         result.Nitrites.Value,
-		NumericValue:  result.Nitrites.Numeric,
-		Qualitative:   result.Nitrites.Qualitative,
-	})
-
-	// Urobilinogen    TestValue `json:"urobilinogen"`
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "urobilinogen",
-		Unit:          result.Urobilinogen.Unit,
-		Value:         result.Urobilinogen.Value,
-		NumericValue:  result.Urobilinogen.Numeric,
-		Qualitative:   result.Urobilinogen.Qualitative,
-	})
-
-	// Bilirubin       TestValue `json:"bilirubin"`
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "bilirubin",
-		Unit:          result.Bilirubin.Unit,
-		Value:         result.Bilirubin.Value,
-		NumericValue:  result.Bilirubin.Numeric,
-		Qualitative:   result.Bilirubin.Qualitative,
-	})
-
-	// Protein         TestValue `json:"protein"`
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "protein",
-		Unit:          result.Protein.Unit,
-		Value:         result.Protein.Value,
-		NumericValue:  result.Protein.Numeric,
-		Qualitative:   result.Protein.Qualitative,
-	})
-
-	// Glucose         TestValue `json:"glucose"`
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "glucose",
-		Unit:          result.Glucose.Unit,
-		Value:         result.Glucose.Value,
-		NumericValue:  result.Glucose.Numeric,
-		Qualitative:   result.Glucose.Qualitative,
-	})
-
-	// Blood           TestValue `json:"blood"`
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "blood",
-		Unit:          result.Blood.Unit,
-		Value:         result.Blood.Value,
-		NumericValue:  result.Blood.Numeric,
-		Qualitative:   result.Blood.Qualitative,
-	})
-
-	// AscorbicAcid    TestValue `json:"ascorbic_acid"`
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "ascorbic_acid",
-		Unit:          result.AscorbicAcid.Unit,
-		Value:         result.AscorbicAcid.Value,
-		NumericValue:  result.AscorbicAcid.Numeric,
-		Qualitative:   result.AscorbicAcid.Qualitative,
-	})
-
-	// SpecificGravity
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "specific_gravity",
-		NumericValue:  result.SpecificGravity,
-	})
-
-	// PH
-	res.Results = append(res.Results, models.Result{
-		ParameterCode: "ph",
-		NumericValue:  result.PH,
-	})
+	res.Results = make([]models.Result, 0, len(urineParameters))
+	for _, parameter := range urineParameters {
+		value := *parameter.field(result)
+		res.Results = append(res.Results, models.Result{
+			ParameterCode:  parameter.code,
+			Unit:           value.Unit,
+			Value:          value.Value,
+			NumericValue:   value.Numeric,
+			Qualitative:    value.Qualitative,
+			ReferenceRange: result.referenceRanges[parameter.code],
+			AbnormalFlags:  result.flag(parameter.code, value),
+		})
+	}
 
 	return res
 }
 
-func parseUrineTestText(text string) (*UrineTestResult, error) {
-	lines := strings.Split(text, "\n")
+// parseUrineTestText reads every specimen of the text, a memory dump or a
+// batched upload holds one NO. record per specimen. The analyzer prints its
+// local time, location places it.
+func parseUrineTestText(text string, location *time.Location) ([]*UrineTestResult, error) {
+	results := []*UrineTestResult{}
+	for _, record := range splitUrineRecords(text) {
+		result, err := parseUrineRecord(record, location)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// urineRecord is the text of one specimen, first is the 1-based line number
+// of its first line in the message.
+type urineRecord struct {
+	first int
+	text  string
+}
+
+// splitUrineRecords cuts the text before every NO. line but the first one,
+// lines printed before the first specimen stay with it. Records are
+// substrings of the text.
+func splitUrineRecords(text string) []urineRecord {
+	records := []urineRecord{}
+	start, first, hasSpecimen := 0, 1, false
+	offset, number := 0, 1
+	for line := range strings.SplitSeq(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "NO.") {
+			if hasSpecimen {
+				// the record ends before the line break of the previous line
+				records = append(records, urineRecord{first: first, text: text[start : offset-1]})
+				start, first = offset, number
+			}
+			hasSpecimen = true
+		}
+		offset += len(line) + 1
+		number++
+	}
+
+	return append(records, urineRecord{first: first, text: text[start:]})
+}
+
+func parseUrineRecord(record urineRecord, location *time.Location) (*UrineTestResult, error) {
 	result := &UrineTestResult{}
+	diagnose := func(i int, diagnostic parsers.Diagnostic) {
+		diagnostic.Location = parsers.LineLocation(record.first + i)
+		result.Diagnostics = append(result.Diagnostics, diagnostic)
+	}
 
-	for i := 0; i < len(lines); i++ {
-		line := strings.TrimSpace(lines[i])
+	i := -1
+	for line := range strings.SplitSeq(record.text, "\n") {
+		i++
+		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 
-		// Parse specimen ID (first line)
+		// Parse specimen ID and date (first line)
 		if result.SpecimenID == "" && strings.HasPrefix(line, "NO.") {
-			parts := strings.Fields(line)
-			if len(parts) >= 1 {
-				result.SpecimenID = parts[0]
-			}
-			if len(parts) >= 2 {
-				// Parse date
-				if date, err := time.Parse("2006-01-02", parts[1]); err == nil {
-					result.DateTime = date
+			specimenID, rest := urineField(line)
+			date, _ := urineField(rest)
+			result.SpecimenID = specimenID
+			if date != "" {
+				if parsed, err := time.ParseInLocation("2006-01-02", date, location); err == nil {
+					result.DateTime = parsed
+				} else {
+					diagnose(i, parsers.Diagnostic{Kind: parsers.DIAGNOSTIC_INVALID_VALUE, Field: parsers.FIELD_TIMESTAMP, Value: date})
 				}
 			}
 			continue
 		}
+		// the analyzer banner is printed before the first specimen
+		if result.SpecimenID == "" {
+			continue
+		}
 
 		// Parse time (if on its own line)
-		if result.DateTime != (time.Time{}) && strings.Contains(line, ":") {
+		if !result.DateTime.IsZero() && strings.Contains(line, ":") {
 			if t, err := time.Parse("15:04:05", line); err == nil {
 				// Combine date and time
-				combined := time.Date(
+				result.DateTime = time.Date(
 					result.DateTime.Year(),
 					result.DateTime.Month(),
 					result.DateTime.Day(),
@@ -191,97 +253,284 @@ func parseUrineTestText(text string) (*UrineTestResult, error) {
 					0,
 					result.DateTime.Location(),
 				)
-				result.DateTime = combined
+			} else {
+				diagnose(i, parsers.Diagnostic{Kind: parsers.DIAGNOSTIC_INVALID_VALUE, Field: parsers.FIELD_TIMESTAMP, Value: line})
 			}
 			continue
 		}
 
 		// Parse test parameters
-		parseTestLine(line, result)
+		if diagnostic, ok := parseTestLine(line, result); !ok {
+			diagnose(i, diagnostic)
+		}
+	}
+
+	if result.SpecimenID == "" {
+		diagnose(0, parsers.Diagnostic{Kind: parsers.DIAGNOSTIC_MISSING_FIELD, Field: parsers.FIELD_SPECIMEN_ID})
+	}
+	if result.DateTime.IsZero() {
+		diagnose(0, parsers.Diagnostic{Kind: parsers.DIAGNOSTIC_MISSING_FIELD, Field: parsers.FIELD_TIMESTAMP})
 	}
 	// add raw data
-	result.RawMessage = text
+	result.RawMessage = record.text
 
 	return result, nil
 }
 
-func parseTestLine(line string, result *UrineTestResult) {
-	// Regular expressions for different test line formats
-	patterns := []*regexp.Regexp{
-		// Format: "SG         1.015     "
-		regexp.MustCompile(`^(\w{2,4})\s+([\d.*]+)`),
-		// Format: "*LEU +3    500 CELL/uL" (with asterisk)
-		regexp.MustCompile(`^\*?(\w{2,4})\s+([+\-]?\w+)\s+([\d.]+)\s+(\w+/?\w*)`),
-		// Format: "KET -        0 mmol/L"
-		regexp.MustCompile(`^(\w{2,4})\s+([+\-]?\w+)\s+([\d.]+)\s+(\w+/?\w*)`),
-		// Format: "NIT -   " (no value/unit)
-		regexp.MustCompile(`^(\w{2,4})\s+([+\-]?\w+)`),
-		// Format: "URO            Normal"
-		regexp.MustCompile(`^(\w{2,4})\s+([\w\s]+)$`),
-	}
-
-	for _, pattern := range patterns {
-		matches := pattern.FindStringSubmatch(line)
-		if matches == nil {
-			continue
+// urineField returns the first space separated field of the line and the
+// rest after it.
+func urineField(line string) (field, rest string) {
+	line = strings.TrimLeftFunc(line, unicode.IsSpace)
+	end := strings.IndexFunc(line, unicode.IsSpace)
+	if end < 0 {
+		return line, ""
+	}
+
+	return line[:end], line[end:]
+}
+
+// parseTestLine stores the test of the line in the result, the diagnostic
+// tells when the line is unrecognized or its value is not a number.
+func parseTestLine(line string, result *UrineTestResult) (parsers.Diagnostic, bool) {
+	// a leading asterisk is the analyzer abnormal marker
+	abnormal := strings.HasPrefix(line, "*")
+	line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+
+	tokens, ok := tokenizeUrineLine(line)
+	if !ok {
+		return parsers.Diagnostic{Kind: parsers.DIAGNOSTIC_UNRECOGNIZED, Value: line}, false
+	}
+	parameter := lookupUrineParameter(tokens.code)
+	if parameter == nil {
+		return parsers.Diagnostic{Kind: parsers.DIAGNOSTIC_UNRECOGNIZED, Value: line}, false
+	}
+
+	if parameter.numeric {
+		numeric, err := strconv.ParseFloat(tokens.value, 64)
+		if err != nil {
+			return parsers.Diagnostic{Kind: parsers.DIAGNOSTIC_INVALID_NUMBER, Field: parameter.code, Value: tokens.value}, false
 		}
+		*parameter.field(result) = TestValue{Value: tokens.value, Numeric: numeric, IsNumeric: true, Abnormal: abnormal}
 
-		param := strings.ToUpper(strings.TrimSpace(matches[1]))
-		value := strings.TrimSpace(matches[2])
+		return parsers.Diagnostic{}, true
+	}
 
-		// Extract unit and numeric value if present
-		var unit string
-		var numericVal float64
-		var hasNumeric bool
+	value := TestValue{
+		Value:       tokens.value,
+		Unit:        tokens.unit,
+		Qualitative: tokens.value,
+		Abnormal:    abnormal,
+	}
+	if tokens.numeric != "" {
+		if numeric, err := strconv.ParseFloat(tokens.numeric, 64); err == nil {
+			value.Numeric, value.IsNumeric = numeric, true
+		}
+	}
+	*parameter.field(result) = value
 
-		if len(matches) > 3 && matches[3] != "" {
-			if num, err := strconv.ParseFloat(matches[3], 64); err == nil {
-				numericVal = num
-				hasNumeric = true
+	return parsers.Diagnostic{}, true
+}
+
+// urineTokens are the parts of a test line, substrings of the line.
+type urineTokens struct {
+	code    string
+	value   string
+	numeric string
+	unit    string
+}
+
+// tokenizeUrineLine splits a test line without allocating. A line starts with
+// a code of 2 to 4 word characters followed by one of
+//
+//	SG         1.015          a run of digits, dots or asterisks
+//	LEU +3    500 CELL/uL    a grade, a number and a unit
+//	NIT -                    a grade or a word, the rest is ignored
+func tokenizeUrineLine(line string) (tokens urineTokens, ok bool) {
+	n := urineSpan(line, isUrineWord)
+	if n < 2 || n > 4 || n == len(line) || !isUrineSpace(line[n]) {
+		return tokens, false
+	}
+	tokens.code = line[:n]
+	rest := line[n+urineSpan(line[n:], isUrineSpace):]
+
+	if n := urineSpan(rest, isUrineDecimal); n > 0 {
+		tokens.value = rest[:n]
+		return tokens, true
+	}
+
+	// a grade, a number and a unit, each followed by spaces but the unit
+	end := urineSpan(rest, func(c byte) bool { return !isUrineSpace(c) })
+	if grade := rest[:end]; isUrineGrade(grade) && end < len(rest) {
+		next := rest[end+urineSpan(rest[end:], isUrineSpace):]
+		numeric := urineSpan(next, isUrineNumber)
+		if numeric > 0 && numeric < len(next) && isUrineSpace(next[numeric]) {
+			unit := next[numeric+urineSpan(next[numeric:], isUrineSpace):]
+			if n := urineUnitLen(unit); n > 0 {
+				tokens.value, tokens.numeric, tokens.unit = grade, next[:numeric], unit[:n]
+				return tokens, true
 			}
 		}
+	}
+
+	// a grade or a word at the start of the value
+	switch {
+	case len(rest) > 1 && isUrineSign(rest[0]) && isUrineWord(rest[1]):
+		tokens.value = rest[:1+urineSpan(rest[1:], isUrineWord)]
+	case len(rest) > 0 && isUrineWord(rest[0]):
+		tokens.value = rest[:urineSpan(rest, isUrineWord)]
+	case len(rest) > 0 && isUrineSign(rest[0]):
+		tokens.value = rest[:urineSpan(rest, isUrineSign)]
+	default:
+		return urineTokens{}, false
+	}
+
+	return tokens, true
+}
 
-		if len(matches) > 4 {
-			unit = strings.TrimSpace(matches[4])
+// isUrineGrade accepts an optionally signed word such as +3 or Normal, or a
+// run of signs such as +-.
+func isUrineGrade(value string) bool {
+	if value == "" {
+		return false
+	}
+	if urineSpan(value, isUrineSign) == len(value) {
+		return true
+	}
+	if isUrineSign(value[0]) {
+		value = value[1:]
+	}
+
+	return value != "" && urineSpan(value, isUrineWord) == len(value)
+}
+
+// urineUnitLen returns the length of the unit at the start of the value,
+// words joined by at most one slash such as CELL/uL.
+func urineUnitLen(value string) int {
+	n := urineSpan(value, isUrineWord)
+	if n == 0 {
+		return 0
+	}
+	if n < len(value) && value[n] == '/' {
+		n++
+		n += urineSpan(value[n:], isUrineWord)
+	}
+
+	return n
+}
+
+func urineSpan(value string, accept func(byte) bool) int {
+	for i := 0; i < len(value); i++ {
+		if !accept(value[i]) {
+			return i
 		}
+	}
+
+	return len(value)
+}
+
+func isUrineWord(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_'
+}
+
+func isUrineSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+}
 
-		testValue := TestValue{
-			Value:       value,
-			Unit:        unit,
-			Numeric:     numericVal,
-			IsNumeric:   hasNumeric,
-			Qualitative: value, // Store the qualitative result
+func isUrineSign(c byte) bool {
+	return c == '+' || c == '-'
+}
+
+func isUrineNumber(c byte) bool {
+	return c >= '0' && c <= '9' || c == '.'
+}
+
+// isUrineDecimal is a number that may carry asterisks.
+func isUrineDecimal(c byte) bool {
+	return isUrineNumber(c) || c == '*'
+}
+
+// Abnormal flags computed for urine results
+const (
+	URINE_FLAG_HIGH     = "H"
+	URINE_FLAG_LOW      = "L"
+	URINE_FLAG_ABNORMAL = "A"
+)
+
+var urineNumericRange = regexp.MustCompile(`^([\d.]+)\s*-\s*([\d.]+)$`)
+
+// flag compares a value with the reference range of its parameter, falling
+// back to the analyzer marker when the range does not decide.
+func (result *UrineTestResult) flag(code string, value TestValue) string {
+	flag := urineReferenceFlag(strings.TrimSpace(result.referenceRanges[code]), value)
+	if flag == "" && value.Abnormal {
+		return URINE_FLAG_ABNORMAL
+	}
+
+	return flag
+}
+
+// urineReferenceFlag returns H or L outside a numeric range and A for a
+// grade above the expected one, e.g. +2 against negative.
+func urineReferenceFlag(reference string, value TestValue) string {
+	if reference == "" || value.Value == "" {
+		return ""
+	}
+
+	if bounds := urineNumericRange.FindStringSubmatch(reference); bounds != nil {
+		low, lowErr := strconv.ParseFloat(bounds[1], 64)
+		high, highErr := strconv.ParseFloat(bounds[2], 64)
+		number, err := strconv.ParseFloat(value.Value, 64)
+		if err != nil && value.IsNumeric {
+			number, err = value.Numeric, nil
+		}
+		if lowErr != nil || highErr != nil || err != nil {
+			return ""
 		}
 
-		// Map parameter to struct field
-		switch param {
-		case "LEU":
-			result.Leukocytes = testValue
-		case "KET":
-			result.Ketones = testValue
-		case "NIT":
-			result.Nitrites = testValue
-		case "URO":
-			result.Urobilinogen = testValue
-		case "BIL":
-			result.Bilirubin = testValue
-		case "PRO":
-			result.Protein = testValue
-		case "GLU":
-			result.Glucose = testValue
-		case "SG":
-			if val, err := strconv.ParseFloat(value, 64); err == nil {
-				result.SpecificGravity = val
-			}
-		case "BLD":
-			result.Blood = testValue
-		case "PH":
-			if val, err := strconv.ParseFloat(value, 64); err == nil {
-				result.PH = val
-			}
-		case "VC":
-			result.AscorbicAcid = testValue
+		switch {
+		case number < low:
+			return URINE_FLAG_LOW
+		case number > high:
+			return URINE_FLAG_HIGH
 		}
-		break
+
+		return ""
 	}
+
+	expected, ok := urineGrade(reference)
+	if !ok {
+		return ""
+	}
+	grade, ok := urineGrade(value.Value)
+	if ok && grade > expected {
+		return URINE_FLAG_ABNORMAL
+	}
+
+	return ""
+}
+
+// urineGrade orders the graded results of the dipstick, trace sits between
+// negative and +1.
+func urineGrade(value string) (float64, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "-", "neg", "negative", "normal", "norm":
+		return 0, true
+	case "+-", "-+", "±", "trace", "tr":
+		return 0.5, true
+	case "+", "pos", "positive":
+		return 1, true
+	}
+
+	// +1, +2 and 1+, 2+ notations
+	digits := strings.Trim(value, "+")
+	if digits == value || digits == "" {
+		return 0, false
+	}
+	grade, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0, false
+	}
+
+	return float64(grade), true
 }
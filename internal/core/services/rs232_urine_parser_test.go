@@ -0,0 +1,278 @@
+package services
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/parsers"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// TestUrineParser_Corpus parses every printout in testdata/urine and compares
+// the parsed and serialized results with its golden json. Run with -update to
+// regenerate.
+func TestUrineParser_Corpus(t *testing.T) {
+	files, err := filepath.Glob("testdata/urine/*.txt")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	parser := NewUrineParser(configurations.Urine{ReferenceRanges: map[string]string{
+		"specific_gravity": "1.005-1.030",
+		"ph":               "5-8",
+		"leukocytes":       "negative",
+		"protein":          "negative",
+	}})
+	deviceMessage := models.DeviceMessage{
+		DeviceID:       "urine-1",
+		DeviceTypeCode: URINE_DEVICE_TYPE_CODE,
+		Protocol:       models.PROTOCOL_RS232,
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			msg, err := os.ReadFile(file)
+			assert.NoError(t, err)
+
+			deviceMessage.Message = string(msg)
+			messages, err := parser.Parse(deviceMessage)
+			assert.NoError(t, err)
+
+			serialized := []models.Serializer{}
+			for _, message := range messages {
+				serialized = append(serialized, message.Serialize(deviceMessage))
+			}
+			actual, err := json.MarshalIndent(map[string]any{
+				"messages":   messages,
+				"serialized": serialized,
+			}, "", "  ")
+			assert.NoError(t, err)
+
+			golden := strings.TrimSuffix(file, ".txt") + ".json"
+			if *update {
+				assert.NoError(t, os.WriteFile(golden, append(actual, '\n'), 0o644))
+			}
+			expected, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+const urineTestText = "NO.0012 2025-03-04\r\n" +
+	"09:15:30\r\n" +
+	"*LEU +3    500 CELL/uL\r\n" +
+	"KET -        0 mmol/L\r\n" +
+	"*NIT +\r\n" +
+	"URO            Normal\r\n" +
+	"PRO +-       0.15 g/L\r\n" +
+	"GLU -\r\n" +
+	"*SG         1.035\r\n" +
+	"PH         4.5\r\n"
+
+func TestUrineParserFlags(t *testing.T) {
+	parser := NewUrineParser(configurations.Urine{ReferenceRanges: map[string]string{
+		"specific_gravity": "1.005-1.030",
+		"ph":               "5-8",
+		"leukocytes":       "negative",
+		"protein":          "+-",
+		"glucose":          "negative",
+	}})
+
+	messages, err := parser.Parse(models.DeviceMessage{Message: urineTestText})
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+
+	results := map[string]models.Result{}
+	for _, result := range messages[0].Serialize(models.DeviceMessage{}).Results {
+		results[result.ParameterCode] = result
+	}
+
+	// graded against the expected grade
+	assert.Equal(t, "A", results["leukocytes"].AbnormalFlags)
+	assert.Equal(t, "negative", results["leukocytes"].ReferenceRange)
+	assert.Equal(t, "", results["protein"].AbnormalFlags)
+	assert.Equal(t, "", results["glucose"].AbnormalFlags)
+	// numeric ranges
+	assert.Equal(t, "H", results["specific_gravity"].AbnormalFlags)
+	assert.Equal(t, "1.035", results["specific_gravity"].Value)
+	assert.Equal(t, 1.035, results["specific_gravity"].NumericValue)
+	assert.Equal(t, "L", results["ph"].AbnormalFlags)
+	assert.Equal(t, "5-8", results["ph"].ReferenceRange)
+	// no range configured, the analyzer asterisk decides
+	assert.Equal(t, "A", results["nitrites"].AbnormalFlags)
+	assert.Equal(t, "+", results["nitrites"].Value)
+	assert.Equal(t, "", results["ketones"].AbnormalFlags)
+	assert.Equal(t, "", results["urobilinogen"].AbnormalFlags)
+}
+
+func TestUrineGrade(t *testing.T) {
+	for value, expected := range map[string]float64{
+		"-":        0,
+		"Negative": 0,
+		"+-":       0.5,
+		"+":        1,
+		"+1":       1,
+		"2+":       2,
+		"+3":       3,
+	} {
+		grade, ok := urineGrade(value)
+		assert.True(t, ok, value)
+		assert.Equal(t, expected, grade, value)
+	}
+
+	for _, value := range []string{"", "500", "1.015", "++"} {
+		_, ok := urineGrade(value)
+		assert.False(t, ok, value)
+	}
+}
+
+func TestUrineParserBatch(t *testing.T) {
+	parser := NewUrineParser(configurations.Urine{})
+
+	text := "MEMORY DATA\r\n" +
+		"NO.0001 2025-03-04\r\n09:00:00\r\nLEU -\r\nPH         6.0\r\n" +
+		"NO.0002 2025-03-04\r\n09:05:00\r\n*LEU +2    125 CELL/uL\r\nPH         7.0\r\n"
+	messages, err := parser.Parse(models.DeviceMessage{Message: text})
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+
+	first := messages[0].Serialize(models.DeviceMessage{})
+	second := messages[1].Serialize(models.DeviceMessage{})
+	assert.Equal(t, "NO.0001", first.PatientID)
+	assert.Equal(t, "1", first.SequenceNumber)
+	assert.Equal(t, "NO.0002", second.PatientID)
+	assert.Equal(t, "2", second.SequenceNumber)
+	assert.Equal(t, 5, second.Timestamp.Minute())
+
+	// results of one specimen never leak into the other
+	assert.Equal(t, "-", first.Results[0].Value)
+	assert.Equal(t, "+2", second.Results[0].Value)
+	assert.Equal(t, 6.0, first.Results[10].NumericValue)
+	assert.Equal(t, 7.0, second.Results[10].NumericValue)
+}
+
+func TestUrineParserDiagnostics(t *testing.T) {
+	parser := NewUrineParser(configurations.Urine{})
+
+	text := "Urine Analyzer\r\n" +
+		"NO.0001 2025-03-04\r\n09:00:00\r\nLEU -\r\nPH         six\r\n" +
+		"NO.0002\r\nLEU -\r\n??\r\n"
+	messages, err := parser.Parse(models.DeviceMessage{Message: text})
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+
+	// the banner before the first specimen is not reported
+	assert.Equal(t, []parsers.Diagnostic{
+		{Kind: parsers.DIAGNOSTIC_INVALID_NUMBER, Location: "line 5", Field: "ph", Value: "six"},
+	}, messages[0].ParseDiagnostics())
+	assert.Equal(t, []parsers.Diagnostic{
+		{Kind: parsers.DIAGNOSTIC_UNRECOGNIZED, Location: "line 8", Value: "??"},
+		{Kind: parsers.DIAGNOSTIC_MISSING_FIELD, Location: "line 6", Field: parsers.FIELD_TIMESTAMP},
+	}, messages[1].ParseDiagnostics())
+}
+
+// regexpTokenizeUrineLine is the tokenizer before the rewrite, kept as the
+// reference of tokenizeUrineLine and for the benchmarks.
+func regexpTokenizeUrineLine(line string) (urineTokens, bool) {
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`^(\w{2,4})\s+([\d.*]+)`),
+		regexp.MustCompile(`^(\w{2,4})\s+([+\-]?\w+|[+\-]+)\s+([\d.]+)\s+(\w+/?\w*)`),
+		regexp.MustCompile(`^(\w{2,4})\s+([+\-]?\w+)\s+([\d.]+)\s+(\w+/?\w*)`),
+		regexp.MustCompile(`^(\w{2,4})\s+([+\-]?\w+|[+\-]+)`),
+		regexp.MustCompile(`^(\w{2,4})\s+([\w\s]+)$`),
+	}
+
+	for _, pattern := range patterns {
+		matches := pattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		tokens := urineTokens{code: matches[1], value: strings.TrimSpace(matches[2])}
+		if len(matches) > 4 {
+			tokens.numeric, tokens.unit = matches[3], strings.TrimSpace(matches[4])
+		}
+
+		return tokens, true
+	}
+
+	return urineTokens{}, false
+}
+
+func urineCorpusLines(t testing.TB) []string {
+	files, err := filepath.Glob("testdata/urine/*.txt")
+	assert.NoError(t, err)
+
+	lines := []string{
+		"LEU", "LE-U 5", "LEU -5", "LEU --5", "LEU +-", "LEU -+x", "LEU +3 5 CELL/", "LEU +3 5. /uL",
+		"LEU +3 5 CELL/uL/x", "LEU 1+ 5 g/L", "LEU +3/ 5 g/L", "SG 1.0*", "SG .", "SG\t1.015", "URO normal range",
+		"URO ± 1", "GLU _ 1 g", "PH 7", "A1_B 2", "PH 7\x0b", "KET - 0 mmol/L trailing",
+	}
+	for _, file := range files {
+		msg, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		for line := range strings.SplitSeq(string(msg), "\n") {
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "*")))
+		}
+	}
+
+	return lines
+}
+
+func TestTokenizeUrineLine(t *testing.T) {
+	for _, line := range urineCorpusLines(t) {
+		expected, expectedOK := regexpTokenizeUrineLine(line)
+		actual, ok := tokenizeUrineLine(line)
+		assert.Equal(t, expectedOK, ok, line)
+		assert.Equal(t, expected, actual, line)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		tokenizeUrineLine("LEU +3    500 CELL/uL")
+	})
+	assert.Zero(t, allocs)
+}
+
+func BenchmarkTokenizeUrineLine(b *testing.B) {
+	lines := urineCorpusLines(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, line := range lines {
+			tokenizeUrineLine(line)
+		}
+	}
+}
+
+func BenchmarkTokenizeUrineLine_Regexp(b *testing.B) {
+	lines := urineCorpusLines(b)
+	b.ReportAllocs()
+	for b.Loop() {
+		for _, line := range lines {
+			regexpTokenizeUrineLine(line)
+		}
+	}
+}
+
+// BenchmarkUrineParser parses a memory dump of 500 specimens.
+func BenchmarkUrineParser(b *testing.B) {
+	record, err := os.ReadFile("testdata/urine/single.txt")
+	assert.NoError(b, err)
+	parser := NewUrineParser(configurations.Urine{})
+	deviceMessage := models.DeviceMessage{Message: strings.Repeat(string(record), 500)}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := parser.Parse(deviceMessage); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
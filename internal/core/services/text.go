@@ -0,0 +1,114 @@
+package services
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/helpers/parsers"
+	"github.com/Calmantara/lis-backend/internal/utils"
+	"go.uber.org/dig"
+)
+
+type TextTestResult struct {
+	parsers.TextRecord
+}
+
+func (s *TextTestResult) Stringify() string {
+	b, _ := json.Marshal(s)
+
+	return string(b)
+}
+
+func (s *TextTestResult) ParseDiagnostics() []parsers.Diagnostic {
+	return s.Diagnostics
+}
+
+func (s *TextTestResult) Serialize(deviceMessage models.DeviceMessage) models.Serializer {
+	res := models.Serializer{
+		DeviceID:       deviceMessage.DeviceID,
+		Protocol:       string(deviceMessage.Protocol),
+		DeviceTypeCode: deviceMessage.DeviceTypeCode,
+		SequenceNumber: strconv.Itoa(utils.FindAllInteger(s.SpecimenID)),
+		PatientID:      s.SpecimenID,
+		Timestamp:      parseISOTimestamp(s.DateTime, deviceMessage.Location()),
+		Results:        []models.Result{},
+	}
+
+	for _, test := range s.Tests {
+		result := models.Result{
+			ParameterCode: test.Code,
+			ParameterName: test.Name,
+			Value:         test.Value,
+			Unit:          test.Unit,
+			Qualitative:   test.Qualitative,
+		}
+		if test.NumericValue != nil {
+			result.NumericValue = *test.NumericValue
+		}
+		res.Results = append(res.Results, result)
+	}
+
+	return res
+}
+
+type textParser struct {
+	parser *parsers.TextParser
+	source string
+}
+
+func (p *textParser) Source() string {
+	return "text definition " + p.source
+}
+
+func (p *textParser) Keys() []models.ParserKey {
+	return []models.ParserKey{{Protocol: models.PROTOCOL_RS232, DeviceTypeCode: p.parser.DeviceTypeCode()}}
+}
+
+func (p *textParser) Parse(deviceMessage models.DeviceMessage) ([]models.Message, error) {
+	parsedMessage, err := p.parser.Parse(deviceMessage.Message)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsedMessage.Records) == 0 {
+		return nil, errors.Wrap(errors.ERROR_INVALID_TEXT_MESSAGE, "no record found")
+	}
+
+	messages := make([]models.Message, 0, len(parsedMessage.Records))
+	for _, record := range parsedMessage.Records {
+		messages = append(messages, &TextTestResult{TextRecord: record})
+	}
+
+	return messages, nil
+}
+
+type TextParsersOutput struct {
+	dig.Out
+	Parsers []ports.MessageParser `group:"messageParsers,flatten"`
+}
+
+// NewTextParsers registers an rs232 parser for every text definition of the
+// configured directory.
+func NewTextParsers(config configurations.TextParser) (output TextParsersOutput, err error) {
+	if config.DefinitionDir == "" {
+		return output, nil
+	}
+
+	definitions, err := parsers.LoadTextDefinitions(config.DefinitionDir)
+	if err != nil {
+		return output, err
+	}
+
+	for _, definition := range definitions {
+		parser, err := parsers.NewTextParser(definition)
+		if err != nil {
+			return output, err
+		}
+		output.Parsers = append(output.Parsers, &textParser{parser: parser, source: definition.Source})
+	}
+
+	return output, nil
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/Calmantara/lis-backend/internal/helpers/ucum"
+)
+
+// UNIT_SIGNIFICANT_DIGITS rounds converted values so factors such as the
+// glucose molar mass do not print a long tail of digits.
+const UNIT_SIGNIFICANT_DIGITS = 4
+
+// defaultMolarMasses in g/mol of the analytes reported in both mass and
+// substance concentrations, configured values win.
+var defaultMolarMasses = map[string]float64{
+	"glucose":       180.16,
+	"bilirubin":     584.66,
+	"urobilinogen":  590.72,
+	"ketones":       102.09,
+	"creatinine":    113.12,
+	"urea":          60.06,
+	"uric_acid":     168.11,
+	"cholesterol":   386.65,
+	"triglycerides": 885.7,
+	"calcium":       40.08,
+}
+
+var unitNumericRange = regexp.MustCompile(`^([\d.]+)\s*-\s*([\d.]+)$`)
+
+// unitNormalizer maps the units of the results to UCUM codes and converts
+// them to the canonical unit of their parameter before they are published.
+type unitNormalizer struct {
+	aliases     map[string]string
+	canonical   map[string]string
+	molarMasses map[string]float64
+}
+
+func newUnitNormalizer(config configurations.Units) (*unitNormalizer, error) {
+	normalizer := &unitNormalizer{
+		aliases:     map[string]string{},
+		canonical:   map[string]string{},
+		molarMasses: map[string]float64{},
+	}
+	for unit, code := range config.Aliases {
+		normalizer.aliases[strings.ToLower(strings.TrimSpace(unit))] = strings.TrimSpace(code)
+	}
+	for code, unit := range config.Canonical {
+		unit = strings.TrimSpace(unit)
+		if !ucum.Valid(unit) {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_UNIT_CONFIG, "canonical unit %q of %s", unit, code)
+		}
+		normalizer.canonical[strings.TrimSpace(code)] = unit
+	}
+	for code, molarMass := range defaultMolarMasses {
+		normalizer.molarMasses[code] = molarMass
+	}
+	for code, value := range config.MolarMasses {
+		molarMass, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || molarMass <= 0 {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_UNIT_CONFIG, "molar mass %q of %s", value, code)
+		}
+		normalizer.molarMasses[strings.TrimSpace(code)] = molarMass
+	}
+
+	return normalizer, nil
+}
+
+// normalize rewrites every result of the serializer, the flat list and the
+// per order copies alike.
+func (n *unitNormalizer) normalize(serializer *models.Serializer) {
+	for i := range serializer.Results {
+		n.normalizeResult(&serializer.Results[i])
+	}
+	for i := range serializer.Orders {
+		for j := range serializer.Orders[i].Results {
+			n.normalizeResult(&serializer.Orders[i].Results[j])
+		}
+	}
+}
+
+// normalizeResult replaces the unit with its UCUM code and converts the value
+// and a numeric reference range to the canonical unit. Unknown units are left
+// as printed, a unit that cannot be converted keeps its value.
+func (n *unitNormalizer) normalizeResult(result *models.Result) {
+	code, ok := n.code(result.Unit)
+	if !ok {
+		return
+	}
+	original := *result
+	result.Unit = code
+
+	if canonical := n.canonical[result.ParameterCode]; canonical != "" && canonical != code {
+		molarMass := n.molarMasses[result.ParameterCode]
+		if converted, err := ucum.Convert(result.NumericValue, code, canonical, molarMass); err == nil {
+			result.Unit = canonical
+			result.NumericValue = roundSignificant(converted)
+			// grades such as +3 do not depend on the unit
+			if value, err := strconv.ParseFloat(result.Value, 64); err == nil {
+				converted, _ := ucum.Convert(value, code, canonical, molarMass)
+				result.Value = strconv.FormatFloat(roundSignificant(converted), 'f', -1, 64)
+			}
+			result.ReferenceRange = convertRange(result.ReferenceRange, code, canonical, molarMass)
+		}
+	}
+
+	if result.Unit != original.Unit || result.Value != original.Value {
+		result.OriginalUnit = original.Unit
+		result.OriginalValue = original.Value
+	}
+}
+
+func (n *unitNormalizer) code(unit string) (string, bool) {
+	if code, ok := n.aliases[strings.ToLower(strings.TrimSpace(unit))]; ok {
+		return code, true
+	}
+
+	return ucum.Code(unit)
+}
+
+// convertRange converts a "low-high" reference range, other ranges such as
+// "negative" are returned unchanged.
+func convertRange(reference, from, to string, molarMass float64) string {
+	bounds := unitNumericRange.FindStringSubmatch(strings.TrimSpace(reference))
+	if bounds == nil {
+		return reference
+	}
+
+	converted := make([]string, 0, 2)
+	for _, bound := range bounds[1:] {
+		value, err := strconv.ParseFloat(bound, 64)
+		if err != nil {
+			return reference
+		}
+		value, err = ucum.Convert(value, from, to, molarMass)
+		if err != nil {
+			return reference
+		}
+		converted = append(converted, strconv.FormatFloat(roundSignificant(value), 'f', -1, 64))
+	}
+
+	return strings.Join(converted, "-")
+}
+
+func roundSignificant(value float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(value, 'g', UNIT_SIGNIFICANT_DIGITS, 64), 64)
+	if err != nil {
+		return value
+	}
+
+	return rounded
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnitNormalizer(t *testing.T) {
+	normalizer, err := newUnitNormalizer(configurations.Units{
+		Aliases:   map[string]string{"Ery/HPF": "/[HPF]"},
+		Canonical: map[string]string{"glucose": "mmol/L", "leukocytes": "/uL", "protein": "g/L"},
+	})
+	assert.NoError(t, err)
+
+	serializer := models.Serializer{
+		Results: []models.Result{
+			{ParameterCode: "glucose", Value: "90", NumericValue: 90, Unit: "mg/dL", ReferenceRange: "70-100"},
+			{ParameterCode: "leukocytes", Value: "+3", NumericValue: 500, Unit: "CELL/uL", ReferenceRange: "negative"},
+			{ParameterCode: "ketones", Value: "-", Unit: "MMOL/L"},
+			{ParameterCode: "erythrocytes", Value: "2", NumericValue: 2, Unit: "ery/hpf"},
+			{ParameterCode: "protein", Value: "+1", NumericValue: 3, Unit: "%"},
+			{ParameterCode: "urobilinogen", Value: "Normal", Unit: "EU/dL"},
+		},
+		Orders: []models.Order{{Results: []models.Result{
+			{ParameterCode: "glucose", Value: "5.5", NumericValue: 5.5, Unit: "mmol/L"},
+		}}},
+	}
+	normalizer.normalize(&serializer)
+
+	assert.Equal(t, []models.Result{
+		// converted through the molar mass of glucose
+		{ParameterCode: "glucose", Value: "4.996", NumericValue: 4.996, Unit: "mmol/L", ReferenceRange: "3.885-5.551", OriginalValue: "90", OriginalUnit: "mg/dL"},
+		// a grade keeps its text, the number is converted
+		{ParameterCode: "leukocytes", Value: "+3", NumericValue: 500, Unit: "/uL", ReferenceRange: "negative", OriginalValue: "+3", OriginalUnit: "CELL/uL"},
+		{ParameterCode: "ketones", Value: "-", Unit: "mmol/L", OriginalValue: "-", OriginalUnit: "MMOL/L"},
+		{ParameterCode: "erythrocytes", Value: "2", NumericValue: 2, Unit: "/[HPF]", OriginalValue: "2", OriginalUnit: "ery/hpf"},
+		// a percentage cannot be converted to a mass concentration
+		{ParameterCode: "protein", Value: "+1", NumericValue: 3, Unit: "%"},
+		// unknown units are left as printed
+		{ParameterCode: "urobilinogen", Value: "Normal", Unit: "EU/dL"},
+	}, serializer.Results)
+	assert.Equal(t, models.Result{ParameterCode: "glucose", Value: "5.5", NumericValue: 5.5, Unit: "mmol/L"}, serializer.Orders[0].Results[0])
+}
+
+func TestUnitNormalizerInvalidConfig(t *testing.T) {
+	_, err := newUnitNormalizer(configurations.Units{Canonical: map[string]string{"glucose": "mmol/h"}})
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_UNIT_CONFIG)
+
+	_, err = newUnitNormalizer(configurations.Units{MolarMasses: map[string]string{"glucose": "heavy"}})
+	assert.ErrorIs(t, err, errors.ERROR_INVALID_UNIT_CONFIG)
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/ports"
+)
+
+const (
+	WORKER_TASK_GROUP = "workerTasks"
+
+	DEFAULT_WORKER_OUTBOX_INTERVAL    = 15 * time.Second
+	DEFAULT_WORKER_RETENTION_INTERVAL = time.Hour
+	DEFAULT_WORKER_REPROCESS_INTERVAL = 10 * time.Minute
+)
+
+// outboxDeliveryTask retries the pending outbox entries whose backoff ended.
+type outboxDeliveryTask struct {
+	dispatcher ports.OutboxDispatcher
+	interval   time.Duration
+}
+
+func NewOutboxDeliveryTask(dispatcher ports.OutboxDispatcher, config configurations.Worker) ports.WorkerTask {
+	interval := config.OutboxInterval
+	if interval <= 0 {
+		interval = DEFAULT_WORKER_OUTBOX_INTERVAL
+	}
+
+	return &outboxDeliveryTask{dispatcher: dispatcher, interval: interval}
+}
+
+func (t *outboxDeliveryTask) Name() string {
+	return "outbox_delivery"
+}
+
+func (t *outboxDeliveryTask) Interval() time.Duration {
+	return t.interval
+}
+
+func (t *outboxDeliveryTask) Run(ctx context.Context) (int, error) {
+	return t.dispatcher.Dispatch(ctx)
+}
+
+// outboxRetentionTask deletes the entries delivered before the retention
+// period, nothing is deleted when it is zero.
+type outboxRetentionTask struct {
+	outboxCommand ports.OutboxCommand
+	interval      time.Duration
+	retention     time.Duration
+	now           func() time.Time
+}
+
+func NewOutboxRetentionTask(outboxCommand ports.OutboxCommand, config configurations.Worker) ports.WorkerTask {
+	interval := config.RetentionInterval
+	if interval <= 0 {
+		interval = DEFAULT_WORKER_RETENTION_INTERVAL
+	}
+
+	return &outboxRetentionTask{
+		outboxCommand: outboxCommand,
+		interval:      interval,
+		retention:     config.OutboxRetention,
+		now:           time.Now,
+	}
+}
+
+func (t *outboxRetentionTask) Name() string {
+	return "outbox_retention"
+}
+
+func (t *outboxRetentionTask) Interval() time.Duration {
+	return t.interval
+}
+
+func (t *outboxRetentionTask) Run(ctx context.Context) (int, error) {
+	if t.retention <= 0 {
+		return 0, nil
+	}
+
+	return t.outboxCommand.Purge(ctx, t.now().Add(-t.retention), DEFAULT_OUTBOX_BATCH_SIZE)
+}
+
+// deadLetterRetentionTask deletes the dead letters closed before the
+// retention period, nothing is deleted when it is zero.
+type deadLetterRetentionTask struct {
+	deadLetterCommand ports.DeadLetterCommand
+	interval          time.Duration
+	retention         time.Duration
+	now               func() time.Time
+}
+
+func NewDeadLetterRetentionTask(deadLetterCommand ports.DeadLetterCommand, config configurations.Worker) ports.WorkerTask {
+	interval := config.RetentionInterval
+	if interval <= 0 {
+		interval = DEFAULT_WORKER_RETENTION_INTERVAL
+	}
+
+	return &deadLetterRetentionTask{
+		deadLetterCommand: deadLetterCommand,
+		interval:          interval,
+		retention:         config.DeadLetterRetention,
+		now:               time.Now,
+	}
+}
+
+func (t *deadLetterRetentionTask) Name() string {
+	return "dead_letter_retention"
+}
+
+func (t *deadLetterRetentionTask) Interval() time.Duration {
+	return t.interval
+}
+
+func (t *deadLetterRetentionTask) Run(ctx context.Context) (int, error) {
+	if t.retention <= 0 {
+		return 0, nil
+	}
+
+	return t.deadLetterCommand.Purge(ctx, t.now().Add(-t.retention), DEFAULT_OUTBOX_BATCH_SIZE)
+}
+
+// deadLetterReprocessTask processes the open dead letters again until they
+// ran out of rounds, nothing is reprocessed when the rounds are zero.
+type deadLetterReprocessTask struct {
+	service  ports.DeadLetterService
+	interval time.Duration
+	rounds   int
+}
+
+func NewDeadLetterReprocessTask(service ports.DeadLetterService, config configurations.Worker) ports.WorkerTask {
+	interval := config.ReprocessInterval
+	if interval <= 0 {
+		interval = DEFAULT_WORKER_REPROCESS_INTERVAL
+	}
+
+	return &deadLetterReprocessTask{service: service, interval: interval, rounds: config.ReprocessRounds}
+}
+
+func (t *deadLetterReprocessTask) Name() string {
+	return "dead_letter_reprocess"
+}
+
+func (t *deadLetterReprocessTask) Interval() time.Duration {
+	return t.interval
+}
+
+func (t *deadLetterReprocessTask) Run(ctx context.Context) (int, error) {
+	if t.rounds <= 0 {
+		return 0, nil
+	}
+
+	return t.service.Reprocess(ctx, t.rounds, t.interval)
+}
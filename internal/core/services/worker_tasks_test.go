@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/core/configurations"
+	"github.com/Calmantara/lis-backend/internal/core/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxRetentionTask(t *testing.T) {
+	now := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	old, recent := now.Add(-40*24*time.Hour), now.Add(-time.Hour)
+
+	outbox := &mockOutboxCommand{}
+	entries := []models.OutboxEntry{
+		{Status: models.OUTBOX_STATUS_DELIVERED, DeliveredAt: &old},
+		{Status: models.OUTBOX_STATUS_DELIVERED, DeliveredAt: &recent},
+		{Status: models.OUTBOX_STATUS_PENDING},
+	}
+	for i := range entries {
+		id := uuid.New()
+		entries[i].ID = &id
+	}
+	assert.NoError(t, outbox.Create(context.Background(), entries))
+
+	// a zero retention keeps everything
+	task := NewOutboxRetentionTask(outbox, configurations.Worker{}).(*outboxRetentionTask)
+	assert.Equal(t, DEFAULT_WORKER_RETENTION_INTERVAL, task.Interval())
+	purged, err := task.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+
+	task = NewOutboxRetentionTask(outbox, configurations.Worker{OutboxRetention: 30 * 24 * time.Hour}).(*outboxRetentionTask)
+	task.now = func() time.Time { return now }
+	purged, err = task.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+
+	remaining := outbox.list()
+	assert.Len(t, remaining, 2)
+	assert.Equal(t, entries[1].ID, remaining[0].ID)
+	assert.Equal(t, entries[2].ID, remaining[1].ID)
+}
+
+func TestDeadLetterRetentionTask(t *testing.T) {
+	now := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+	deadLetters := &mockDeadLetterStore{}
+	for _, status := range []string{models.DEAD_LETTER_STATUS_REPLAYED, models.DEAD_LETTER_STATUS_DISCARDED, models.DEAD_LETTER_STATUS_OPEN} {
+		deadLetter := newDeadLetter(models.DEAD_LETTER_REASON_PARSE_FAILED, nil)
+		deadLetter.Status = status
+		deadLetter.UpdatedAt = now.Add(-100 * 24 * time.Hour)
+		assert.NoError(t, deadLetters.Create(context.Background(), deadLetter))
+	}
+
+	task := NewDeadLetterRetentionTask(deadLetters, configurations.Worker{}).(*deadLetterRetentionTask)
+	purged, err := task.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+
+	// open dead letters wait for support staff whatever their age
+	task = NewDeadLetterRetentionTask(deadLetters, configurations.Worker{DeadLetterRetention: 90 * 24 * time.Hour}).(*deadLetterRetentionTask)
+	task.now = func() time.Time { return now }
+	purged, err = task.Run(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, purged)
+	remaining := deadLetters.list()
+	assert.Len(t, remaining, 1)
+	assert.Equal(t, models.DEAD_LETTER_STATUS_OPEN, remaining[0].Status)
+}
@@ -0,0 +1,108 @@
+package charset
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+const UTF8 = "UTF-8"
+
+// aliases maps the lower case names the index does not know to one it does,
+// the HL7 table 0211 values declared in MSH-18 among them.
+var aliases = map[string]string{
+	"ascii":          UTF8,
+	"unicode":        UTF8,
+	"unicode utf-8":  UTF8,
+	"utf8":           UTF8,
+	"cp1252":         "windows-1252",
+	"cp1250":         "windows-1250",
+	"cp1251":         "windows-1251",
+	"gb2312":         "GBK",
+	"gb 18030":       "GB18030",
+	"big-5":          "Big5",
+	"ks x 1001":      "EUC-KR",
+	"iso ir14":       "Shift_JIS",
+	"iso ir87":       "ISO-2022-JP",
+	"unicode utf-16": "UTF-16",
+}
+
+// Lookup returns the encoding of a charset name and its canonical name. IANA
+// names and labels such as latin1 are accepted, as are HL7 names such as
+// 8859/1.
+func Lookup(name string) (encoding.Encoding, string, error) {
+	label := strings.TrimSpace(name)
+	if alias, ok := aliases[strings.ToLower(label)]; ok {
+		label = alias
+	}
+	if number, ok := strings.CutPrefix(label, "8859/"); ok {
+		label = "ISO-8859-" + number
+	}
+
+	enc, err := ianaindex.IANA.Encoding(label)
+	if err != nil || enc == nil {
+		return nil, "", errors.Wrapf(errors.ERROR_UNSUPPORTED_CHARSET, "charset %q", name)
+	}
+	canonical, err := ianaindex.MIME.Name(enc)
+	if err != nil {
+		canonical, _ = ianaindex.IANA.Name(enc)
+	}
+
+	return enc, canonical, nil
+}
+
+// Decode transcodes the bytes from the charset to UTF-8, bytes the charset
+// does not map are replaced with U+FFFD.
+func Decode(raw []byte, name string) (string, error) {
+	enc, canonical, err := Lookup(name)
+	if err != nil {
+		return "", err
+	}
+	if canonical == UTF8 {
+		return string(raw), nil
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		return "", errors.Wrapf(errors.ERROR_UNSUPPORTED_CHARSET, "decode %s: %v", canonical, err)
+	}
+
+	return string(decoded), nil
+}
+
+// Valid tells whether the bytes are already UTF-8.
+func Valid(raw []byte) bool {
+	return utf8.Valid(raw)
+}
+
+// DeclaredHL7 returns the first character set of MSH-18, empty when the
+// message declares none. The header is read before decoding, its delimiters
+// are ASCII in every charset HL7 allows there.
+func DeclaredHL7(raw []byte) string {
+	start := bytes.Index(raw, []byte("MSH"))
+	if start < 0 || len(raw) < start+8 {
+		return ""
+	}
+	header := raw[start:]
+	if end := bytes.IndexAny(header, "\r\n"); end >= 0 {
+		header = header[:end]
+	}
+
+	fieldSeparator := header[3]
+	// MSH-1 is the separator itself, so MSH-18 is the 17th field after it
+	fields := bytes.Split(header[4:], []byte{fieldSeparator})
+	if len(fields) < 17 {
+		return ""
+	}
+	value := fields[16]
+	// MSH-2 lists the component, repetition and escape characters
+	if encodingCharacters := fields[0]; len(encodingCharacters) > 1 {
+		value, _, _ = bytes.Cut(value, encodingCharacters[1:2])
+	}
+
+	return strings.TrimSpace(string(value))
+}
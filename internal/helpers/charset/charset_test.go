@@ -0,0 +1,60 @@
+package charset
+
+import (
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	for name, expected := range map[string]string{
+		"UTF-8":         UTF8,
+		"ASCII":         UTF8,
+		"UNICODE UTF-8": UTF8,
+		"8859/1":        "ISO-8859-1",
+		"latin1":        "ISO-8859-1",
+		"8859/15":       "ISO-8859-15",
+		"cp1252":        "windows-1252",
+		"Windows-1252":  "windows-1252",
+		"GB2312":        "GBK",
+		"GB 18030":      "GB18030",
+		"BIG-5":         "Big5",
+	} {
+		_, canonical, err := Lookup(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, canonical, name)
+	}
+
+	_, _, err := Lookup("EBCDIC-XYZ")
+	assert.ErrorIs(t, err, errors.ERROR_UNSUPPORTED_CHARSET)
+}
+
+func TestDecode(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		raw      []byte
+		expected string
+	}{
+		{"ISO-8859-1", []byte("\xb5mol/L Jos\xe9"), "µmol/L José"},
+		{"windows-1252", []byte("\x80 \xb5g/dL"), "€ µg/dL"},
+		{"GB2312", []byte("\xd5\xc5\xc8\xfd"), "张三"},
+		{"UTF-8", []byte("µmol/L"), "µmol/L"},
+	} {
+		decoded, err := Decode(tc.raw, tc.name)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, decoded, tc.name)
+	}
+}
+
+func TestDeclaredHL7(t *testing.T) {
+	for message, expected := range map[string]string{
+		"MSH|^~\\&|LAB|FAC|LIS|FAC|20250304091530||ORU^R01|1|P|2.5|||||CHN|8859/1\rPID|1":            "8859/1",
+		"MSH|^~\\&|LAB|FAC|LIS|FAC|20250304091530||ORU^R01|1|P|2.5|||||CHN|GB 18030~UNICODE UTF-8\r": "GB 18030",
+		"\x0bMSH#^~\\&#LAB#FAC#LIS#FAC#20250304091530##ORU^R01#1#P#2.5#####CHN#UNICODE UTF-8\n":      "UNICODE UTF-8",
+		"MSH|^~\\&|LAB|FAC|LIS|FAC|20250304091530||ORU^R01|1|P|2.5\rPID|1|||||||||||||||||8859/1":    "",
+		"PID|1": "",
+	} {
+		assert.Equal(t, expected, DeclaredHL7([]byte(message)), message)
+	}
+}
@@ -16,6 +16,36 @@ var (
 	ERROR_CREATE_ACCESS_TOKEN           = New("Failed to create access token")
 	ERROR_CREATE_SESSION_TOKEN          = New("Failed to create session token")
 	ERROR_DUPLICATED_KEY                = New("Duplicate key value violates unique constraint")
+	ERROR_LISTENER_CLOSED               = New("Listener has been closed")
+	ERROR_INCOMPLETE_FRAME              = New("Connection closed before the frame was completed")
+	ERROR_MESSAGE_TOO_LARGE             = New("Message exceeds the maximum allowed size")
+	ERROR_INVALID_HL7_MESSAGE           = New("Message does not start with a valid MSH segment")
+	ERROR_INVALID_HL7_PATH              = New("HL7 path is not in SEG(rep)-field(rep).component.subcomponent format")
+	ERROR_INVALID_ASTM_MESSAGE          = New("Message does not start with a valid ASTM header record")
+	ERROR_INVALID_ASTM_FRAME            = New("ASTM frame is malformed or its checksum does not match")
+	ERROR_ASTM_TIMEOUT                  = New("ASTM peer did not answer in time")
+	ERROR_ASTM_LINK_BUSY                = New("ASTM peer kept the line busy")
+	ERROR_ASTM_RETRANSMISSION           = New("ASTM frame was rejected too many times")
+	ERROR_PROCESSING_ID_REJECTED        = New("Message processing ID is not accepted in this environment")
+	ERROR_UNSUPPORTED_MESSAGE           = New("No parser is registered for the message protocol and device type")
+	ERROR_DUPLICATED_PARSER             = New("More than one parser is registered for the same protocol and device type")
+	ERROR_INVALID_TEXT_DEFINITION       = New("Text parser definition is invalid")
+	ERROR_INVALID_TEXT_MESSAGE          = New("Message does not match its text parser definition")
+	ERROR_PARSE_DIAGNOSTICS             = New("Message has parse diagnostics and its device is parsed in strict mode")
+	ERROR_INVALID_SERIAL_CONFIG         = New("Serial device or boundary configuration is invalid")
+	ERROR_SERIAL_UNSUPPORTED            = New("Serial ports are not supported on this platform")
+	ERROR_UNIT_CONVERSION               = New("Unit cannot be converted to the requested unit")
+	ERROR_INVALID_UNIT_CONFIG           = New("Unit normalization configuration is invalid")
+	ERROR_INVALID_CLOCK_CONFIG          = New("Device timezone configuration is invalid")
+	ERROR_UNSUPPORTED_CHARSET           = New("Character set is not supported")
+	ERROR_INVALID_CHARSET_CONFIG        = New("Device character set configuration is invalid")
+	ERROR_DELIVERY_FAILED               = New("Destination did not accept the delivered result")
+	ERROR_WORKER_TASK_PANIC             = New("Worker task panicked")
+	ERROR_DEAD_LETTER_CLOSED            = New("Dead letter was already replayed or discarded")
+	ERROR_INVALID_SIGNATURE             = New("Request signature is invalid")
+	ERROR_INVALID_SIGNING_CONFIG        = New("LIS platform signing configuration is invalid")
+	ERROR_INVALID_ROUTING_CONFIG        = New("Result routing configuration is invalid")
+	ERROR_UNKNOWN_DESTINATION           = New("Result destination is not configured")
 
 	ERROR_MISSING_CLIENT_ID      = New("Missing Client ID in the request")
 	ERROR_MISSING_USER_ID        = New("Missing User ID in the request")
@@ -38,6 +68,10 @@ var (
 	UNPROCESSABLE_ENTITY = []error{
 		ERROR_UNPROCESSABLE_ENTITY,
 		ERROR_DUPLICATED_KEY,
+		ERROR_PROCESSING_ID_REJECTED,
+		ERROR_UNSUPPORTED_MESSAGE,
+		ERROR_PARSE_DIAGNOSTICS,
+		ERROR_DEAD_LETTER_CLOSED,
 	}
 
 	INTERNAL_SERVER = []error{
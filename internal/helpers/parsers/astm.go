@@ -0,0 +1,350 @@
+package parsers
+
+import (
+	"strings"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// ASTM E1394 record types
+const (
+	ASTM_HEADER     = "H"
+	ASTM_PATIENT    = "P"
+	ASTM_ORDER      = "O"
+	ASTM_RESULT     = "R"
+	ASTM_COMMENT    = "C"
+	ASTM_QUERY      = "Q"
+	ASTM_TERMINATOR = "L"
+	// manufacturer and scientific records carry vendor specific data
+	ASTM_MANUFACTURER = "M"
+	ASTM_SCIENTIFIC   = "S"
+)
+
+// ASTMResult types returned by the parser. Records are nested the way the
+// standard defines the message hierarchy: patients hold orders, orders hold
+// results, and comments belong to the record they follow.
+type ASTMResult struct {
+	Header   ASTMHeader    `json:"header"`
+	Patients []ASTMPatient `json:"patients"`
+	Queries  []ASTMQuery   `json:"queries,omitempty"`
+	// TerminationCode is L-3, N for normal termination
+	TerminationCode string `json:"termination_code,omitempty"`
+	// Diagnostics lists the records and values that could not be read
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+type ASTMHeader struct {
+	Delimiters   string   `json:"delimiters"`
+	ControlID    string   `json:"control_id,omitempty"`
+	Sender       string   `json:"sender,omitempty"`
+	Receiver     string   `json:"receiver,omitempty"`
+	ProcessingID string   `json:"processing_id,omitempty"`
+	Version      string   `json:"version,omitempty"`
+	DateTime     string   `json:"date_time,omitempty"`
+	Comments     []string `json:"comments,omitempty"`
+}
+
+type ASTMPatient struct {
+	SequenceNumber string      `json:"sequence_number,omitempty"`
+	PracticeID     string      `json:"practice_id,omitempty"`
+	LaboratoryID   string      `json:"laboratory_id,omitempty"`
+	PatientID3     string      `json:"patient_id_3,omitempty"`
+	Name           Name        `json:"name"`
+	DOB            string      `json:"dob,omitempty"`
+	Sex            string      `json:"sex,omitempty"`
+	Comments       []string    `json:"comments,omitempty"`
+	Orders         []ASTMOrder `json:"orders"`
+}
+
+type ASTMOrder struct {
+	SequenceNumber       string            `json:"sequence_number,omitempty"`
+	SpecimenID           string            `json:"specimen_id,omitempty"`
+	InstrumentSpecimenID string            `json:"instrument_specimen_id,omitempty"`
+	TestCodes            []string          `json:"test_codes,omitempty"`
+	Priority             string            `json:"priority,omitempty"`
+	CollectionDatetime   string            `json:"collection_datetime,omitempty"`
+	ActionCode           string            `json:"action_code,omitempty"`
+	SpecimenType         string            `json:"specimen_type,omitempty"`
+	ReportType           string            `json:"report_type,omitempty"`
+	Comments             []string          `json:"comments,omitempty"`
+	Results              []ASTMObservation `json:"results"`
+}
+
+type ASTMObservation struct {
+	SequenceNumber    string   `json:"sequence_number,omitempty"`
+	Code              string   `json:"code,omitempty"`
+	Name              string   `json:"name,omitempty"`
+	Value             string   `json:"value,omitempty"`
+	Comparator        string   `json:"comparator,omitempty"`
+	NumericValue      *float64 `json:"numeric_value,omitempty"`
+	Units             string   `json:"units,omitempty"`
+	ReferenceRange    string   `json:"reference_range,omitempty"`
+	AbnormalFlags     string   `json:"abnormal_flags,omitempty"`
+	Status            string   `json:"status,omitempty"`
+	CompletedDatetime string   `json:"completed_datetime,omitempty"`
+	Instrument        string   `json:"instrument,omitempty"`
+	Comments          []string `json:"comments,omitempty"`
+}
+
+type ASTMQuery struct {
+	SequenceNumber string   `json:"sequence_number,omitempty"`
+	PatientID      string   `json:"patient_id,omitempty"`
+	SpecimenID     string   `json:"specimen_id,omitempty"`
+	TestCodes      []string `json:"test_codes,omitempty"`
+	StatusCode     string   `json:"status_code,omitempty"`
+}
+
+// ParseASTMHeader reads the H record of a message.
+func ParseASTMHeader(msg string) (ASTMHeader, error) {
+	lines := SplitSegments(msg)
+	if len(lines) == 0 {
+		return ASTMHeader{}, errors.ERROR_INVALID_ASTM_MESSAGE
+	}
+	message, err := ParseASTM(lines[0])
+	if err != nil {
+		return ASTMHeader{}, err
+	}
+
+	return parseASTMHeader(message.Records[0], message.Delimiters), nil
+}
+
+// ParseASTMMessage parses an E1394 message into patients, orders, results
+// and queries. It is a fixed mapping over the generic ASTMMessage returned
+// by ParseASTM; records out of place (e.g. R before any O) are ignored.
+func ParseASTMMessage(msg string) (ASTMResult, error) {
+	var res ASTMResult
+
+	message, err := ParseASTM(msg)
+	if err != nil {
+		return res, err
+	}
+	d := message.Delimiters
+	res.Patients = []ASTMPatient{}
+
+	// comments attach to the latest record that accepts them
+	var comments *[]string
+	hasResults := false
+	for i, record := range message.Records {
+		var patient *ASTMPatient
+		if len(res.Patients) > 0 {
+			patient = &res.Patients[len(res.Patients)-1]
+		}
+		var order *ASTMOrder
+		if patient != nil && len(patient.Orders) > 0 {
+			order = &patient.Orders[len(patient.Orders)-1]
+		}
+
+		switch record.Type {
+		case ASTM_HEADER:
+			res.Header = parseASTMHeader(record, d)
+			comments = &res.Header.Comments
+
+		case ASTM_PATIENT:
+			res.Patients = append(res.Patients, ASTMPatient{
+				SequenceNumber: record.Get(2, 0, 1),
+				// P-3 Practice Assigned and P-4 Laboratory Assigned Patient ID
+				PracticeID:   record.Get(3, 0, 1),
+				LaboratoryID: record.Get(4, 0, 1),
+				// P-5 Patient ID No. 3, where some analyzers put the MRN
+				PatientID3: record.Get(5, 0, 1),
+				// P-6 Patient Name, last^first^middle^suffix
+				Name: Name{
+					Family: record.Get(6, 0, 1),
+					Given:  record.Get(6, 0, 2),
+					Middle: record.Get(6, 0, 3),
+					Suffix: record.Get(6, 0, 4),
+				},
+				// P-8 Birthdate and P-9 Sex
+				DOB:    parseHL7Timestamp(record.Get(8, 0, 1)),
+				Sex:    record.Get(9, 0, 1),
+				Orders: []ASTMOrder{},
+			})
+			comments = &res.Patients[len(res.Patients)-1].Comments
+
+		case ASTM_ORDER:
+			// orders without a patient record still need a parent
+			if patient == nil {
+				res.Patients = append(res.Patients, ASTMPatient{Orders: []ASTMOrder{}})
+				patient = &res.Patients[len(res.Patients)-1]
+			}
+
+			testCodes := []string{}
+			for rep := range record.Field(5) {
+				if code, _ := astmTestID(record, 5, rep); code != "" {
+					testCodes = append(testCodes, code)
+				}
+			}
+			if record.Get(3, 0, 1) == "" && record.Get(4, 0, 1) == "" {
+				res.Diagnostics = append(res.Diagnostics, Diagnostic{
+					Kind:     DIAGNOSTIC_MISSING_FIELD,
+					Location: SegmentLocation(i+1, record.Type),
+					Field:    FIELD_SPECIMEN_ID,
+				})
+			}
+
+			patient.Orders = append(patient.Orders, ASTMOrder{
+				SequenceNumber: record.Get(2, 0, 1),
+				// O-3 Specimen ID and O-4 Instrument Specimen ID
+				SpecimenID:           record.Get(3, 0, 1),
+				InstrumentSpecimenID: record.Get(4, 0, 1),
+				TestCodes:            testCodes,
+				Priority:             record.Get(6, 0, 1),
+				// O-8 Specimen Collection Date/Time, fallback O-7 requested
+				CollectionDatetime: firstNonEmpty(
+					parseHL7Timestamp(record.Get(8, 0, 1)),
+					parseHL7Timestamp(record.Get(7, 0, 1)),
+				),
+				ActionCode:   record.Get(12, 0, 1),
+				SpecimenType: record.Get(16, 0, 1),
+				ReportType:   record.Get(26, 0, 1),
+				Results:      []ASTMObservation{},
+			})
+			comments = &patient.Orders[len(patient.Orders)-1].Comments
+
+		case ASTM_RESULT:
+			if order == nil {
+				// a result without an order cannot be attributed
+				res.Diagnostics = append(res.Diagnostics, Diagnostic{
+					Kind:     DIAGNOSTIC_UNRECOGNIZED,
+					Location: SegmentLocation(i+1, record.Type),
+				})
+				comments = nil
+				continue
+			}
+			hasResults = true
+
+			flags := []string{}
+			for rep := range record.Field(7) {
+				if flag := record.Get(7, rep, 1); flag != "" {
+					flags = append(flags, flag)
+				}
+			}
+
+			code, name := astmTestID(record, 3, 0)
+			observation := ASTMObservation{
+				SequenceNumber: record.Get(2, 0, 1),
+				Code:           code,
+				Name:           name,
+				// R-4 Data or Measurement Value
+				Value:          record.Field(4).Text(d),
+				Units:          record.Get(5, 0, 1),
+				ReferenceRange: record.Field(6).Text(d),
+				AbnormalFlags:  strings.Join(flags, ","),
+				Status:         record.Get(9, 0, 1),
+				// R-13 Date/Time Test Completed, fallback R-12 started
+				CompletedDatetime: firstNonEmpty(
+					parseHL7Timestamp(record.Get(13, 0, 1)),
+					parseHL7Timestamp(record.Get(12, 0, 1)),
+				),
+				Instrument: record.Get(14, 0, 1),
+			}
+			observation.Comparator, observation.NumericValue = parseComparatorNumber(observation.Value)
+			order.Results = append(order.Results, observation)
+			comments = &order.Results[len(order.Results)-1].Comments
+
+		case ASTM_COMMENT:
+			// C-4 Comment Text
+			if text := record.Field(4).Text(d); text != "" && comments != nil {
+				*comments = append(*comments, text)
+			}
+
+		case ASTM_QUERY:
+			testCodes := []string{}
+			for rep := range record.Field(5) {
+				if code, _ := astmTestID(record, 5, rep); code != "" && code != "ALL" {
+					testCodes = append(testCodes, code)
+				}
+			}
+
+			res.Queries = append(res.Queries, ASTMQuery{
+				SequenceNumber: record.Get(2, 0, 1),
+				// Q-3 Starting Range ID, patient^specimen
+				PatientID:  record.Get(3, 0, 1),
+				SpecimenID: record.Get(3, 0, 2),
+				TestCodes:  testCodes,
+				StatusCode: record.Get(13, 0, 1),
+			})
+			comments = nil
+
+		case ASTM_TERMINATOR:
+			res.TerminationCode = record.Get(3, 0, 1)
+			comments = nil
+
+		case ASTM_MANUFACTURER, ASTM_SCIENTIFIC:
+			// manufacturer and scientific records are not mapped
+			comments = nil
+
+		default:
+			res.Diagnostics = append(res.Diagnostics, Diagnostic{
+				Kind:     DIAGNOSTIC_UNRECOGNIZED,
+				Location: SegmentLocation(i+1, record.Type),
+			})
+			comments = nil
+		}
+	}
+
+	if hasResults && res.Header.DateTime == "" {
+		res.Diagnostics = append(res.Diagnostics, Diagnostic{Kind: DIAGNOSTIC_MISSING_FIELD, Field: FIELD_TIMESTAMP})
+	}
+
+	return res, nil
+}
+
+func parseASTMHeader(record ASTMRecord, d ASTMDelimiters) ASTMHeader {
+	return ASTMHeader{
+		Delimiters: d.Definition(),
+		ControlID:  record.Get(3, 0, 1),
+		// H-5 Sender Name or ID, name^version^serial number
+		Sender:       record.Field(5).Text(d),
+		Receiver:     record.Field(10).Text(d),
+		ProcessingID: record.Get(12, 0, 1),
+		Version:      record.Get(13, 0, 1),
+		DateTime:     parseHL7Timestamp(record.Get(14, 0, 1)),
+	}
+}
+
+// astmTestID reads a Universal Test ID (universal ID^name^type^manufacturer
+// code). Instruments almost always leave the universal ID empty and send
+// their own code in the fourth component, followed by either a name or a
+// numeric dilution, e.g. "^^^GLU^Glucose" or "^^^8714^1".
+func astmTestID(record ASTMRecord, field, repeat int) (code, name string) {
+	code = record.Get(field, repeat, 4)
+	name = record.Get(field, repeat, 2)
+	if code == "" {
+		return record.Get(field, repeat, 1), name
+	}
+	if extra := record.Get(field, repeat, 5); name == "" && parseNumber(extra) == nil {
+		name = extra
+	}
+
+	return
+}
+
+// parseComparatorNumber splits values such as "<0.5" or ">=100" into their
+// comparator and number. Non numeric values return a nil number.
+func parseComparatorNumber(value string) (comparator string, number *float64) {
+	value = strings.TrimSpace(value)
+	for _, candidate := range []string{">=", "<=", "<>", ">", "<", "="} {
+		if strings.HasPrefix(value, candidate) {
+			comparator = candidate
+			break
+		}
+	}
+
+	number = parseNumber(strings.TrimPrefix(value, comparator))
+	if number == nil {
+		return "", nil
+	}
+
+	return comparator, number
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,205 @@
+package parsers
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+type (
+	// ASTMDelimiters are declared by the header record right after the "H":
+	// field, repeat, component and escape, e.g. "H|\^&".
+	ASTMDelimiters struct {
+		Field     byte
+		Repeat    byte
+		Component byte
+		Escape    byte
+	}
+
+	// ASTMMessage is the generic representation of an E1394 message.
+	ASTMMessage struct {
+		Delimiters ASTMDelimiters `json:"-"`
+		Records    []ASTMRecord   `json:"records"`
+	}
+
+	// ASTMRecord fields are numbered like the standard, Fields[0] holds field
+	// 1 which is the record type itself (for H, field 2 is the delimiter
+	// definition and is never split).
+	ASTMRecord struct {
+		Type   string      `json:"type"`
+		Fields []ASTMField `json:"fields"`
+	}
+
+	// ASTMField is a list of repeats, each a list of decoded components.
+	ASTMField  []ASTMRepeat
+	ASTMRepeat []string
+)
+
+func DefaultASTMDelimiters() ASTMDelimiters {
+	return ASTMDelimiters{
+		Field:     '|',
+		Repeat:    '\\',
+		Component: '^',
+		Escape:    '&',
+	}
+}
+
+// ParseASTMDelimiters reads the delimiters from a header record.
+func ParseASTMDelimiters(header string) (ASTMDelimiters, error) {
+	if len(header) < 5 || header[0] != 'H' {
+		return DefaultASTMDelimiters(), errors.ERROR_INVALID_ASTM_MESSAGE
+	}
+
+	return ASTMDelimiters{
+		Field:     header[1],
+		Repeat:    header[2],
+		Component: header[3],
+		Escape:    header[4],
+	}, nil
+}
+
+// Definition returns the H-2 representation of the delimiters.
+func (d ASTMDelimiters) Definition() string {
+	return string([]byte{d.Repeat, d.Component, d.Escape})
+}
+
+// Decode resolves ASTM escape sequences: &F& &S& &R& &E& for the delimiters
+// and &Xhhhh& for raw bytes. Highlighting and other sequences are dropped.
+func (d ASTMDelimiters) Decode(value string) string {
+	if strings.IndexByte(value, d.Escape) == -1 {
+		return value
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != d.Escape {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		end := strings.IndexByte(value[i+1:], d.Escape)
+		if end == -1 {
+			// dangling escape character, keep as literal
+			sb.WriteString(value[i:])
+			break
+		}
+		sequence := value[i+1 : i+1+end]
+		i += end + 1
+
+		switch {
+		case sequence == "F":
+			sb.WriteByte(d.Field)
+		case sequence == "S":
+			sb.WriteByte(d.Component)
+		case sequence == "R":
+			sb.WriteByte(d.Repeat)
+		case sequence == "E":
+			sb.WriteByte(d.Escape)
+		case strings.HasPrefix(sequence, "X"):
+			if decoded, err := hex.DecodeString(sequence[1:]); err == nil {
+				sb.Write(decoded)
+			}
+		default:
+			// &H& &N& highlighting and manufacturer sequences carry no data
+		}
+	}
+
+	return sb.String()
+}
+
+// ParseASTM splits a message into records, fields, repeats and components,
+// decoding escape sequences at the leaves. Records are terminated by CR, LF
+// from gateways that rewrite line endings is accepted as well.
+func ParseASTM(msg string) (*ASTMMessage, error) {
+	lines := SplitSegments(msg)
+	if len(lines) == 0 {
+		return nil, errors.ERROR_INVALID_ASTM_MESSAGE
+	}
+	delimiters, err := ParseASTMDelimiters(lines[0])
+	if err != nil {
+		return nil, err
+	}
+
+	message := &ASTMMessage{Delimiters: delimiters}
+	for _, line := range lines {
+		rawFields := strings.Split(line, string(delimiters.Field))
+		record := ASTMRecord{Type: strings.ToUpper(rawFields[0])}
+		if record.Type == "" {
+			continue
+		}
+
+		for i, rawField := range rawFields {
+			// H-2 declares the delimiters and is never split
+			if record.Type == "H" && i == 1 {
+				record.Fields = append(record.Fields, ASTMField{{rawField}})
+				continue
+			}
+			record.Fields = append(record.Fields, parseASTMField(rawField, delimiters))
+		}
+		message.Records = append(message.Records, record)
+	}
+
+	return message, nil
+}
+
+func parseASTMField(raw string, delimiters ASTMDelimiters) ASTMField {
+	field := ASTMField{}
+	for _, rawRepeat := range strings.Split(raw, string(delimiters.Repeat)) {
+		repeat := ASTMRepeat{}
+		for _, rawComp := range strings.Split(rawRepeat, string(delimiters.Component)) {
+			repeat = append(repeat, delimiters.Decode(rawComp))
+		}
+		field = append(field, repeat)
+	}
+
+	return field
+}
+
+// RecordsByType returns all records of the given type in message order.
+func (m *ASTMMessage) RecordsByType(recordType string) []ASTMRecord {
+	res := []ASTMRecord{}
+	for _, record := range m.Records {
+		if record.Type == recordType {
+			res = append(res, record)
+		}
+	}
+
+	return res
+}
+
+// Get returns a single decoded component. Field and component are 1-based,
+// repeat is 0-based.
+func (r ASTMRecord) Get(field, repeat, component int) string {
+	f := r.Field(field)
+	if repeat < 0 || repeat >= len(f) {
+		return ""
+	}
+	rep := f[repeat]
+	if component < 1 || component > len(rep) {
+		return ""
+	}
+
+	return strings.TrimSpace(rep[component-1])
+}
+
+// Field returns the 1-based field, or nil when absent.
+func (r ASTMRecord) Field(n int) ASTMField {
+	if n < 1 || n > len(r.Fields) {
+		return nil
+	}
+
+	return r.Fields[n-1]
+}
+
+// Text joins a field back into a readable value: components with their
+// delimiter, repeats with line breaks.
+func (f ASTMField) Text(delimiters ASTMDelimiters) string {
+	repeats := make([]string, 0, len(f))
+	for _, rep := range f {
+		repeats = append(repeats, strings.TrimRight(strings.Join(rep, string(delimiters.Component)), string(delimiters.Component)))
+	}
+
+	return strings.TrimSpace(strings.Join(repeats, "\n"))
+}
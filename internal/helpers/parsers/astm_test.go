@@ -0,0 +1,97 @@
+package parsers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseASTMMessage_Corpus parses every message in testdata/astm and
+// compares the result with its golden json. Run with -update to regenerate.
+func TestParseASTMMessage_Corpus(t *testing.T) {
+	files, err := filepath.Glob("testdata/astm/*.astm")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			msg, err := os.ReadFile(file)
+			assert.NoError(t, err)
+
+			res, err := ParseASTMMessage(string(msg))
+			assert.NoError(t, err)
+
+			actual, err := json.MarshalIndent(res, "", "  ")
+			assert.NoError(t, err)
+
+			golden := strings.TrimSuffix(file, ".astm") + ".json"
+			if *update {
+				assert.NoError(t, os.WriteFile(golden, append(actual, '\n'), 0o644))
+			}
+			expected, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestParseASTMMessage_Delimiters(t *testing.T) {
+	msg, err := os.ReadFile("testdata/astm/olympus_au_multi_patient.astm")
+	assert.NoError(t, err)
+
+	res, err := ParseASTMMessage(string(msg))
+	assert.NoError(t, err)
+
+	// repeat delimiter is a backtick, escaped field delimiter in the value
+	assert.Equal(t, "`^&", res.Header.Delimiters)
+	assert.Equal(t, []string{"Daily batch"}, res.Header.Comments)
+	assert.Len(t, res.Patients, 2)
+	assert.Equal(t, "3.|8", res.Patients[0].Orders[0].Results[1].Value)
+	assert.Nil(t, res.Patients[0].Orders[0].Results[1].NumericValue)
+	assert.Equal(t, "URN-3302", res.Patients[1].Orders[0].SpecimenID)
+}
+
+func TestParseASTMHeader(t *testing.T) {
+	header, err := ParseASTMHeader("H|\\^&|CTRL-1||CA-660^00-12|||||LIS||T|1394-97|20250801093012\rP|1")
+	assert.NoError(t, err)
+	assert.Equal(t, ASTMHeader{
+		Delimiters:   "\\^&",
+		ControlID:    "CTRL-1",
+		Sender:       "CA-660^00-12",
+		Receiver:     "LIS",
+		ProcessingID: "T",
+		Version:      "1394-97",
+		DateTime:     "2025-08-01T09:30:12",
+	}, header)
+}
+
+func TestParseASTMMessage_Invalid(t *testing.T) {
+	for _, msg := range []string{"", "\r\n", "P|1||123", "H|"} {
+		_, err := ParseASTMMessage(msg)
+		assert.True(t, errors.Is(err, errors.ERROR_INVALID_ASTM_MESSAGE), msg)
+	}
+}
+
+func TestParseASTMMessage_Diagnostics(t *testing.T) {
+	msg := "H|\\^&|||CA-660\r" +
+		"R|1|^^^GLU|5.4\r" +
+		"P|1\r" +
+		"O|1|||^^^GLU\r" +
+		"R|1|^^^GLU|5.4\r" +
+		"X|1\r" +
+		"L|1|N\r"
+
+	res, err := ParseASTMMessage(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, []Diagnostic{
+		{Kind: DIAGNOSTIC_UNRECOGNIZED, Location: "segment 2 R"},
+		{Kind: DIAGNOSTIC_MISSING_FIELD, Location: "segment 4 O", Field: FIELD_SPECIMEN_ID},
+		{Kind: DIAGNOSTIC_UNRECOGNIZED, Location: "segment 6 X"},
+		{Kind: DIAGNOSTIC_MISSING_FIELD, Field: FIELD_TIMESTAMP},
+	}, res.Diagnostics)
+}
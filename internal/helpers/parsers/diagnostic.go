@@ -0,0 +1,42 @@
+package parsers
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Diagnostic kinds
+const (
+	DIAGNOSTIC_UNRECOGNIZED   = "unrecognized"
+	DIAGNOSTIC_INVALID_NUMBER = "invalid_number"
+	DIAGNOSTIC_INVALID_VALUE  = "invalid_value"
+	DIAGNOSTIC_MISSING_FIELD  = "missing_field"
+)
+
+// Required fields reported as missing
+const (
+	FIELD_SPECIMEN_ID = "specimen_id"
+	FIELD_TIMESTAMP   = "timestamp"
+)
+
+// Diagnostic points at input the parser skipped or could not convert, or
+// names a required field it did not find. Parsing goes on either way.
+type Diagnostic struct {
+	Kind string `json:"kind"`
+	// Location is the line, segment or record, e.g. "line 4" or "segment 3 OBX"
+	Location string `json:"location,omitempty"`
+	Field    string `json:"field,omitempty"`
+	Value    string `json:"value,omitempty"`
+}
+
+var segmentNamePattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{2}$`)
+
+// LineLocation names a 1-based line of a text message.
+func LineLocation(line int) string {
+	return fmt.Sprintf("line %d", line)
+}
+
+// SegmentLocation names a 1-based segment or record of a message.
+func SegmentLocation(index int, name string) string {
+	return fmt.Sprintf("segment %d %s", index, name)
+}
@@ -1,16 +1,23 @@
 package parsers
 
 import (
-	"fmt"
 	"strings"
 	"time"
 )
 
 // Result types returned by the parser
 type Result struct {
-	Patient              Patient `json:"patient"`
+	Header  MessageHeader `json:"header"`
+	Patient Patient       `json:"patient"`
+	// Tests lists every observation of the message in order, Orders groups
+	// the same observations under the OBR they belong to
 	Tests                []Test  `json:"tests"`
+	Orders               []Order `json:"orders,omitempty"`
 	PrimaryOrderDatetime string  `json:"primary_order_datetime,omitempty"`
+	// Extras holds message level values requested through WithFieldMappings
+	Extras map[string]string `json:"extras,omitempty"`
+	// Diagnostics lists the segments and values that could not be read
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
 }
 
 type Patient struct {
@@ -22,8 +29,9 @@ type Patient struct {
 }
 
 type Identifier struct {
-	ID             string `json:"id"`
-	IdentifierType string `json:"identifier_type,omitempty"`
+	ID                 string `json:"id"`
+	IdentifierType     string `json:"identifier_type,omitempty"`
+	AssigningAuthority string `json:"assigning_authority,omitempty"`
 }
 
 type Name struct {
@@ -42,20 +50,47 @@ type Address struct {
 	Country string `json:"country,omitempty"`
 }
 
+type Order struct {
+	SetID               string   `json:"set_id,omitempty"`
+	PlacerOrderNumber   string   `json:"placer_order_number,omitempty"`
+	FillerOrderNumber   string   `json:"filler_order_number,omitempty"`
+	ServiceCode         string   `json:"service_code,omitempty"`
+	ServiceName         string   `json:"service_name,omitempty"`
+	ServiceCodingSystem string   `json:"service_coding_system,omitempty"`
+	ObservationDatetime string   `json:"observation_datetime,omitempty"`
+	Comments            []string `json:"comments,omitempty"`
+	Tests               []Test   `json:"tests"`
+}
+
 type Test struct {
-	Code                string `json:"code,omitempty"`
-	Name                string `json:"name,omitempty"`
-	CodingSystem        string `json:"coding_system,omitempty"`
-	Value               string `json:"value,omitempty"`
-	Units               string `json:"units,omitempty"`
-	ReferenceRange      string `json:"reference_range,omitempty"`
-	AbnormalFlags       string `json:"abnormal_flags,omitempty"`
-	ObservationDatetime string `json:"observation_datetime,omitempty"`
+	Code         string `json:"code,omitempty"`
+	Name         string `json:"name,omitempty"`
+	CodingSystem string `json:"coding_system,omitempty"`
+	SubID        string `json:"sub_id,omitempty"`
+	ValueType    string `json:"value_type,omitempty"`
+	Value        string `json:"value,omitempty"`
+	// Comparator and NumericValue are filled for NM and SN values
+	Comparator   string   `json:"comparator,omitempty"`
+	NumericValue *float64 `json:"numeric_value,omitempty"`
+	// ValueCode and ValueText are filled for coded CE, CWE and CNE values
+	ValueCode           string   `json:"value_code,omitempty"`
+	ValueText           string   `json:"value_text,omitempty"`
+	Units               string   `json:"units,omitempty"`
+	ReferenceRange      string   `json:"reference_range,omitempty"`
+	AbnormalFlags       string   `json:"abnormal_flags,omitempty"`
+	ObservationDatetime string   `json:"observation_datetime,omitempty"`
+	Comments            []string `json:"comments,omitempty"`
+	// Extras holds observation level values requested through WithFieldMappings
+	Extras map[string]string `json:"extras,omitempty"`
 }
 
+// LOCAL_DATETIME is the ISO 8601 layout of timestamps printed without an
+// offset, the device timezone decides when they happened.
+const LOCAL_DATETIME = "2006-01-02T15:04:05"
+
 // parseHL7Timestamp converts HL7 TS (e.g., 20250101120000 or 20250101 or 202501011200+0500)
-// into ISO 8601 string (RFC3339 for timestamps or YYYY-MM-DD for date-only).
-// Returns empty string if input empty or unparseable.
+// into ISO 8601 string (RFC3339 with an offset, LOCAL_DATETIME without one or
+// YYYY-MM-DD for date-only). Returns empty string if input empty or unparseable.
 func parseHL7Timestamp(ts string) string {
 	if ts == "" {
 		return ""
@@ -116,239 +151,245 @@ func parseHL7Timestamp(ts string) string {
 	if len(tsMain) == 8 {
 		return parsed.Format("2006-01-02")
 	}
-	// Make it RFC3339 (naive, local set to UTC for consistent output)
-	return parsed.Format(time.RFC3339)
+	// naive, the offset is left to the device timezone
+	return parsed.Format(LOCAL_DATETIME)
 }
 
-// parseHL7Message parses a single HL7 message (string) and extracts patient, tests, and primary order datetime.
-// It reads delimiters from MSH and supports component and repetition separators.
-func ParseHL7Message(msg string) (Result, error) {
-	var res Result
+type (
+	HL7Option func(o *hl7Options)
 
-	// Normalize line endings and split segments
-	lines := []string{}
-	for _, ln := range strings.Split(msg, "\n") {
-		ln = strings.TrimSpace(ln)
-		if ln != "" {
-			lines = append(lines, ln)
-		}
-	}
-	if len(lines) == 0 || !strings.HasPrefix(lines[0], "MSH") {
-		return res, fmt.Errorf("message does not start with MSH")
+	hl7Options struct {
+		fieldMappings map[string]string
 	}
+)
 
-	msh := lines[0]
-	// field separator is the 4th byte in MSH (index 3)
-	if len(msh) < 4 {
-		return res, fmt.Errorf("invalid MSH segment")
+// WithFieldMappings extracts additional named values by path. OBX paths are
+// resolved against every observation and land in Test.Extras, any other
+// segment is resolved once for the message and lands in Result.Extras.
+func WithFieldMappings(mappings map[string]string) HL7Option {
+	return func(o *hl7Options) {
+		o.fieldMappings = mappings
 	}
-	fieldSep := string(msh[3])
+}
 
-	fields := strings.Split(msh, fieldSep)
-	encChars := "^~\\&"
-	if len(fields) > 1 && fields[1] != "" {
-		encChars = fields[1]
-	}
-	// encoding chars: component, repetition, escape, subcomponent
-	compSep := "^"
-	repSep := "~"
-	subCompSep := "&"
-	if len(encChars) > 0 {
-		compSep = string(encChars[0])
-	}
-	if len(encChars) > 1 {
-		repSep = string(encChars[1])
-	}
-	if len(encChars) > 3 {
-		subCompSep = string(encChars[3])
+// ParseHL7Message parses a single HL7 message (string) and extracts patient, tests, and primary order datetime.
+// It is a fixed mapping over the generic HL7Message tree returned by ParseHL7.
+func ParseHL7Message(msg string, options ...HL7Option) (Result, error) {
+	var res Result
+
+	opts := &hl7Options{}
+	for _, fn := range options {
+		fn(opts)
 	}
 
-	// helper to split by fieldSep
-	splitFields := func(segment string) []string {
-		return strings.Split(segment, fieldSep)
+	message, err := ParseHL7(msg)
+	if err != nil {
+		return res, err
 	}
-	// helper to split components
-	splitComp := func(field string) []string {
-		if field == "" {
-			return []string{}
+
+	// split mappings between message level and observation level
+	messageFields := map[string]string{}
+	observationFields := map[string]Path{}
+	for name, path := range opts.fieldMappings {
+		p, err := ParsePath(path)
+		if err != nil {
+			continue
+		}
+		if p.Segment == "OBX" {
+			observationFields[name] = p
+			continue
 		}
-		return strings.Split(field, compSep)
+		messageFields[name] = path
 	}
 
 	var primaryOrderDatetime string
 	var patient Patient
 	var tests []Test
+	var orders []Order
+	// testOrders holds the order index of every test, -1 before the first OBR
+	var testOrders []int
+	// NTE segments comment on the preceding OBX or OBR
+	var lastSegment string
+	var diagnostics []Diagnostic
 
-	for _, seg := range lines {
-		if len(seg) < 3 {
+	for i, seg := range message.Segments {
+		if !segmentNamePattern.MatchString(seg.Name) {
+			diagnostics = append(diagnostics, Diagnostic{
+				Kind:     DIAGNOSTIC_UNRECOGNIZED,
+				Location: SegmentLocation(i+1, seg.Name),
+			})
 			continue
 		}
-		tag := seg[:3]
-		switch tag {
+
+		switch seg.Name {
 		case "PID":
-			pidFields := splitFields(seg)
-			safe := func(i int) string {
-				if i < len(pidFields) {
-					return pidFields[i]
-				}
-				return ""
-			}
 			// PID-3 Patient Identifier List
-			pid3 := safe(3)
 			identifiers := []Identifier{}
-			if pid3 != "" {
-				for _, rep := range strings.Split(pid3, repSep) {
-					comps := splitComp(rep)
-					id := ""
-					idType := ""
-					if len(comps) > 0 {
-						id = comps[0]
-					}
-					if len(comps) > 4 {
-						idType = comps[4]
-					}
-					if id != "" {
-						identifiers = append(identifiers, Identifier{ID: id, IdentifierType: idType})
-					}
+			for rep := range seg.Field(3) {
+				id := seg.Get(3, rep, 1, 1)
+				if id == "" {
+					continue
 				}
-			}
-			// PID-5 Name
-			nameRaw := safe(5)
-			nc := splitComp(nameRaw)
-			name := Name{
-				Family: "",
-				Given:  "",
-				Middle: "",
-				Suffix: "",
-			}
-			if len(nc) > 0 {
-				name.Family = nc[0]
-			}
-			if len(nc) > 1 {
-				name.Given = nc[1]
-			}
-			if len(nc) > 2 {
-				name.Middle = nc[2]
-			}
-			if len(nc) > 3 {
-				name.Suffix = nc[3]
-			}
-			// PID-7 DOB
-			dobRaw := safe(7)
-			dob := parseHL7Timestamp(dobRaw)
-			// PID-8 Sex
-			sex := safe(8)
-			// PID-11 Address
-			addrRaw := safe(11)
-			ac := splitComp(addrRaw)
-			address := Address{}
-			if len(ac) > 0 {
-				address.Street = ac[0]
-			}
-			if len(ac) > 1 {
-				address.Other = ac[1]
-			}
-			if len(ac) > 2 {
-				address.City = ac[2]
-			}
-			if len(ac) > 3 {
-				address.State = ac[3]
-			}
-			if len(ac) > 4 {
-				address.Zip = ac[4]
-			}
-			if len(ac) > 5 {
-				address.Country = ac[5]
+				identifiers = append(identifiers, Identifier{
+					ID:                 id,
+					IdentifierType:     seg.Get(3, rep, 5, 1),
+					AssigningAuthority: seg.Get(3, rep, 4, 1),
+				})
 			}
 
 			patient = Patient{
 				Identifiers: identifiers,
-				Name:        name,
-				DOB:         dob,
-				Sex:         sex,
-				Address:     address,
+				// PID-5 Name, first repetition
+				Name: Name{
+					Family: seg.Get(5, 0, 1, 1),
+					Given:  seg.Get(5, 0, 2, 1),
+					Middle: seg.Get(5, 0, 3, 1),
+					Suffix: seg.Get(5, 0, 4, 1),
+				},
+				// PID-7 DOB
+				DOB: parseHL7Timestamp(seg.Get(7, 0, 1, 1)),
+				// PID-8 Sex
+				Sex: seg.Get(8, 0, 1, 1),
+				// PID-11 Address, first repetition
+				Address: Address{
+					Street:  seg.Get(11, 0, 1, 1),
+					Other:   seg.Get(11, 0, 2, 1),
+					City:    seg.Get(11, 0, 3, 1),
+					State:   seg.Get(11, 0, 4, 1),
+					Zip:     seg.Get(11, 0, 5, 1),
+					Country: seg.Get(11, 0, 6, 1),
+				},
 			}
 
 		case "OBR":
-			obrFields := splitFields(seg)
-			safe := func(i int) string {
-				if i < len(obrFields) {
-					return obrFields[i]
-				}
-				return ""
-			}
-			// OBR-7 Observation Date/Time (index 7)
-			if dt := safe(7); dt != "" {
-				if parsed := parseHL7Timestamp(dt); parsed != "" {
-					primaryOrderDatetime = parsed
-				}
+			// OBR-7 Observation Date/Time, fallback OBR-14 (specimen collected)
+			observationDatetime := parseHL7Timestamp(seg.Get(7, 0, 1, 1))
+			if observationDatetime == "" {
+				observationDatetime = parseHL7Timestamp(seg.Get(14, 0, 1, 1))
 			}
-			// fallback OBR-14 (specimen collected)
+			// the first order with a timestamp is the primary one
 			if primaryOrderDatetime == "" {
-				if dt := safe(14); dt != "" {
-					if parsed := parseHL7Timestamp(dt); parsed != "" {
-						primaryOrderDatetime = parsed
-					}
-				}
+				primaryOrderDatetime = observationDatetime
+			}
+
+			// OBR-4 Universal Service Identifier, analyzers that only know
+			// their local code send it in the alternate components 4-6
+			service := 1
+			if seg.Get(4, 0, 1, 1) == "" && seg.Get(4, 0, 4, 1) != "" {
+				service = 4
 			}
 
+			orders = append(orders, Order{
+				SetID: seg.Get(1, 0, 1, 1),
+				// OBR-2 Placer and OBR-3 Filler Order Number
+				PlacerOrderNumber:   seg.Get(2, 0, 1, 1),
+				FillerOrderNumber:   seg.Get(3, 0, 1, 1),
+				ServiceCode:         seg.Get(4, 0, service, 1),
+				ServiceName:         seg.Get(4, 0, service+1, 1),
+				ServiceCodingSystem: seg.Get(4, 0, service+2, 1),
+				ObservationDatetime: observationDatetime,
+				Tests:               []Test{},
+			})
+			lastSegment = seg.Name
+
 		case "OBX":
-			obxFields := splitFields(seg)
-			safe := func(i int) string {
-				if i < len(obxFields) {
-					return obxFields[i]
+			// OBX-8 abnormal flags may repeat
+			flags := []string{}
+			for rep := range seg.Field(8) {
+				if flag := seg.Get(8, rep, 1, 1); flag != "" {
+					flags = append(flags, flag)
 				}
-				return ""
 			}
-			obsIDRaw := safe(3)
-			obsIdComps := splitComp(obsIDRaw)
-			code := ""
-			name := ""
-			codingSystem := ""
-			if len(obsIdComps) > 0 {
-				code = obsIdComps[0]
+
+			test := Test{
+				// OBX-3 Observation Identifier
+				Code:         seg.Get(3, 0, 1, 1),
+				Name:         seg.Get(3, 0, 2, 1),
+				CodingSystem: seg.Get(3, 0, 3, 1),
+				SubID:        seg.Get(4, 0, 1, 1),
+				// OBX-2 Value Type
+				ValueType:           strings.ToUpper(seg.Get(2, 0, 1, 1)),
+				Units:               seg.Get(6, 0, 1, 1),
+				ReferenceRange:      seg.Field(7).Text(message.Delimiters),
+				AbnormalFlags:       strings.Join(flags, ","),
+				ObservationDatetime: parseHL7Timestamp(seg.Get(14, 0, 1, 1)),
 			}
-			if len(obsIdComps) > 1 {
-				name = obsIdComps[1]
+			setObservationValue(&test, seg.Field(5), message.Delimiters)
+			if test.ValueType == VALUE_TYPE_NUMERIC && test.Value != "" && test.NumericValue == nil {
+				diagnostics = append(diagnostics, Diagnostic{
+					Kind:     DIAGNOSTIC_INVALID_NUMBER,
+					Location: SegmentLocation(i+1, seg.Name),
+					Field:    "OBX-5",
+					Value:    test.Value,
+				})
 			}
-			if len(obsIdComps) > 2 {
-				codingSystem = obsIdComps[2]
+			for name, p := range observationFields {
+				if value := seg.Value(p); value != "" {
+					if test.Extras == nil {
+						test.Extras = map[string]string{}
+					}
+					test.Extras[name] = value
+				}
+			}
+			lastSegment = seg.Name
+
+			// text reports split over several OBX with the same identifier
+			// are continuation lines of one observation
+			if last := len(tests) - 1; last >= 0 && isContinuation(tests[last], test) {
+				tests[last].Value += "\n" + test.Value
+				continue
 			}
-			value := safe(5)
-			unitsRaw := safe(6)
-			unitsComps := splitComp(unitsRaw)
-			units := unitsRaw
-			if len(unitsComps) > 0 && unitsComps[0] != "" {
-				units = unitsComps[0]
+			tests = append(tests, test)
+			testOrders = append(testOrders, len(orders)-1)
+
+		case "NTE":
+			// NTE-3 Comment, repetitions are lines
+			comment := seg.Field(3).Text(message.Delimiters)
+			if comment == "" {
+				continue
 			}
-			refRange := safe(7)
-			flags := safe(8)
-			obsDtRaw := safe(14)
-			obsDt := ""
-			if obsDtRaw != "" {
-				obsDt = parseHL7Timestamp(obsDtRaw)
+			switch {
+			case lastSegment == "OBX" && len(tests) > 0:
+				tests[len(tests)-1].Comments = append(tests[len(tests)-1].Comments, comment)
+			case lastSegment == "OBR" && len(orders) > 0:
+				orders[len(orders)-1].Comments = append(orders[len(orders)-1].Comments, comment)
 			}
 
-			tests = append(tests, Test{
-				Code:                code,
-				Name:                name,
-				CodingSystem:        codingSystem,
-				Value:               value,
-				Units:               units,
-				ReferenceRange:      refRange,
-				AbnormalFlags:       flags,
-				ObservationDatetime: obsDt,
-			})
 		default:
 			// ignore other segments
-			_ = subCompSep // silence unused
 		}
 	}
 
+	// observations before the first OBR only appear in the flat list
+	for i, test := range tests {
+		if testOrders[i] >= 0 {
+			orders[testOrders[i]].Tests = append(orders[testOrders[i]].Tests, test)
+		}
+	}
+
+	// the patient or an order number identifies the specimen
+	hasSpecimen := len(patient.Identifiers) > 0
+	for _, order := range orders {
+		hasSpecimen = hasSpecimen || order.PlacerOrderNumber != "" || order.FillerOrderNumber != ""
+	}
+	if !hasSpecimen {
+		diagnostics = append(diagnostics, Diagnostic{Kind: DIAGNOSTIC_MISSING_FIELD, Field: FIELD_SPECIMEN_ID})
+	}
+	if primaryOrderDatetime == "" {
+		diagnostics = append(diagnostics, Diagnostic{Kind: DIAGNOSTIC_MISSING_FIELD, Field: FIELD_TIMESTAMP})
+	}
+
 	res = Result{
+		Header:               message.Header(),
 		Patient:              patient,
 		Tests:                tests,
+		Orders:               orders,
 		PrimaryOrderDatetime: primaryOrderDatetime,
+		Diagnostics:          diagnostics,
+	}
+	if extras := message.Extract(messageFields); len(extras) > 0 {
+		res.Extras = extras
 	}
+
 	return res, nil
 }
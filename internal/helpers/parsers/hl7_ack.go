@@ -0,0 +1,181 @@
+package parsers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/google/uuid"
+)
+
+type AckCode string
+
+const (
+	ACK_APPLICATION_ACCEPT AckCode = "AA"
+	ACK_APPLICATION_ERROR  AckCode = "AE"
+	ACK_APPLICATION_REJECT AckCode = "AR"
+)
+
+// HL7 table 0357 message error condition codes
+const (
+	ERR_SEGMENT_SEQUENCE          = "100"
+	ERR_DATA_TYPE                 = "102"
+	ERR_UNSUPPORTED_MESSAGE_TYPE  = "200"
+	ERR_UNSUPPORTED_PROCESSING_ID = "202"
+	ERR_APPLICATION_INTERNAL      = "207"
+)
+
+// ackConditions maps the errors a sender can act on to their condition and
+// a fixed text, anything else is an internal error. The error itself never
+// leaves the server, it may carry repository and parser details.
+var ackConditions = []struct {
+	err       error
+	condition string
+	text      string
+}{
+	{errors.ERROR_INVALID_HL7_MESSAGE, ERR_SEGMENT_SEQUENCE, "Segment sequence error"},
+	{errors.ERROR_PARSE_DIAGNOSTICS, ERR_DATA_TYPE, "Data type error"},
+	{errors.ERROR_UNSUPPORTED_MESSAGE, ERR_UNSUPPORTED_MESSAGE_TYPE, "Unsupported message type"},
+	{errors.ERROR_PROCESSING_ID_REJECTED, ERR_UNSUPPORTED_PROCESSING_ID, "Unsupported processing id"},
+}
+
+// HL7AckCondition returns the table 0357 condition and the text an ACK
+// reports for the cause.
+func HL7AckCondition(cause error) (condition, text string) {
+	for _, ackCondition := range ackConditions {
+		if errors.Is(cause, ackCondition.err) {
+			return ackCondition.condition, ackCondition.text
+		}
+	}
+
+	return ERR_APPLICATION_INTERNAL, "Application internal error"
+}
+
+const (
+	DEFAULT_FIELD_SEPARATOR     = "|"
+	DEFAULT_ENCODING_CHARACTERS = "^~\\&"
+	DEFAULT_HL7_VERSION         = "2.3"
+)
+
+// ParseHL7Header reads only the MSH segment of a message. The returned
+// header keeps the default delimiters when the message has no valid MSH.
+func ParseHL7Header(msg string) (MessageHeader, error) {
+	header := MessageHeader{
+		FieldSeparator:     DEFAULT_FIELD_SEPARATOR,
+		EncodingCharacters: DEFAULT_ENCODING_CHARACTERS,
+	}
+
+	lines := SplitSegments(msg)
+	if len(lines) == 0 {
+		return header, errors.ERROR_INVALID_HL7_MESSAGE
+	}
+	message, err := ParseHL7(lines[0])
+	if err != nil {
+		return header, err
+	}
+
+	return message.Header(), nil
+}
+
+// BuildHL7Ack builds an ACK message (MSH + MSA, plus ERR when a cause is given)
+// in reply to the message described by header. The cause is reported by its
+// condition only, callers log it.
+func BuildHL7Ack(header MessageHeader, code AckCode, cause error) string {
+	fs := header.FieldSeparator
+	if fs == "" {
+		fs = DEFAULT_FIELD_SEPARATOR
+	}
+	enc := header.EncodingCharacters
+	if enc == "" {
+		enc = DEFAULT_ENCODING_CHARACTERS
+	}
+	version := header.Version
+	if version == "" {
+		version = DEFAULT_HL7_VERSION
+	}
+	delimiters := NewDelimiters(fs, enc)
+	processingID := header.ProcessingID
+	if processingID == "" {
+		processingID = "P"
+	}
+
+	// ACK^<trigger event> and the message structure from 2.4 onwards
+	compSep := string(enc[0])
+	messageType := "ACK"
+	if parts := strings.Split(header.MessageType, compSep); len(parts) > 1 && parts[1] != "" {
+		messageType += compSep + parts[1]
+		if versionAtLeast(version, 2, 4) {
+			messageType += compSep + "ACK"
+		}
+	}
+
+	controlID := strings.ReplaceAll(uuid.NewString(), "-", "")[:20]
+	segments := []string{
+		strings.Join([]string{
+			"MSH",
+			enc,
+			header.ReceivingApplication,
+			header.ReceivingFacility,
+			header.SendingApplication,
+			header.SendingFacility,
+			time.Now().Format("20060102150405"),
+			"",
+			messageType,
+			controlID,
+			processingID,
+			version,
+		}, fs),
+	}
+
+	condition, text := "", ""
+	if cause != nil {
+		condition, text = HL7AckCondition(cause)
+	}
+	segments = append(segments, strings.Join([]string{
+		"MSA",
+		string(code),
+		header.ControlID,
+		delimiters.Encode(text),
+	}, fs))
+
+	if cause != nil && code != ACK_APPLICATION_ACCEPT {
+		segments = append(segments, buildErrSegment(version, fs, enc, condition, delimiters.Encode(text)))
+	}
+
+	return strings.Join(segments, "\r") + "\r"
+}
+
+// buildErrSegment uses ERR-3/ERR-4 from 2.5 onwards and the
+// ERR-1 error code and location layout for older versions.
+func buildErrSegment(version, fs, enc, condition, text string) string {
+	compSep := string(enc[0])
+	if versionAtLeast(version, 2, 5) {
+		return strings.Join([]string{
+			"ERR",
+			"",
+			"",
+			condition + compSep + text + compSep + "HL70357",
+			"E",
+		}, fs)
+	}
+
+	subCompSep := "&"
+	if len(enc) > 3 {
+		subCompSep = string(enc[3])
+	}
+
+	return "ERR" + fs + strings.Repeat(compSep, 3) +
+		condition + subCompSep + text + subCompSep + "HL70357"
+}
+
+func versionAtLeast(version string, major, minor int) bool {
+	parts := strings.Split(version, ".")
+	vMajor, _ := strconv.Atoi(parts[0])
+	vMinor := 0
+	if len(parts) > 1 {
+		vMinor, _ = strconv.Atoi(parts[1])
+	}
+
+	return vMajor > major || (vMajor == major && vMinor >= minor)
+}
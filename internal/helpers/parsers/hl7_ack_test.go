@@ -0,0 +1,97 @@
+package parsers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHL7Header(t *testing.T) {
+	t.Run("valid header", func(t *testing.T) {
+		header, err := ParseHL7Header("MSH|^~\\&|LAB|LIS|EHR|HOSP|202501011200||ORU^R01|12345|P|2.3\rPID|1")
+		assert.NoError(t, err)
+		assert.Equal(t, MessageHeader{
+			FieldSeparator:       "|",
+			EncodingCharacters:   "^~\\&",
+			SendingApplication:   "LAB",
+			SendingFacility:      "LIS",
+			ReceivingApplication: "EHR",
+			ReceivingFacility:    "HOSP",
+			DateTime:             "2025-01-01T12:00:00",
+			MessageType:          "ORU^R01",
+			ControlID:            "12345",
+			ProcessingID:         "P",
+			Version:              "2.3",
+		}, header)
+	})
+
+	t.Run("missing MSH", func(t *testing.T) {
+		_, err := ParseHL7Header("PID|1||123")
+		assert.True(t, errors.Is(err, errors.ERROR_INVALID_HL7_MESSAGE))
+	})
+}
+
+func TestBuildHL7Ack(t *testing.T) {
+	header := MessageHeader{
+		FieldSeparator:       "|",
+		EncodingCharacters:   "^~\\&",
+		SendingApplication:   "LAB",
+		SendingFacility:      "LIS",
+		ReceivingApplication: "EHR",
+		ReceivingFacility:    "HOSP",
+		MessageType:          "ORU^R01",
+		ControlID:            "12345",
+		ProcessingID:         "P",
+		Version:              "2.3",
+	}
+
+	t.Run("application accept", func(t *testing.T) {
+		ack := BuildHL7Ack(header, ACK_APPLICATION_ACCEPT, nil)
+		segments := strings.Split(strings.TrimSuffix(ack, "\r"), "\r")
+		assert.Len(t, segments, 2)
+
+		msh := strings.Split(segments[0], "|")
+		assert.Equal(t, []string{"MSH", "^~\\&", "EHR", "HOSP", "LAB", "LIS"}, msh[:6])
+		assert.Equal(t, "ACK^R01", msh[8])
+		assert.Equal(t, "P", msh[10])
+		assert.Equal(t, "2.3", msh[11])
+		assert.Equal(t, "MSA|AA|12345|", segments[1])
+	})
+
+	t.Run("application error hides the cause", func(t *testing.T) {
+		cause := errors.Wrap(errors.New("dial tcp 10.0.0.5:3306: connection refused"), errors.ERROR_INTERNAL_SERVER.Error())
+		ack := BuildHL7Ack(header, ACK_APPLICATION_ERROR, cause)
+		segments := strings.Split(strings.TrimSuffix(ack, "\r"), "\r")
+		assert.Len(t, segments, 3)
+		assert.Equal(t, "MSA|AE|12345|Application internal error", segments[1])
+		assert.Equal(t, "ERR|^^^207&Application internal error&HL70357", segments[2])
+		assert.NotContains(t, ack, "10.0.0.5")
+	})
+
+	t.Run("application error for parse diagnostics", func(t *testing.T) {
+		ack := BuildHL7Ack(header, ACK_APPLICATION_ERROR, errors.Wrapf(errors.ERROR_PARSE_DIAGNOSTICS, "%d diagnostics", 2))
+		segments := strings.Split(strings.TrimSuffix(ack, "\r"), "\r")
+		assert.Equal(t, "MSA|AE|12345|Data type error", segments[1])
+		assert.Equal(t, "ERR|^^^102&Data type error&HL70357", segments[2])
+	})
+
+	t.Run("application reject for 2.5 message", func(t *testing.T) {
+		v25 := header
+		v25.Version = "2.5.1"
+		ack := BuildHL7Ack(v25, ACK_APPLICATION_REJECT, errors.ERROR_INVALID_HL7_MESSAGE)
+		segments := strings.Split(strings.TrimSuffix(ack, "\r"), "\r")
+		assert.Len(t, segments, 3)
+		assert.Equal(t, "ACK^R01^ACK", strings.Split(segments[0], "|")[8])
+		assert.Equal(t, "ERR|||100^Segment sequence error^HL70357|E", segments[2])
+	})
+
+	t.Run("application reject for processing id", func(t *testing.T) {
+		ack := BuildHL7Ack(header, ACK_APPLICATION_REJECT, errors.Wrap(errors.ERROR_PROCESSING_ID_REJECTED, "processing id T"))
+		segments := strings.Split(strings.TrimSuffix(ack, "\r"), "\r")
+		assert.Len(t, segments, 3)
+		assert.Equal(t, "MSA|AR|12345|Unsupported processing id", segments[1])
+		assert.Equal(t, "ERR|^^^202&Unsupported processing id&HL70357", segments[2])
+	})
+}
@@ -0,0 +1,145 @@
+package parsers
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// Delimiters are the separators declared by MSH-1 and MSH-2.
+type Delimiters struct {
+	Field        byte
+	Component    byte
+	Repetition   byte
+	Escape       byte
+	SubComponent byte
+}
+
+func DefaultDelimiters() Delimiters {
+	return Delimiters{
+		Field:        '|',
+		Component:    '^',
+		Repetition:   '~',
+		Escape:       '\\',
+		SubComponent: '&',
+	}
+}
+
+// NewDelimiters builds delimiters from the MSH-1 field separator and the MSH-2
+// encoding characters, keeping defaults for characters not declared.
+func NewDelimiters(fieldSeparator, encodingCharacters string) Delimiters {
+	d := DefaultDelimiters()
+	if fieldSeparator != "" {
+		d.Field = fieldSeparator[0]
+	}
+
+	targets := []*byte{&d.Component, &d.Repetition, &d.Escape, &d.SubComponent}
+	for i := 0; i < len(encodingCharacters) && i < len(targets); i++ {
+		*targets[i] = encodingCharacters[i]
+	}
+
+	return d
+}
+
+// ParseDelimiters reads the delimiters from an MSH segment.
+func ParseDelimiters(msh string) (Delimiters, error) {
+	if !strings.HasPrefix(msh, "MSH") || len(msh) < 4 {
+		return DefaultDelimiters(), errors.ERROR_INVALID_HL7_MESSAGE
+	}
+
+	encodingCharacters := msh[4:]
+	if end := strings.IndexByte(encodingCharacters, msh[3]); end != -1 {
+		encodingCharacters = encodingCharacters[:end]
+	}
+
+	return NewDelimiters(msh[3:4], encodingCharacters), nil
+}
+
+// EncodingCharacters returns the MSH-2 representation of the delimiters.
+func (d Delimiters) EncodingCharacters() string {
+	return string([]byte{d.Component, d.Repetition, d.Escape, d.SubComponent})
+}
+
+// Decode resolves HL7 escape sequences: \F\ \S\ \T\ \R\ \E\ for the
+// delimiters, \Xhh..\ for raw bytes and \.br\ for line breaks. Formatting
+// and character set sequences are dropped.
+func (d Delimiters) Decode(value string) string {
+	if strings.IndexByte(value, d.Escape) == -1 {
+		return value
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != d.Escape {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		end := strings.IndexByte(value[i+1:], d.Escape)
+		if end == -1 {
+			// dangling escape character, keep as literal
+			sb.WriteString(value[i:])
+			break
+		}
+		sequence := value[i+1 : i+1+end]
+		i += end + 1
+
+		switch {
+		case sequence == "F":
+			sb.WriteByte(d.Field)
+		case sequence == "S":
+			sb.WriteByte(d.Component)
+		case sequence == "T":
+			sb.WriteByte(d.SubComponent)
+		case sequence == "R":
+			sb.WriteByte(d.Repetition)
+		case sequence == "E":
+			sb.WriteByte(d.Escape)
+		case sequence == ".br":
+			sb.WriteByte('\n')
+		case strings.HasPrefix(sequence, "X"):
+			if decoded, err := hex.DecodeString(sequence[1:]); err == nil {
+				sb.Write(decoded)
+			}
+		default:
+			// \H\ \N\ highlighting, \Cxxyy\ \Mxxyyzz\ charsets, \Z..\ locals
+			// and other formatting commands carry no data
+		}
+	}
+
+	return sb.String()
+}
+
+// Encode escapes delimiter characters and line breaks in free text.
+func (d Delimiters) Encode(value string) string {
+	esc := string(d.Escape)
+
+	return strings.NewReplacer(
+		esc, esc+"E"+esc,
+		string(d.Field), esc+"F"+esc,
+		string(d.Component), esc+"S"+esc,
+		string(d.SubComponent), esc+"T"+esc,
+		string(d.Repetition), esc+"R"+esc,
+		"\r\n", esc+".br"+esc,
+		"\r", esc+".br"+esc,
+		"\n", esc+".br"+esc,
+	).Replace(value)
+}
+
+// SplitSegments splits a message on the standard \r segment terminator,
+// also accepting \n and \r\n from gateways that rewrite line endings.
+func SplitSegments(msg string) []string {
+	segments := []string{}
+	for _, seg := range strings.FieldsFunc(msg, func(r rune) bool {
+		return r == '\r' || r == '\n'
+	}) {
+		seg = strings.TrimSpace(seg)
+		if seg != "" {
+			segments = append(segments, seg)
+		}
+	}
+
+	return segments
+}
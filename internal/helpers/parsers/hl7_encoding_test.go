@@ -0,0 +1,55 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDelimiters(t *testing.T) {
+	d, err := ParseDelimiters("MSH#$*!@#LEGACY")
+	assert.NoError(t, err)
+	assert.Equal(t, Delimiters{Field: '#', Component: '$', Repetition: '*', Escape: '!', SubComponent: '@'}, d)
+	assert.Equal(t, "$*!@", d.EncodingCharacters())
+
+	// truncated encoding characters keep defaults
+	d, err = ParseDelimiters("MSH|^~|LAB")
+	assert.NoError(t, err)
+	assert.Equal(t, byte('\\'), d.Escape)
+	assert.Equal(t, byte('&'), d.SubComponent)
+
+	_, err = ParseDelimiters("PID|1")
+	assert.Error(t, err)
+}
+
+func TestDelimitersDecode(t *testing.T) {
+	d := DefaultDelimiters()
+	tests := map[string]string{
+		"plain":                "plain",
+		`a\F\b`:                "a|b",
+		`a\S\b\T\c\R\d\E\e`:    `a^b&c~d\e`,
+		`line1\.br\line2`:      "line1\nline2",
+		`\X4142\C`:             "ABC",
+		`\H\bold\N\ text`:      "bold text",
+		`\C2842\charset`:       "charset",
+		`dangling \ escape`:    `dangling \ escape`,
+		`\Xzz\invalid hex`:     "invalid hex",
+		`\E\\E\ double escape`: `\\ double escape`,
+	}
+	for input, expected := range tests {
+		assert.Equal(t, expected, d.Decode(input), input)
+	}
+}
+
+func TestDelimitersEncode(t *testing.T) {
+	d := DefaultDelimiters()
+	text := "ratio 1^2 | A&B ~ C \\ D\r\nnext"
+	encoded := d.Encode(text)
+	assert.Equal(t, `ratio 1\S\2 \F\ A\T\B \R\ C \E\ D\.br\next`, encoded)
+	assert.Equal(t, "ratio 1^2 | A&B ~ C \\ D\nnext", d.Decode(encoded))
+}
+
+func TestSplitSegments(t *testing.T) {
+	assert.Equal(t, []string{"MSH|1", "PID|2", "OBX|3"}, SplitSegments("MSH|1\rPID|2\r\nOBX|3\n\r"))
+	assert.Empty(t, SplitSegments("\r\n \r"))
+}
@@ -0,0 +1,274 @@
+package parsers
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+type (
+	// HL7Message is the generic representation of a parsed message. Every value
+	// is kept, so any segment or field can be addressed with a Path.
+	HL7Message struct {
+		Delimiters Delimiters `json:"-"`
+		Segments   []Segment  `json:"segments"`
+	}
+
+	// Segment fields are 1-based, Fields[0] holds HL7 field 1 (for MSH this is
+	// the field separator itself and Fields[1] the encoding characters).
+	Segment struct {
+		Name   string  `json:"name"`
+		Fields []Field `json:"fields"`
+	}
+
+	// Field is a list of repetitions, each a list of components made of
+	// decoded sub-components.
+	Field      []Repetition
+	Repetition []Component
+	Component  []string
+
+	// MessageHeader holds the MSH metadata. Application and facility fields
+	// keep their components so they can be echoed back in acknowledgements.
+	MessageHeader struct {
+		FieldSeparator       string `json:"field_separator"`
+		EncodingCharacters   string `json:"encoding_characters"`
+		SendingApplication   string `json:"sending_application,omitempty"`
+		SendingFacility      string `json:"sending_facility,omitempty"`
+		ReceivingApplication string `json:"receiving_application,omitempty"`
+		ReceivingFacility    string `json:"receiving_facility,omitempty"`
+		DateTime             string `json:"date_time,omitempty"`
+		MessageType          string `json:"message_type,omitempty"`
+		ControlID            string `json:"control_id,omitempty"`
+		ProcessingID         string `json:"processing_id,omitempty"`
+		Version              string `json:"version,omitempty"`
+		CharacterSet         string `json:"character_set,omitempty"`
+	}
+
+	// Path addresses a value in terser style: SEG(rep)-field(rep).component.subcomponent.
+	// Segment and field repetitions are 0-based like HAPI's Terser, field,
+	// component and sub-component numbers are 1-based like the HL7 standard.
+	Path struct {
+		Segment           string
+		SegmentRepetition int
+		Field             int
+		FieldRepetition   int
+		Component         int
+		SubComponent      int
+	}
+)
+
+var pathPattern = regexp.MustCompile(`^([A-Z][A-Z0-9]{2})(?:\((\d+)\))?-(\d+)(?:\((\d+)\))?(?:\.(\d+))?(?:\.(\d+))?$`)
+
+// ParseHL7 splits a message into segments, fields, repetitions, components and
+// sub-components, decoding escape sequences at the leaves.
+func ParseHL7(msg string) (*HL7Message, error) {
+	lines := SplitSegments(msg)
+	if len(lines) == 0 {
+		return nil, errors.ERROR_INVALID_HL7_MESSAGE
+	}
+	delimiters, err := ParseDelimiters(lines[0])
+	if err != nil {
+		return nil, err
+	}
+
+	message := &HL7Message{Delimiters: delimiters}
+	for _, line := range lines {
+		if len(line) < 3 {
+			continue
+		}
+
+		rawFields := strings.Split(line, string(delimiters.Field))
+		segment := Segment{Name: rawFields[0]}
+		rawFields = rawFields[1:]
+
+		// MSH-1 and MSH-2 declare the delimiters and are never split
+		if segment.Name == "MSH" {
+			segment.Fields = append(segment.Fields,
+				Field{{{string(delimiters.Field)}}},
+				Field{{{delimiters.EncodingCharacters()}}},
+			)
+			if len(rawFields) > 0 {
+				rawFields = rawFields[1:]
+			}
+		}
+
+		for _, rawField := range rawFields {
+			segment.Fields = append(segment.Fields, parseField(rawField, delimiters))
+		}
+		message.Segments = append(message.Segments, segment)
+	}
+
+	return message, nil
+}
+
+func parseField(raw string, delimiters Delimiters) Field {
+	field := Field{}
+	for _, rawRep := range strings.Split(raw, string(delimiters.Repetition)) {
+		repetition := Repetition{}
+		for _, rawComp := range strings.Split(rawRep, string(delimiters.Component)) {
+			component := Component{}
+			for _, rawSub := range strings.Split(rawComp, string(delimiters.SubComponent)) {
+				component = append(component, delimiters.Decode(rawSub))
+			}
+			repetition = append(repetition, component)
+		}
+		field = append(field, repetition)
+	}
+
+	return field
+}
+
+// Header returns the MSH metadata of the message.
+func (m *HL7Message) Header() MessageHeader {
+	header := MessageHeader{
+		FieldSeparator:     string(m.Delimiters.Field),
+		EncodingCharacters: m.Delimiters.EncodingCharacters(),
+	}
+	segments := m.SegmentsByName("MSH")
+	if len(segments) == 0 {
+		return header
+	}
+
+	msh := segments[0]
+	text := func(n int) string {
+		return msh.Field(n).Text(m.Delimiters)
+	}
+	header.SendingApplication = text(3)
+	header.SendingFacility = text(4)
+	header.ReceivingApplication = text(5)
+	header.ReceivingFacility = text(6)
+	header.DateTime = parseHL7Timestamp(msh.Get(7, 0, 1, 1))
+	header.MessageType = text(9)
+	header.ControlID = msh.Get(10, 0, 1, 1)
+	header.ProcessingID = msh.Get(11, 0, 1, 1)
+	header.Version = msh.Get(12, 0, 1, 1)
+	header.CharacterSet = msh.Get(18, 0, 1, 1)
+
+	return header
+}
+
+// ParsePath parses a terser style path such as "OBX(2)-5.1" or "PID-3(1).5".
+func ParsePath(path string) (Path, error) {
+	matches := pathPattern.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(path)))
+	if matches == nil {
+		return Path{}, errors.Wrapf(errors.ERROR_INVALID_HL7_PATH, "path %q", path)
+	}
+
+	atoi := func(s string, fallback int) int {
+		if s == "" {
+			return fallback
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	res := Path{
+		Segment:           matches[1],
+		SegmentRepetition: atoi(matches[2], 0),
+		Field:             atoi(matches[3], 0),
+		FieldRepetition:   atoi(matches[4], 0),
+		Component:         atoi(matches[5], 1),
+		SubComponent:      atoi(matches[6], 1),
+	}
+	if res.Field < 1 || res.Component < 1 || res.SubComponent < 1 {
+		return Path{}, errors.Wrapf(errors.ERROR_INVALID_HL7_PATH, "path %q", path)
+	}
+
+	return res, nil
+}
+
+// Get returns the decoded value at path, or an empty string when the message
+// does not contain it.
+func (m *HL7Message) Get(path string) (string, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	segments := m.SegmentsByName(p.Segment)
+	if p.SegmentRepetition >= len(segments) {
+		return "", nil
+	}
+
+	return segments[p.SegmentRepetition].Value(p), nil
+}
+
+// Extract resolves a set of named paths, skipping invalid ones.
+func (m *HL7Message) Extract(paths map[string]string) map[string]string {
+	res := map[string]string{}
+	for name, path := range paths {
+		if value, err := m.Get(path); err == nil && value != "" {
+			res[name] = value
+		}
+	}
+
+	return res
+}
+
+// SegmentsByName returns all segments with the given name in message order.
+func (m *HL7Message) SegmentsByName(name string) []Segment {
+	res := []Segment{}
+	for _, segment := range m.Segments {
+		if segment.Name == name {
+			res = append(res, segment)
+		}
+	}
+
+	return res
+}
+
+// Value returns the value of a path within this segment, ignoring the path's
+// segment name and repetition.
+func (s Segment) Value(p Path) string {
+	return s.Get(p.Field, p.FieldRepetition, p.Component, p.SubComponent)
+}
+
+// Get returns a single decoded sub-component. Field, component and
+// sub-component are 1-based, repetition is 0-based.
+func (s Segment) Get(field, repetition, component, subComponent int) string {
+	f := s.Field(field)
+	if repetition < 0 || repetition >= len(f) {
+		return ""
+	}
+	rep := f[repetition]
+	if component < 1 || component > len(rep) {
+		return ""
+	}
+	comp := rep[component-1]
+	if subComponent < 1 || subComponent > len(comp) {
+		return ""
+	}
+
+	return comp[subComponent-1]
+}
+
+// Field returns the 1-based field, or nil when absent.
+func (s Segment) Field(n int) Field {
+	if n < 1 || n > len(s.Fields) {
+		return nil
+	}
+
+	return s.Fields[n-1]
+}
+
+// Text joins a field back into a readable value: components and
+// sub-components with their delimiters, repetitions with line breaks.
+func (f Field) Text(delimiters Delimiters) string {
+	reps := make([]string, 0, len(f))
+	for _, rep := range f {
+		reps = append(reps, rep.Text(delimiters))
+	}
+
+	return strings.Join(reps, "\n")
+}
+
+func (r Repetition) Text(delimiters Delimiters) string {
+	comps := make([]string, 0, len(r))
+	for _, comp := range r {
+		comps = append(comps, strings.Join(comp, string(delimiters.SubComponent)))
+	}
+
+	return strings.TrimRight(strings.Join(comps, string(delimiters.Component)), string(delimiters.Component))
+}
@@ -0,0 +1,116 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const vendorMessage = "MSH|^~\\&|DxH|BECKMAN|LIS|LAB|20250701101010||ORU^R01|VX-1|P|2.5\r" +
+	"PID|1||MRN-1^^^HOSP&1.2.3&ISO^MR~NIK-9^^^DUKCAPIL^NI||LIM^ANDI\r" +
+	"OBR|1||ORD-1|CBC^Complete blood count^L|||20250701100000\r" +
+	"OBX|1|NM|WBC^WBC^L||6.2|10*3/uL|4.0-10.0|N|||F|||20250701100500||||DXH-800-01^BECKMAN\r" +
+	"OBX|2|NM|HGB^HGB^L||13.1|g/dL|12.0-16.0|N|||F|||20250701100500||||DXH-800-02^BECKMAN\r" +
+	"ZXX|1|RACK-17|POS-3|QC\\T\\CAL\r"
+
+func TestParsePath(t *testing.T) {
+	p, err := ParsePath("OBX(2)-5.1")
+	assert.NoError(t, err)
+	assert.Equal(t, Path{Segment: "OBX", SegmentRepetition: 2, Field: 5, Component: 1, SubComponent: 1}, p)
+
+	p, err = ParsePath("pid-3(1).5")
+	assert.NoError(t, err)
+	assert.Equal(t, Path{Segment: "PID", Field: 3, FieldRepetition: 1, Component: 5, SubComponent: 1}, p)
+
+	p, err = ParsePath("PID-3.4.2")
+	assert.NoError(t, err)
+	assert.Equal(t, Path{Segment: "PID", Field: 3, Component: 4, SubComponent: 2}, p)
+
+	for _, invalid := range []string{"", "OBX", "OBX-0", "OBX-5.0", "OBX(a)-5", "OBX-5..1", "1BX-5"} {
+		_, err := ParsePath(invalid)
+		assert.True(t, errors.Is(err, errors.ERROR_INVALID_HL7_PATH), invalid)
+	}
+}
+
+func TestHL7MessageGet(t *testing.T) {
+	message, err := ParseHL7(vendorMessage)
+	assert.NoError(t, err)
+	assert.Len(t, message.Segments, 6)
+
+	tests := map[string]string{
+		"MSH-1":       "|",
+		"MSH-2":       "^~\\&",
+		"MSH-9.2":     "R01",
+		"MSH-10":      "VX-1",
+		"PID-3":       "MRN-1",
+		"PID-3(1).5":  "NI",
+		"PID-3.4.2":   "1.2.3",
+		"PID-5.2":     "ANDI",
+		"OBX-18":      "DXH-800-01",
+		"OBX(1)-5.1":  "13.1",
+		"OBX(1)-18.2": "BECKMAN",
+		"OBX(2)-5":    "",
+		"ZXX-2":       "RACK-17",
+		"ZXX-4":       "QC&CAL",
+		"NTE-3":       "",
+		"PID-3(5).1":  "",
+		"PID-30.1.1":  "",
+		"OBR-4.2":     "Complete blood count",
+	}
+	for path, expected := range tests {
+		value, err := message.Get(path)
+		assert.NoError(t, err, path)
+		assert.Equal(t, expected, value, path)
+	}
+
+	_, err = message.Get("not a path")
+	assert.Error(t, err)
+}
+
+func TestHL7FieldText(t *testing.T) {
+	message, err := ParseHL7("MSH|^~\\&|LAB\rOBX|1|CE|X||POS^Positive^L~NEG&x^Negative")
+	assert.NoError(t, err)
+
+	obx := message.SegmentsByName("OBX")[0]
+	assert.Equal(t, "POS^Positive^L\nNEG&x^Negative", obx.Field(5).Text(message.Delimiters))
+	assert.Nil(t, obx.Field(40))
+}
+
+func TestParseHL7Message_WithFieldMappings(t *testing.T) {
+	res, err := ParseHL7Message(vendorMessage, WithFieldMappings(map[string]string{
+		"equipment_id":  "OBX-18",
+		"rack":          "ZXX-2",
+		"position":      "ZXX-3",
+		"national_id":   "PID-3(1).1",
+		"missing_field": "ZZZ-1",
+		"invalid_path":  "???",
+	}))
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"rack":        "RACK-17",
+		"position":    "POS-3",
+		"national_id": "NIK-9",
+	}, res.Extras)
+	assert.Len(t, res.Tests, 2)
+	assert.Equal(t, map[string]string{"equipment_id": "DXH-800-01"}, res.Tests[0].Extras)
+	assert.Equal(t, map[string]string{"equipment_id": "DXH-800-02"}, res.Tests[1].Extras)
+}
+
+func TestParseHL7Message_Diagnostics(t *testing.T) {
+	msg := "MSH|^~\\&|LAB|HOSP|LIS|HOSP|20250301083000||ORU^R01|MSG-1|P|2.5.1\r" +
+		"PID|1\r" +
+		"OBR|1\r" +
+		"OBX|1|NM|GLU^Glucose||high|mg/dL\r" +
+		"obx|2|NM|HGB^Hemoglobin||13.9|g/dL\r"
+
+	res, err := ParseHL7Message(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, []Diagnostic{
+		{Kind: DIAGNOSTIC_INVALID_NUMBER, Location: "segment 4 OBX", Field: "OBX-5", Value: "high"},
+		{Kind: DIAGNOSTIC_UNRECOGNIZED, Location: "segment 5 obx"},
+		{Kind: DIAGNOSTIC_MISSING_FIELD, Field: FIELD_SPECIMEN_ID},
+		{Kind: DIAGNOSTIC_MISSING_FIELD, Field: FIELD_TIMESTAMP},
+	}, res.Diagnostics)
+}
@@ -0,0 +1,82 @@
+package parsers
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// TestParseHL7Message_Corpus parses every message in testdata/hl7 and compares
+// the result with its golden json. Run with -update to regenerate.
+func TestParseHL7Message_Corpus(t *testing.T) {
+	files, err := filepath.Glob("testdata/hl7/*.hl7")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			msg, err := os.ReadFile(file)
+			assert.NoError(t, err)
+
+			res, err := ParseHL7Message(string(msg))
+			assert.NoError(t, err)
+
+			actual, err := json.MarshalIndent(res, "", "  ")
+			assert.NoError(t, err)
+
+			golden := strings.TrimSuffix(file, ".hl7") + ".json"
+			if *update {
+				assert.NoError(t, os.WriteFile(golden, append(actual, '\n'), 0o644))
+			}
+			expected, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestParseHL7Message_Decoding(t *testing.T) {
+	msg, err := os.ReadFile("testdata/hl7/roche_cobas_escaped.hl7")
+	assert.NoError(t, err)
+
+	res, err := ParseHL7Message(string(msg))
+	assert.NoError(t, err)
+
+	// sub-components and escaped delimiters
+	assert.Equal(t, "O^BRIEN", res.Patient.Name.Family)
+	assert.Equal(t, "RSUD&Jakarta", res.Patient.Identifiers[0].AssigningAuthority)
+	assert.Equal(t, "Jl. Merdeka No.5|7", res.Patient.Address.Street)
+	assert.Equal(t, "0.27^4.20", res.Tests[0].ReferenceRange)
+	assert.Equal(t, "mIU/L", res.Tests[0].Units)
+	assert.Equal(t, "Sample slightly hemolyzed\nRepeat advised: ratio 1^2 & trend \\ stable\nSecond line A", res.Tests[1].Value)
+}
+
+func TestParseHL7Message_Invalid(t *testing.T) {
+	for _, msg := range []string{"", "\r\n", "PID|1||123", "MSH"} {
+		_, err := ParseHL7Message(msg)
+		assert.True(t, errors.Is(err, errors.ERROR_INVALID_HL7_MESSAGE), msg)
+	}
+}
+
+func TestParseHL7Timestamp(t *testing.T) {
+	for ts, expected := range map[string]string{
+		"20250301140000":       "2025-03-01T14:00:00",
+		"20250301140000.1234":  "2025-03-01T14:00:00",
+		"202503011400+0700":    "2025-03-01T14:00:00+07:00",
+		"20250301140000-0500":  "2025-03-01T14:00:00-05:00",
+		"20250301":             "2025-03-01",
+		"2025030114":           "2025-03-01T14:00:00",
+		"":                     "",
+		"2025-03-01T14:00:00Z": "",
+	} {
+		assert.Equal(t, expected, parseHL7Timestamp(ts), ts)
+	}
+}
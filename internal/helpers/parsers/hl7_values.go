@@ -0,0 +1,116 @@
+package parsers
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OBX-2 value types with a dedicated mapping, anything else is kept as text
+const (
+	VALUE_TYPE_NUMERIC           = "NM"
+	VALUE_TYPE_STRUCTURED_NUMBER = "SN"
+	VALUE_TYPE_CODED_ENTRY       = "CE"
+	VALUE_TYPE_CODED_WITH_EXCEPT = "CWE"
+	VALUE_TYPE_CODED_NO_EXCEPT   = "CNE"
+	VALUE_TYPE_STRING            = "ST"
+	VALUE_TYPE_TEXT              = "TX"
+	VALUE_TYPE_FORMATTED_TEXT    = "FT"
+)
+
+// SN_COMPARATORS are the valid SN-1 values
+var SN_COMPARATORS = []string{"", ">", "<", ">=", "<=", "=", "<>"}
+
+// setObservationValue maps OBX-5 according to the OBX-2 value type. Value
+// always holds a readable rendering so consumers that ignore the typed
+// fields keep working.
+func setObservationValue(test *Test, field Field, delimiters Delimiters) {
+	switch test.ValueType {
+	case VALUE_TYPE_NUMERIC:
+		test.Value = strings.TrimSpace(field.Text(delimiters))
+		test.NumericValue = parseNumber(test.Value)
+
+	case VALUE_TYPE_STRUCTURED_NUMBER:
+		setStructuredNumber(test, field)
+
+	case VALUE_TYPE_CODED_ENTRY, VALUE_TYPE_CODED_WITH_EXCEPT, VALUE_TYPE_CODED_NO_EXCEPT:
+		if len(field) == 0 {
+			return
+		}
+		// code^text^coding system, the first repetition is the answer
+		test.ValueCode = componentValue(field[0], 1)
+		test.ValueText = componentValue(field[0], 2)
+		test.Value = test.ValueText
+		if test.Value == "" {
+			test.Value = test.ValueCode
+		}
+
+	default:
+		// ST, TX, FT and unknown types, repetitions are lines of text
+		test.Value = field.Text(delimiters)
+	}
+}
+
+// setStructuredNumber handles SN: comparator ^ num1 ^ separator/suffix ^ num2,
+// e.g. "<^5", ">=^10", "^1^:^128" (ratio) or "^10^-^20" (range).
+func setStructuredNumber(test *Test, field Field) {
+	if len(field) == 0 {
+		return
+	}
+
+	rep := field[0]
+	comparator := componentValue(rep, 1)
+	first := componentValue(rep, 2)
+	separator := componentValue(rep, 3)
+	second := componentValue(rep, 4)
+	// some analyzers drop the empty comparator and send "1^:^128"
+	if !slices.Contains(SN_COMPARATORS, comparator) && parseNumber(comparator) != nil {
+		comparator, first, separator, second = "", comparator, first, separator
+	}
+
+	test.Comparator = comparator
+	test.Value = comparator + first + separator + second
+
+	num := parseNumber(first)
+	switch separator {
+	case ":", "/":
+		// ratios such as titers are kept as their quotient
+		denominator := parseNumber(second)
+		if num != nil && denominator != nil && *denominator != 0 {
+			quotient := *num / *denominator
+			num = &quotient
+		} else {
+			num = nil
+		}
+	}
+	test.NumericValue = num
+}
+
+// isContinuation tells whether next is a continuation line of a text
+// observation, i.e. same identifier and sub-ID as the previous one.
+func isContinuation(previous, next Test) bool {
+	if next.ValueType != VALUE_TYPE_TEXT && next.ValueType != VALUE_TYPE_FORMATTED_TEXT {
+		return false
+	}
+
+	return previous.ValueType == next.ValueType &&
+		previous.Code == next.Code &&
+		previous.SubID == next.SubID
+}
+
+func componentValue(rep Repetition, n int) string {
+	if n < 1 || n > len(rep) || len(rep[n-1]) == 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(rep[n-1][0])
+}
+
+func parseNumber(value string) *float64 {
+	num, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return nil
+	}
+
+	return &num
+}
@@ -0,0 +1,35 @@
+package parsers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetStructuredNumber(t *testing.T) {
+	number := func(n float64) *float64 { return &n }
+
+	tests := []struct {
+		raw        string
+		value      string
+		comparator string
+		numeric    *float64
+	}{
+		{raw: "<^5", value: "<5", comparator: "<", numeric: number(5)},
+		{raw: ">=^10.5", value: ">=10.5", comparator: ">=", numeric: number(10.5)},
+		{raw: "^1^:^128", value: "1:128", numeric: number(1.0 / 128)},
+		{raw: "1^:^128", value: "1:128", numeric: number(1.0 / 128)},
+		{raw: "^1^/^0", value: "1/0"},
+		{raw: "^10^-^20", value: "10-20", numeric: number(10)},
+		{raw: "^2^+", value: "2+", numeric: number(2)},
+		{raw: "<^abc", value: "<abc", comparator: "<"},
+	}
+	for _, tt := range tests {
+		test := Test{ValueType: VALUE_TYPE_STRUCTURED_NUMBER}
+		setObservationValue(&test, parseField(tt.raw, DefaultDelimiters()), DefaultDelimiters())
+
+		assert.Equal(t, tt.value, test.Value, tt.raw)
+		assert.Equal(t, tt.comparator, test.Comparator, tt.raw)
+		assert.Equal(t, tt.numeric, test.NumericValue, tt.raw)
+	}
+}
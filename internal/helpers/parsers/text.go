@@ -0,0 +1,407 @@
+package parsers
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Named capture groups read from a result line
+const (
+	TEXT_GROUP_CODE    = "code"
+	TEXT_GROUP_VALUE   = "value"
+	TEXT_GROUP_NUMERIC = "numeric"
+	TEXT_GROUP_UNIT    = "unit"
+)
+
+// TextDefinition describes the printout of a text instrument so it can be
+// parsed without code. Definitions are written in YAML or JSON, for example
+//
+//	device_type_code: urine
+//	record:
+//	  start: '^NO\.'
+//	specimen_id:
+//	  pattern: '(?m)^(NO\.\d+)'
+//	date_time:
+//	  pattern: '(?m)^NO\.\d+\s+(\d{4}-\d{2}-\d{2})\s*\n\s*(\d{2}:\d{2}:\d{2})'
+//	  layout: '2006-01-02 15:04:05'
+//	lines:
+//	  - '^\*?(?P<code>\w{2,4})\s+(?P<value>[+\-]?\w+)\s+(?P<numeric>[\d.]+)\s+(?P<unit>\S+)'
+//	  - '^\*?(?P<code>\w{2,4})\s+(?P<value>[\w.+\-]+)'
+//	ignore:
+//	  - '^\d{2}:\d{2}:\d{2}$'
+//	parameters:
+//	  LEU: {code: leukocytes, qualitative: {'-': negative}}
+//	  SG: {code: specific_gravity}
+type TextDefinition struct {
+	DeviceTypeCode string `yaml:"device_type_code"`
+	// Record marks the lines of one result, the whole text when left empty
+	Record TextRecordMarkers `yaml:"record"`
+	// SpecimenID and DateTime run on the record text, their capture groups
+	// are joined with a space
+	SpecimenID TextPattern `yaml:"specimen_id"`
+	DateTime   TextPattern `yaml:"date_time"`
+	// Lines are tried in order on every line of the record, the first match
+	// wins. They capture the code, value, numeric and unit named groups.
+	Lines []string `yaml:"lines"`
+	// Ignore matches lines that are expected but carry no result, such as
+	// banners or the time line, so they are not reported as unrecognized
+	Ignore []string `yaml:"ignore"`
+	// Parameters maps a captured code to the published parameter, lines with
+	// a code missing here are left out and reported as unrecognized, which
+	// rejects the message of a strict device
+	Parameters map[string]TextParameter `yaml:"parameters"`
+	// Source is the file the definition was read from
+	Source string `yaml:"-"`
+}
+
+type TextRecordMarkers struct {
+	// Start matches the first line of a record
+	Start string `yaml:"start"`
+	// End matches the last line of a record, the next start otherwise
+	End string `yaml:"end"`
+}
+
+type TextPattern struct {
+	Pattern string `yaml:"pattern"`
+	// Layout is the time layout of the joined captures
+	Layout string `yaml:"layout"`
+}
+
+type TextParameter struct {
+	Code string `yaml:"code"`
+	Name string `yaml:"name"`
+	// Unit is used when the line does not print one
+	Unit string `yaml:"unit"`
+	// Qualitative maps a printed value to its qualitative result
+	Qualitative map[string]string `yaml:"qualitative"`
+}
+
+// TextResult types returned by the parser
+type TextResult struct {
+	Records []TextRecord `json:"records"`
+}
+
+type TextRecord struct {
+	SpecimenID  string            `json:"specimen_id,omitempty"`
+	DateTime    string            `json:"date_time,omitempty"`
+	Text        string            `json:"text"`
+	Tests       []TextResultValue `json:"tests"`
+	Diagnostics []Diagnostic      `json:"diagnostics,omitempty"`
+}
+
+type TextResultValue struct {
+	Code         string   `json:"code"`
+	Name         string   `json:"name,omitempty"`
+	RawCode      string   `json:"raw_code"`
+	Value        string   `json:"value"`
+	NumericValue *float64 `json:"numeric_value,omitempty"`
+	Unit         string   `json:"unit,omitempty"`
+	Qualitative  string   `json:"qualitative,omitempty"`
+}
+
+// TextParser parses the text of one instrument type with a compiled
+// TextDefinition.
+type TextParser struct {
+	definition  TextDefinition
+	recordStart *regexp.Regexp
+	recordEnd   *regexp.Regexp
+	specimenID  *regexp.Regexp
+	dateTime    *regexp.Regexp
+	lines       []*regexp.Regexp
+	ignore      []*regexp.Regexp
+}
+
+// textLines are the lines of one record, first is the 1-based line number
+// of the first one in the message.
+type textLines struct {
+	first int
+	lines []string
+}
+
+func NewTextParser(definition TextDefinition) (*TextParser, error) {
+	if definition.DeviceTypeCode == "" {
+		return nil, errors.Wrap(errors.ERROR_INVALID_TEXT_DEFINITION, "device_type_code is required")
+	}
+	if len(definition.Lines) == 0 {
+		return nil, errors.Wrapf(errors.ERROR_INVALID_TEXT_DEFINITION, "%s has no lines", definition.DeviceTypeCode)
+	}
+	if definition.DateTime.Pattern != "" && definition.DateTime.Layout == "" {
+		return nil, errors.Wrapf(errors.ERROR_INVALID_TEXT_DEFINITION, "%s date_time has no layout", definition.DeviceTypeCode)
+	}
+
+	parser := &TextParser{definition: definition}
+	compile := func(name, pattern string) (res *regexp.Regexp, err error) {
+		if pattern == "" {
+			return nil, nil
+		}
+		res, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_TEXT_DEFINITION, "%s %s: %s", definition.DeviceTypeCode, name, err.Error())
+		}
+
+		return res, nil
+	}
+
+	var err error
+	if parser.recordStart, err = compile("record start", definition.Record.Start); err != nil {
+		return nil, err
+	}
+	if parser.recordEnd, err = compile("record end", definition.Record.End); err != nil {
+		return nil, err
+	}
+	if parser.specimenID, err = compile("specimen_id", definition.SpecimenID.Pattern); err != nil {
+		return nil, err
+	}
+	if parser.dateTime, err = compile("date_time", definition.DateTime.Pattern); err != nil {
+		return nil, err
+	}
+	for i, pattern := range definition.Lines {
+		line, err := compile("line "+strconv.Itoa(i+1), pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(line.SubexpNames(), TEXT_GROUP_CODE) || !slices.Contains(line.SubexpNames(), TEXT_GROUP_VALUE) {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_TEXT_DEFINITION, "%s line %d needs the code and value groups", definition.DeviceTypeCode, i+1)
+		}
+		parser.lines = append(parser.lines, line)
+	}
+	for i, pattern := range definition.Ignore {
+		ignore, err := compile("ignore "+strconv.Itoa(i+1), pattern)
+		if err != nil {
+			return nil, err
+		}
+		parser.ignore = append(parser.ignore, ignore)
+	}
+
+	return parser, nil
+}
+
+func (p *TextParser) DeviceTypeCode() string {
+	return p.definition.DeviceTypeCode
+}
+
+// Parse cuts the text into records and reads every one of them.
+func (p *TextParser) Parse(text string) (TextResult, error) {
+	res := TextResult{Records: []TextRecord{}}
+	for _, lines := range p.records(text) {
+		res.Records = append(res.Records, p.parseRecord(lines))
+	}
+
+	return res, nil
+}
+
+// records groups the lines of the text per record. Lines outside the
+// start and end markers are dropped.
+func (p *TextParser) records(text string) (records []textLines) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	if p.recordStart == nil {
+		return []textLines{{first: 1, lines: lines}}
+	}
+
+	var current textLines
+	inRecord := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if p.recordStart.MatchString(trimmed) {
+			if inRecord {
+				records = append(records, current)
+			}
+			current, inRecord = textLines{first: i + 1, lines: []string{line}}, true
+			continue
+		}
+		if !inRecord {
+			continue
+		}
+
+		current.lines = append(current.lines, line)
+		if p.recordEnd != nil && p.recordEnd.MatchString(trimmed) {
+			records = append(records, current)
+			current, inRecord = textLines{}, false
+		}
+	}
+	if inRecord {
+		records = append(records, current)
+	}
+
+	return records
+}
+
+func (p *TextParser) parseRecord(lines textLines) TextRecord {
+	text := strings.Join(lines.lines, "\n")
+	record := TextRecord{Text: text, Tests: []TextResultValue{}}
+
+	if p.specimenID != nil {
+		record.SpecimenID = joinCaptures(p.specimenID.FindStringSubmatch(text))
+		if record.SpecimenID == "" {
+			record.Diagnostics = append(record.Diagnostics, Diagnostic{
+				Kind:     DIAGNOSTIC_MISSING_FIELD,
+				Location: LineLocation(lines.first),
+				Field:    FIELD_SPECIMEN_ID,
+			})
+		}
+	}
+	if p.dateTime != nil {
+		value := joinCaptures(p.dateTime.FindStringSubmatch(text))
+		dateTime, err := time.Parse(p.definition.DateTime.Layout, value)
+		switch {
+		case value == "":
+			record.Diagnostics = append(record.Diagnostics, Diagnostic{
+				Kind:     DIAGNOSTIC_MISSING_FIELD,
+				Location: LineLocation(lines.first),
+				Field:    FIELD_TIMESTAMP,
+			})
+		case err != nil:
+			record.Diagnostics = append(record.Diagnostics, Diagnostic{
+				Kind:     DIAGNOSTIC_INVALID_VALUE,
+				Location: LineLocation(lines.first),
+				Field:    FIELD_TIMESTAMP,
+				Value:    value,
+			})
+		default:
+			record.DateTime = dateTime.Format(LOCAL_DATETIME)
+			if hasZone(p.definition.DateTime.Layout) {
+				record.DateTime = dateTime.Format(time.RFC3339)
+			}
+		}
+	}
+
+	for i, line := range lines.lines {
+		line = strings.TrimSpace(line)
+		// the record markers carry no result
+		if line == "" || (i == 0 && p.recordStart != nil) || p.ignored(line) ||
+			(i == len(lines.lines)-1 && p.recordEnd != nil && p.recordEnd.MatchString(line)) {
+			continue
+		}
+
+		value, diagnostic := p.parseLine(line)
+		if diagnostic != nil {
+			diagnostic.Location = LineLocation(lines.first + i)
+			record.Diagnostics = append(record.Diagnostics, *diagnostic)
+		}
+		if diagnostic == nil || diagnostic.Kind != DIAGNOSTIC_UNRECOGNIZED {
+			record.Tests = append(record.Tests, value)
+		}
+	}
+
+	return record
+}
+
+func (p *TextParser) ignored(line string) bool {
+	for _, pattern := range p.ignore {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseLine reads one result line, the diagnostic tells when the line is
+// unrecognized or its numeric value could not be converted.
+func (p *TextParser) parseLine(line string) (TextResultValue, *Diagnostic) {
+	for _, pattern := range p.lines {
+		matches := pattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		captured := map[string]string{}
+		for i, name := range pattern.SubexpNames() {
+			if name != "" {
+				captured[name] = strings.TrimSpace(matches[i])
+			}
+		}
+
+		rawCode := captured[TEXT_GROUP_CODE]
+		parameter, ok := p.definition.Parameters[rawCode]
+		if !ok {
+			return TextResultValue{}, &Diagnostic{Kind: DIAGNOSTIC_UNRECOGNIZED, Value: line}
+		}
+
+		value := TextResultValue{
+			Code:        parameter.Code,
+			Name:        parameter.Name,
+			RawCode:     rawCode,
+			Value:       captured[TEXT_GROUP_VALUE],
+			Unit:        firstNonEmpty(captured[TEXT_GROUP_UNIT], parameter.Unit),
+			Qualitative: captured[TEXT_GROUP_VALUE],
+		}
+		if qualitative, ok := parameter.Qualitative[value.Value]; ok {
+			value.Qualitative = qualitative
+		}
+		// the numeric group wins over a numeric value, a captured numeric
+		// group that does not convert is reported
+		if numeric := captured[TEXT_GROUP_NUMERIC]; numeric != "" {
+			number, err := strconv.ParseFloat(numeric, 64)
+			if err != nil {
+				return value, &Diagnostic{Kind: DIAGNOSTIC_INVALID_NUMBER, Field: parameter.Code, Value: numeric}
+			}
+			value.NumericValue = &number
+		} else if number, err := strconv.ParseFloat(value.Value, 64); err == nil {
+			value.NumericValue = &number
+		}
+
+		return value, nil
+	}
+
+	return TextResultValue{}, &Diagnostic{Kind: DIAGNOSTIC_UNRECOGNIZED, Value: line}
+}
+
+// hasZone tells whether a time layout reads an offset or a zone name.
+func hasZone(layout string) bool {
+	return strings.Contains(layout, "-07") || strings.Contains(layout, "Z07") || strings.Contains(layout, "MST")
+}
+
+// LoadTextDefinitions reads every .yaml, .yml and .json definition of a
+// directory.
+func LoadTextDefinitions(dir string) ([]TextDefinition, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read text definitions in %s", dir)
+	}
+
+	definitions := []TextDefinition{}
+	for _, entry := range entries {
+		if entry.IsDir() || !slices.Contains([]string{".yaml", ".yml", ".json"}, filepath.Ext(entry.Name())) {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "read text definition %s", entry.Name())
+		}
+
+		// yaml also reads json documents
+		definition := TextDefinition{}
+		if err := yaml.Unmarshal(content, &definition); err != nil {
+			return nil, errors.Wrapf(errors.ERROR_INVALID_TEXT_DEFINITION, "%s: %s", entry.Name(), err.Error())
+		}
+		definition.Source = entry.Name()
+		definitions = append(definitions, definition)
+	}
+
+	return definitions, nil
+}
+
+func joinCaptures(matches []string) string {
+	if len(matches) < 2 {
+		return ""
+	}
+
+	captures := []string{}
+	for _, match := range matches[1:] {
+		if match = strings.TrimSpace(match); match != "" {
+			captures = append(captures, match)
+		}
+	}
+
+	return strings.Join(captures, " ")
+}
@@ -0,0 +1,107 @@
+package parsers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTextParser_Corpus parses every sample in testdata/text with the
+// definition of the same device type and compares the result with its
+// golden json. Run with -update to regenerate.
+func TestTextParser_Corpus(t *testing.T) {
+	definitions, err := LoadTextDefinitions("testdata/text/definitions")
+	assert.NoError(t, err)
+	assert.Len(t, definitions, 2)
+
+	for _, definition := range definitions {
+		t.Run(definition.DeviceTypeCode, func(t *testing.T) {
+			parser, err := NewTextParser(definition)
+			assert.NoError(t, err)
+
+			file := filepath.Join("testdata/text", definition.DeviceTypeCode+".txt")
+			msg, err := os.ReadFile(file)
+			assert.NoError(t, err)
+
+			res, err := parser.Parse(string(msg))
+			assert.NoError(t, err)
+
+			actual, err := json.MarshalIndent(res, "", "  ")
+			assert.NoError(t, err)
+
+			golden := strings.TrimSuffix(file, ".txt") + ".json"
+			if *update {
+				assert.NoError(t, os.WriteFile(golden, append(actual, '\n'), 0o644))
+			}
+			expected, err := os.ReadFile(golden)
+			assert.NoError(t, err)
+			assert.JSONEq(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestTextParser_Records(t *testing.T) {
+	parser, err := NewTextParser(TextDefinition{
+		DeviceTypeCode: "urine",
+		Record:         TextRecordMarkers{Start: `^NO\.`},
+		SpecimenID:     TextPattern{Pattern: `^(NO\.\d+)`},
+		Lines:          []string{`^(?P<code>\w+)\s+(?P<value>\S+)`},
+		Parameters:     map[string]TextParameter{"PH": {Code: "ph"}},
+	})
+	assert.NoError(t, err)
+
+	res, err := parser.Parse("NO.1\nPH 6\nNO.2\nPH 7\n")
+	assert.NoError(t, err)
+	assert.Len(t, res.Records, 2)
+	assert.Equal(t, "NO.2", res.Records[1].SpecimenID)
+	assert.Equal(t, 7.0, *res.Records[1].Tests[0].NumericValue)
+
+	// nothing before the first start marker belongs to a record
+	res, err = parser.Parse("PH 6\n")
+	assert.NoError(t, err)
+	assert.Empty(t, res.Records)
+}
+
+func TestNewTextParser_Invalid(t *testing.T) {
+	definitions := []TextDefinition{
+		{Lines: []string{`(?P<code>\w+) (?P<value>\w+)`}},
+		{DeviceTypeCode: "urine"},
+		{DeviceTypeCode: "urine", Lines: []string{`(\w+) (\w+)`}},
+		{DeviceTypeCode: "urine", Lines: []string{`(?P<code>\w+`}},
+		{DeviceTypeCode: "urine", Lines: []string{`(?P<code>\w+) (?P<value>\w+)`}, DateTime: TextPattern{Pattern: `(\d+)`}},
+	}
+	for _, definition := range definitions {
+		_, err := NewTextParser(definition)
+		assert.ErrorIs(t, err, errors.ERROR_INVALID_TEXT_DEFINITION)
+	}
+
+	_, err := LoadTextDefinitions("testdata/text/missing")
+	assert.Error(t, err)
+}
+
+func TestTextParser_Diagnostics(t *testing.T) {
+	parser, err := NewTextParser(TextDefinition{
+		DeviceTypeCode: "urine",
+		Record:         TextRecordMarkers{Start: `^NO\.`},
+		DateTime:       TextPattern{Pattern: `DATE (\S+)`, Layout: "2006-01-02"},
+		Lines:          []string{`^(?P<code>\w+) (?P<value>\w+) (?P<numeric>\S+)`},
+		Ignore:         []string{`^DATE `},
+		Parameters:     map[string]TextParameter{"PH": {Code: "ph"}},
+	})
+	assert.NoError(t, err)
+
+	res, err := parser.Parse("NO.1\nDATE 2025-13-01\nPH 6 6,5\nGLU 1 1\n???\n")
+	assert.NoError(t, err)
+	assert.Len(t, res.Records[0].Tests, 1)
+	assert.Equal(t, []Diagnostic{
+		{Kind: DIAGNOSTIC_INVALID_VALUE, Location: "line 1", Field: FIELD_TIMESTAMP, Value: "2025-13-01"},
+		{Kind: DIAGNOSTIC_INVALID_NUMBER, Location: "line 3", Field: "ph", Value: "6,5"},
+		{Kind: DIAGNOSTIC_UNRECOGNIZED, Location: "line 4", Value: "GLU 1 1"},
+		{Kind: DIAGNOSTIC_UNRECOGNIZED, Location: "line 5", Value: "???"},
+	}, res.Records[0].Diagnostics)
+}
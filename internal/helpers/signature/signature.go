@@ -0,0 +1,78 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// Headers of a signed request, the signature covers the timestamp, the nonce
+// and the body so none of them can be replaced without the secret.
+const (
+	HEADER_KEY_ID          = "X-Lis-Key-Id"
+	HEADER_TIMESTAMP       = "X-Lis-Timestamp"
+	HEADER_NONCE           = "X-Lis-Nonce"
+	HEADER_SIGNATURE       = "X-Lis-Signature"
+	HEADER_IDEMPOTENCY_KEY = "Idempotency-Key"
+
+	ALGORITHM = "sha256"
+)
+
+// Sign returns the HMAC-SHA256 of "<timestamp>.<nonce>.<body>" as
+// "sha256=<hex>", the timestamp is in unix seconds.
+func Sign(secret string, timestamp int64, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return ALGORITHM + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetHeaders signs the body with the secret of the key and sets the
+// signature headers on the request.
+func SetHeaders(header http.Header, keyID, secret string, timestamp time.Time, nonce string, body []byte) {
+	header.Set(HEADER_KEY_ID, keyID)
+	header.Set(HEADER_TIMESTAMP, strconv.FormatInt(timestamp.Unix(), 10))
+	header.Set(HEADER_NONCE, nonce)
+	header.Set(HEADER_SIGNATURE, Sign(secret, timestamp.Unix(), nonce, body))
+}
+
+// Verify checks the signature headers against the secrets by key id and
+// rejects timestamps further than the tolerance from now. Remembering the
+// nonces seen within the tolerance to reject replays is left to the caller.
+func Verify(header http.Header, body []byte, secrets map[string]string, now time.Time, tolerance time.Duration) error {
+	keyID := header.Get(HEADER_KEY_ID)
+	secret, ok := secrets[keyID]
+	if !ok || secret == "" {
+		return errors.Wrapf(errors.ERROR_INVALID_SIGNATURE, "unknown key id %q", keyID)
+	}
+
+	timestamp, err := strconv.ParseInt(header.Get(HEADER_TIMESTAMP), 10, 64)
+	if err != nil {
+		return errors.Wrapf(errors.ERROR_INVALID_SIGNATURE, "timestamp %q", header.Get(HEADER_TIMESTAMP))
+	}
+	if skew := now.Sub(time.Unix(timestamp, 0)).Abs(); skew > tolerance {
+		return errors.Wrapf(errors.ERROR_INVALID_SIGNATURE, "timestamp is %s off", skew)
+	}
+
+	nonce := header.Get(HEADER_NONCE)
+	if strings.TrimSpace(nonce) == "" {
+		return errors.Wrap(errors.ERROR_INVALID_SIGNATURE, "missing nonce")
+	}
+
+	expected := Sign(secret, timestamp, nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(header.Get(HEADER_SIGNATURE))) {
+		return errors.Wrap(errors.ERROR_INVALID_SIGNATURE, "signature mismatch")
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package signature
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// echo -n '1700000000.nonce-1.{}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t,
+		"sha256=5458f2f08a043a552b8f713128e75b46813c591e01c5d984962b25f4bc5d0b47",
+		Sign("secret", 1700000000, "nonce-1", []byte("{}")),
+	)
+}
+
+func TestVerify(t *testing.T) {
+	secrets := map[string]string{"2025-12": "old-secret", "2026-01": "new-secret"}
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"device_id":"urine-1"}`)
+
+	signed := func(keyID string, at time.Time) http.Header {
+		header := http.Header{}
+		SetHeaders(header, keyID, secrets[keyID], at, "nonce-1", body)
+
+		return header
+	}
+
+	// both the current and the retired key verify while rotating
+	assert.NoError(t, Verify(signed("2026-01", now), body, secrets, now, time.Minute))
+	assert.NoError(t, Verify(signed("2025-12", now.Add(-30*time.Second)), body, secrets, now, time.Minute))
+
+	// a tampered body, a stale timestamp or an unknown key are rejected
+	assert.ErrorIs(t, Verify(signed("2026-01", now), []byte(`{}`), secrets, now, time.Minute), errors.ERROR_INVALID_SIGNATURE)
+	assert.ErrorIs(t, Verify(signed("2026-01", now.Add(-2*time.Minute)), body, secrets, now, time.Minute), errors.ERROR_INVALID_SIGNATURE)
+	assert.ErrorIs(t, Verify(signed("2024-01", now), body, secrets, now, time.Minute), errors.ERROR_INVALID_SIGNATURE)
+
+	// the nonce is signed so it cannot be swapped
+	header := signed("2026-01", now)
+	header.Set(HEADER_NONCE, "nonce-2")
+	assert.ErrorIs(t, Verify(header, body, secrets, now, time.Minute), errors.ERROR_INVALID_SIGNATURE)
+	header.Del(HEADER_NONCE)
+	assert.ErrorIs(t, Verify(header, body, secrets, now, time.Minute), errors.ERROR_INVALID_SIGNATURE)
+}
@@ -0,0 +1,203 @@
+package ucum
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+)
+
+// aliases maps the lower case unit strings printed by instruments that do
+// not follow the prefix/atom form to their UCUM code.
+var aliases = map[string]string{
+	"cell/ul":   "/uL",
+	"cells/ul":  "/uL",
+	"leu/ul":    "/uL",
+	"ery/ul":    "/uL",
+	"wbc/ul":    "/uL",
+	"rbc/ul":    "/uL",
+	"k/ul":      "10*3/uL",
+	"x10^3/ul":  "10*3/uL",
+	"x10^6/ul":  "10*6/uL",
+	"x10^9/l":   "10*9/L",
+	"x10^12/l":  "10*12/L",
+	"iu/l":      "[IU]/L",
+	"u/l":       "U/L",
+	"%":         "%",
+	"fl":        "fL",
+	"pg":        "pg",
+	"mmhg":      "mm[Hg]",
+	"mm/hr":     "mm/h",
+	"mm/h":      "mm/h",
+	"sec":       "s",
+	"s":         "s",
+	"ml/min":    "mL/min",
+	"g/cm3":     "g/cm3",
+	"mosm/kg":   "mosm/kg",
+	"ratio":     "{ratio}",
+	"index":     "{index}",
+	"mmol/mol":  "mmol/mol",
+	"cells/hpf": "/[HPF]",
+	"/hpf":      "/[HPF]",
+}
+
+// micro is printed with the micro sign or the Greek mu, UCUM writes u
+var micro = strings.NewReplacer("µ", "u", "μ", "u")
+
+// prefixes are the metric prefixes met in laboratory units, matched without
+// case so mega is never read from an M.
+var prefixes = map[string]float64{
+	"":  1,
+	"k": 1e3,
+	"d": 1e-1,
+	"c": 1e-2,
+	"m": 1e-3,
+	"u": 1e-6,
+	"n": 1e-9,
+	"p": 1e-12,
+	"f": 1e-15,
+}
+
+// atoms are the numerator units of a concentration, mol is tried before g
+// so mmol is not read as a prefixed g.
+var atoms = []string{"mol", "eq", "g"}
+
+// Concentration dimensions, counts are cells or particles per volume
+const (
+	DIMENSION_MASS      = "g"
+	DIMENSION_SUBSTANCE = "mol"
+	DIMENSION_CHARGE    = "eq"
+	DIMENSION_COUNT     = "count"
+)
+
+// concentration is a UCUM code per litre, factor scales a value to the
+// dimension base per litre, e.g. 10 for mg/dL to g/L.
+type concentration struct {
+	code      string
+	dimension string
+	factor    float64
+}
+
+// Code returns the UCUM code of a unit printed by an instrument, e.g.
+// "/uL" for "CELL/uL" or "mg/dL" for "MG/DL".
+func Code(unit string) (string, bool) {
+	unit = micro.Replace(strings.TrimSpace(unit))
+	if unit == "" {
+		return "", false
+	}
+	if code, ok := aliases[strings.ToLower(unit)]; ok {
+		return code, true
+	}
+	if c, ok := parseConcentration(unit); ok {
+		return c.code, true
+	}
+
+	return "", false
+}
+
+// Convert converts a value between two UCUM codes. Mass and substance
+// concentrations convert through the molar mass of the analyte in g/mol, pass
+// 0 when it is unknown.
+func Convert(value float64, from, to string, molarMass float64) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+
+	source, ok := parseConcentration(from)
+	if !ok {
+		return 0, errors.Wrapf(errors.ERROR_UNIT_CONVERSION, "unit %q", from)
+	}
+	target, ok := parseConcentration(to)
+	if !ok {
+		return 0, errors.Wrapf(errors.ERROR_UNIT_CONVERSION, "unit %q", to)
+	}
+
+	base := value * source.factor
+	switch {
+	case source.dimension == target.dimension:
+	case source.dimension == DIMENSION_MASS && target.dimension == DIMENSION_SUBSTANCE && molarMass > 0:
+		base /= molarMass
+	case source.dimension == DIMENSION_SUBSTANCE && target.dimension == DIMENSION_MASS && molarMass > 0:
+		base *= molarMass
+	default:
+		return 0, errors.Wrapf(errors.ERROR_UNIT_CONVERSION, "%s to %s", from, to)
+	}
+
+	return base / target.factor, nil
+}
+
+// Valid tells whether the code is a UCUM code this package can convert or
+// normalize to.
+func Valid(code string) bool {
+	if _, ok := parseConcentration(code); ok {
+		return true
+	}
+	for _, alias := range aliases {
+		if alias == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseConcentration reads "<numerator>/<prefix>L" where the numerator is a
+// prefixed mol, eq or g, a power of ten count such as 10*3 or 10^3, or empty
+// for a plain count.
+func parseConcentration(unit string) (concentration, bool) {
+	numerator, denominator, found := strings.Cut(strings.TrimSpace(unit), "/")
+	if !found || strings.Contains(denominator, "/") {
+		return concentration{}, false
+	}
+
+	// the volume, L with a prefix
+	denominator = strings.ToLower(denominator)
+	volumePrefix, ok := strings.CutSuffix(denominator, "l")
+	volume, known := prefixes[volumePrefix]
+	if !ok || !known {
+		return concentration{}, false
+	}
+	res := concentration{code: "/" + volumePrefix + "L"}
+
+	if count, ok := parseCount(numerator); ok {
+		res.dimension = DIMENSION_COUNT
+		res.factor = count / volume
+		if numerator != "" {
+			res.code = "10*" + numerator[3:] + res.code
+		}
+		return res, true
+	}
+
+	numerator = strings.ToLower(numerator)
+	for _, atom := range atoms {
+		prefix, ok := strings.CutSuffix(numerator, atom)
+		factor, known := prefixes[prefix]
+		if !ok || !known {
+			continue
+		}
+		res.dimension = atom
+		res.factor = factor / volume
+		res.code = prefix + atom + res.code
+		return res, true
+	}
+
+	return concentration{}, false
+}
+
+// parseCount reads an empty numerator or a power of ten such as 10*9 or 10^9.
+func parseCount(numerator string) (float64, bool) {
+	if numerator == "" {
+		return 1, true
+	}
+	if len(numerator) < 4 || numerator[:2] != "10" || (numerator[2] != '*' && numerator[2] != '^') {
+		return 0, false
+	}
+
+	exponent, err := strconv.Atoi(numerator[3:])
+	if err != nil || exponent < 0 || exponent > 18 {
+		return 0, false
+	}
+
+	return math.Pow10(exponent), true
+}
@@ -0,0 +1,74 @@
+package ucum
+
+import (
+	"testing"
+
+	"github.com/Calmantara/lis-backend/internal/helpers/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCode(t *testing.T) {
+	for unit, expected := range map[string]string{
+		"mmol/L":   "mmol/L",
+		"MG/DL":    "mg/dL",
+		"mg/dl":    "mg/dL",
+		"CELL/uL":  "/uL",
+		"Leu/uL":   "/uL",
+		"10^3/uL":  "10*3/uL",
+		"10*9/L":   "10*9/L",
+		"x10^12/L": "10*12/L",
+		"µmol/L":   "umol/L",
+		"μg/dL":    "ug/dL",
+		"mEq/L":    "meq/L",
+		"IU/L":     "[IU]/L",
+		" g/L ":    "g/L",
+		"fL":       "fL",
+	} {
+		code, ok := Code(unit)
+		assert.True(t, ok, unit)
+		assert.Equal(t, expected, code, unit)
+	}
+
+	for _, unit := range []string{"", "Normal", "mg/dL/h", "Mx/L", "10^x/uL", "mg/h"} {
+		_, ok := Code(unit)
+		assert.False(t, ok, unit)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		value     float64
+		from, to  string
+		molarMass float64
+		expected  float64
+	}{
+		{100, "mg/dL", "g/L", 0, 1},
+		{1, "g/L", "mg/dL", 0, 100},
+		{500, "/uL", "10*3/uL", 0, 0.5},
+		{7.2, "10*3/uL", "10*9/L", 0, 7.2},
+		{5, "mmol/L", "umol/L", 0, 5000},
+		// glucose
+		{90, "mg/dL", "mmol/L", 180.16, 4.9956},
+		{5.5, "mmol/L", "mg/dL", 180.16, 99.088},
+		// bilirubin
+		{1, "mg/dL", "umol/L", 584.66, 17.104},
+		{3, "%", "%", 0, 3},
+	}
+	for _, test := range tests {
+		converted, err := Convert(test.value, test.from, test.to, test.molarMass)
+		assert.NoError(t, err)
+		assert.InDelta(t, test.expected, converted, 1e-3, "%v %s to %s", test.value, test.from, test.to)
+	}
+
+	for _, pair := range [][2]string{{"mg/dL", "mmol/L"}, {"/uL", "g/L"}, {"%", "g/L"}, {"mg/dL", "s"}} {
+		_, err := Convert(1, pair[0], pair[1], 0)
+		assert.ErrorIs(t, err, errors.ERROR_UNIT_CONVERSION, pair)
+	}
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("mmol/L"))
+	assert.True(t, Valid("/uL"))
+	assert.True(t, Valid("[IU]/L"))
+	assert.False(t, Valid("Normal"))
+}
@@ -26,6 +26,10 @@ const (
 	ERROR_MISSING_APPLICATION_ID
 	ERROR_MISSING_TOKEN_ID
 	ERROR_DUPLICATED_KEY
+	ERROR_PROCESSING_ID_REJECTED
+	ERROR_UNSUPPORTED_MESSAGE
+	ERROR_PARSE_DIAGNOSTICS
+	ERROR_DEAD_LETTER_CLOSED
 )
 
 var (
@@ -48,6 +52,10 @@ var (
 		errors.ERROR_MISSING_APPLICATION_ID:        ERROR_MISSING_APPLICATION_ID,
 		errors.ERROR_MISSING_TOKEN_ID:              ERROR_MISSING_TOKEN_ID,
 		errors.ERROR_DUPLICATED_KEY:                ERROR_DUPLICATED_KEY,
+		errors.ERROR_PROCESSING_ID_REJECTED:        ERROR_PROCESSING_ID_REJECTED,
+		errors.ERROR_UNSUPPORTED_MESSAGE:           ERROR_UNSUPPORTED_MESSAGE,
+		errors.ERROR_PARSE_DIAGNOSTICS:             ERROR_PARSE_DIAGNOSTICS,
+		errors.ERROR_DEAD_LETTER_CLOSED:            ERROR_DEAD_LETTER_CLOSED,
 	}
 )
 
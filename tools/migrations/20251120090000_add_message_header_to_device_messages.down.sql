@@ -0,0 +1,8 @@
+ALTER TABLE device_messages
+    DROP INDEX idx_device_messages_message_control_id,
+    DROP COLUMN message_type,
+    DROP COLUMN message_control_id,
+    DROP COLUMN processing_id,
+    DROP COLUMN sending_application,
+    DROP COLUMN sending_facility,
+    DROP COLUMN protocol_version;
@@ -0,0 +1,8 @@
+ALTER TABLE device_messages
+    ADD COLUMN message_type CHAR(50),
+    ADD COLUMN message_control_id CHAR(100),
+    ADD COLUMN processing_id CHAR(10),
+    ADD COLUMN sending_application CHAR(100),
+    ADD COLUMN sending_facility CHAR(100),
+    ADD COLUMN protocol_version CHAR(20),
+    ADD INDEX idx_device_messages_message_control_id (message_control_id);
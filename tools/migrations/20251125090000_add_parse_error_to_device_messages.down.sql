@@ -0,0 +1,2 @@
+ALTER TABLE device_messages
+    DROP COLUMN parse_error;
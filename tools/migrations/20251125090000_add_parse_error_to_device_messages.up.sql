@@ -0,0 +1,2 @@
+ALTER TABLE device_messages
+    ADD COLUMN parse_error TEXT;
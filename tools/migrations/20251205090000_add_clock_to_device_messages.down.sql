@@ -0,0 +1,6 @@
+ALTER TABLE device_messages
+    DROP COLUMN timezone,
+    DROP COLUMN instrument_time,
+    DROP COLUMN received_at,
+    DROP COLUMN clock_skew,
+    DROP COLUMN clock_skewed;
@@ -0,0 +1,6 @@
+ALTER TABLE device_messages
+    ADD COLUMN timezone CHAR(64),
+    ADD COLUMN instrument_time TIMESTAMP NULL,
+    ADD COLUMN received_at TIMESTAMP NULL,
+    ADD COLUMN clock_skew BIGINT DEFAULT 0,
+    ADD COLUMN clock_skewed BOOLEAN DEFAULT FALSE;
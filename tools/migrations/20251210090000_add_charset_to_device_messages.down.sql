@@ -0,0 +1,3 @@
+ALTER TABLE device_messages
+    DROP COLUMN charset,
+    DROP COLUMN raw_message;
@@ -0,0 +1,3 @@
+ALTER TABLE device_messages
+    ADD COLUMN charset CHAR(32),
+    ADD COLUMN raw_message LONGBLOB NULL;
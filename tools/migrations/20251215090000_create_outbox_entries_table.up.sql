@@ -0,0 +1,15 @@
+CREATE TABLE outbox_entries (
+    id CHAR(36) PRIMARY KEY DEFAULT (UUID()),
+    device_message_id CHAR(36),
+    payload LONGTEXT,
+    status CHAR(32),
+    attempts INT DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NULL,
+    delivered_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,
+    INDEX idx_outbox_entries_status_next_attempt_at (status, next_attempt_at),
+    INDEX idx_outbox_entries_device_message_id (device_message_id)
+);
@@ -0,0 +1,21 @@
+CREATE TABLE dead_letters (
+    id CHAR(36) PRIMARY KEY DEFAULT (UUID()),
+    reason CHAR(32),
+    status CHAR(32),
+    device_message_id CHAR(36),
+    outbox_entry_id CHAR(36),
+    device_id CHAR(36),
+    device_type_code CHAR(100),
+    protocol CHAR(100),
+    error_chain JSON,
+    attempts INT DEFAULT 0,
+    payload LONGTEXT,
+    replayed_payload LONGTEXT,
+    replayed_at TIMESTAMP NULL,
+    discarded_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP NULL,
+    INDEX idx_dead_letters_status_created_at (status, created_at),
+    INDEX idx_dead_letters_device_message_id (device_message_id)
+);
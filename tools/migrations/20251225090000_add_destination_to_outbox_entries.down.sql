@@ -0,0 +1,3 @@
+ALTER TABLE outbox_entries
+    DROP INDEX idx_outbox_entries_destination_status,
+    DROP COLUMN destination;
@@ -0,0 +1,3 @@
+ALTER TABLE outbox_entries
+    ADD COLUMN destination CHAR(100) NOT NULL DEFAULT 'lis',
+    ADD INDEX idx_outbox_entries_destination_status (destination, status);
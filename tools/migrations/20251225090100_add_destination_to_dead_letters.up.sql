@@ -0,0 +1,2 @@
+ALTER TABLE dead_letters
+    ADD COLUMN destination CHAR(100) NULL;
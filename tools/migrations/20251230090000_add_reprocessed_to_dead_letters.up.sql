@@ -0,0 +1,2 @@
+ALTER TABLE dead_letters
+    ADD COLUMN reprocessed INT NOT NULL DEFAULT 0;